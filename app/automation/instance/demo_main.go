@@ -1,39 +1,43 @@
+//go:build automation
+
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+
 	"github.com/go-vgo/robotgo"
 	"raselper/app/automation/src"
 )
 
 func main() {
+	fsm := src.NewFSM(100 * time.Millisecond)
 
 	Idle := src.NewStatus("Idle")
 	Run := src.NewStatus("Run")
-	src.RegisterStatus(Idle, func() {
+	fsm.RegisterStatus(Idle, func() {
 		x, y := robotgo.Location()
 		println("Idle: ", x, y)
 	})
-	src.RegisterStatus(Run, func() {
+	fsm.RegisterStatus(Run, func() {
 		x, y := robotgo.Location()
 		println("Run: ", x, y)
 	})
 
-	src.RegisterTrans(Idle, func() *src.StatusNode {
+	fsm.AddTransition(Idle, Run, func() bool {
 		x, _ := robotgo.Location()
-		if x > 1000 {
-			return &Run
-		}
-		return nil
+		return x > 1000
 	})
-	src.RegisterTrans(Run, func() *src.StatusNode {
+	fsm.AddTransition(Run, Idle, func() bool {
 		x, _ := robotgo.Location()
-		if x <= 1000 {
-			return &Idle
-		}
-		return nil
+		return x <= 1000
 	})
 
-	src.Start(Idle)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	_ = fsm.Run(ctx, Idle)
 }
 
 func getLocation() {
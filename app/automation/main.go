@@ -1 +1,86 @@
 package automation
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"raselper/app/automation/src"
+)
+
+// Run
+//
+//	raselper automation run <flow.yaml>
+//	raselper automation record <timeline.json> [-duration 1m] [-min-move 5]
+//	raselper automation play <timeline.json> [-speed 2] [-loops 3]
+func Run(params []string, driver src.Driver, source src.InputSource) error {
+	if len(params) < 3 {
+		return errors.New("missing automation command")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	switch params[2] {
+	case "run":
+		if len(params) < 4 {
+			return errors.New("usage: automation run <flow.yaml>")
+		}
+		flow, err := src.LoadFlow(params[3])
+		if err != nil {
+			return err
+		}
+		return src.RunFlow(ctx, flow, driver)
+	case "record":
+		if len(params) < 4 {
+			return errors.New("usage: automation record <timeline.json> [-duration 1m] [-min-move 5]")
+		}
+		flags := flag.NewFlagSet("record", flag.ContinueOnError)
+		duration := flags.Duration("duration", 0, "录制时长, 0表示直到Ctrl+C")
+		minMove := flags.Int("min-move", 5, "小于该距离的移动不记录")
+		if err := flags.Parse(params[4:]); err != nil {
+			return err
+		}
+		if *duration > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, *duration)
+			defer cancel()
+		}
+		fmt.Println("recording, Ctrl+C to stop...")
+		recorder := &src.Recorder{Source: source, MinMove: *minMove}
+		timeline, err := recorder.Record(ctx, params[3])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("recorded %d events to %s\n", len(timeline.Events), params[3])
+		return src.SaveTimeline(params[3], timeline)
+	case "play":
+		if len(params) < 4 {
+			return errors.New("usage: automation play <timeline.json> [-speed 2] [-loops 3]")
+		}
+		flags := flag.NewFlagSet("play", flag.ContinueOnError)
+		speed := flags.Float64("speed", 1, "回放速度倍数")
+		loops := flags.Int("loops", 1, "回放次数, 小于0一直循环")
+		if err := flags.Parse(params[4:]); err != nil {
+			return err
+		}
+		timeline, err := src.LoadTimeline(params[3])
+		if err != nil {
+			return err
+		}
+		player := &src.Player{Driver: driver, Speed: *speed, Loops: *loops}
+		if err := player.Play(ctx, timeline); err != nil && err != context.Canceled {
+			return err
+		}
+		return nil
+	case "help":
+		fmt.Println("run <flow.yaml>")
+		fmt.Println("record <timeline.json> [-duration 1m] [-min-move 5]")
+		fmt.Println("play <timeline.json> [-speed 2] [-loops 3]")
+		return nil
+	}
+
+	return errors.New("command:" + params[2] + " not found")
+}
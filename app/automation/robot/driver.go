@@ -0,0 +1,61 @@
+//go:build automation
+
+package robot
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-vgo/robotgo"
+	"raselper/app/automation/src"
+)
+
+// Driver 基于robotgo操作真实桌面
+type Driver struct{}
+
+func NewDriver() src.Driver {
+	return Driver{}
+}
+
+func (d Driver) Location() (int, int) {
+	return robotgo.Location()
+}
+
+func (d Driver) Move(x, y int) {
+	robotgo.Move(x, y)
+}
+
+func (d Driver) Click(button string, double bool) {
+	robotgo.Click(button, double)
+}
+
+func (d Driver) Type(text string) {
+	robotgo.TypeStr(text)
+}
+
+func (d Driver) KeyTap(key string, modifiers ...string) {
+	if len(modifiers) == 0 {
+		_ = robotgo.KeyTap(key)
+		return
+	}
+	_ = robotgo.KeyTap(key, modifiers)
+}
+
+func (d Driver) PixelColor(x, y int) string {
+	return robotgo.GetPixelColor(x, y)
+}
+
+func (d Driver) Sleep(duration time.Duration) {
+	time.Sleep(duration)
+}
+
+func (d Driver) SleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
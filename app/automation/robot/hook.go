@@ -0,0 +1,110 @@
+//go:build automation
+
+package robot
+
+import (
+	"context"
+	"time"
+
+	hook "github.com/robotn/gohook"
+	"raselper/app/automation/src"
+)
+
+// libuiohook的修饰键掩码, 左右键不区分
+var hookModifiers = []struct {
+	mask uint16
+	name string
+}{
+	{1<<0 | 1<<4, "shift"},
+	{1<<1 | 1<<5, "ctrl"},
+	{1<<2 | 1<<6, "cmd"},
+	{1<<3 | 1<<7, "alt"},
+}
+
+var hookButtons = map[uint16]string{1: "left", 2: "right", 3: "center"}
+
+// HookSource 基于gohook全局键鼠钩子的输入源, 录制移动、点击和按键
+//
+// 钩子是进程全局的, 同一时间只能有一个HookSource在运行
+type HookSource struct{}
+
+func NewHookSource() src.InputSource {
+	return HookSource{}
+}
+
+func (s HookSource) Start(ctx context.Context) (<-chan src.InputEvent, error) {
+	hookEvents := hook.Start()
+	events := make(chan src.InputEvent)
+	go func() {
+		defer close(events)
+		defer hook.End()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-hookEvents:
+				if !ok {
+					return
+				}
+				event, ok := convertHookEvent(e)
+				if !ok {
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func convertHookEvent(e hook.Event) (src.InputEvent, bool) {
+	when := e.When
+	if when.IsZero() {
+		when = time.Now()
+	}
+	switch e.Kind {
+	case hook.MouseMove, hook.MouseDrag:
+		return src.InputEvent{Time: when, Kind: src.EventMove, X: int(e.X), Y: int(e.Y)}, true
+	case hook.MouseDown:
+		button, ok := hookButtons[e.Button]
+		if !ok {
+			return src.InputEvent{}, false
+		}
+		return src.InputEvent{Time: when, Kind: src.EventClick, X: int(e.X), Y: int(e.Y), Button: button, Double: e.Clicks >= 2}, true
+	case hook.KeyDown:
+		key := hook.RawcodetoKeychar(e.Rawcode)
+		if key == "" || isModifierKey(key) {
+			return src.InputEvent{}, false
+		}
+		if name, ok := hookKeyNames[key]; ok {
+			key = name
+		}
+		var modifiers []string
+		for _, m := range hookModifiers {
+			if e.Mask&m.mask != 0 {
+				modifiers = append(modifiers, m.name)
+			}
+		}
+		return src.InputEvent{Time: when, Kind: src.EventKey, Key: key, Modifiers: modifiers}, true
+	}
+	return src.InputEvent{}, false
+}
+
+// gohook键名与robotgo.KeyTap不同的部分
+var hookKeyNames = map[string]string{
+	"spacebar": "space", "escape": "esc", "left arrow": "left", "up arrow": "up", "right arrow": "right",
+	"down arrow": "down", "page up": "pageup", "page down": "pagedown", "caps lock": "capslock",
+}
+
+// 单独按下修饰键不记录, 回放时作为其他按键的modifiers
+func isModifierKey(key string) bool {
+	switch key {
+	case "shift", "ctrl", "alt", "l-super", "r-super":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+//go:build automation
+
+package robot
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-vgo/robotgo"
+	"raselper/app/automation/src"
+)
+
+// PollSource 定时读取鼠标位置作为输入源, 只能录制鼠标移动;
+// 不能使用全局钩子的环境下替代HookSource
+type PollSource struct {
+	Interval time.Duration
+}
+
+func NewPollSource(interval time.Duration) src.InputSource {
+	return PollSource{Interval: interval}
+}
+
+func (s PollSource) Start(ctx context.Context) (<-chan src.InputEvent, error) {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = 50 * time.Millisecond
+	}
+
+	events := make(chan src.InputEvent)
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		lastX, lastY := -1, -1
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				x, y := robotgo.Location()
+				if x == lastX && y == lastY {
+					continue
+				}
+				lastX, lastY = x, y
+				select {
+				case events <- src.InputEvent{Time: now, Kind: src.EventMove, X: x, Y: y}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
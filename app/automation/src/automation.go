@@ -1,5 +1,12 @@
 package src
 
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
 type StatusNode struct {
 	name string
 }
@@ -10,46 +17,226 @@ func NewStatus(name string) StatusNode {
 	}
 }
 
-var (
-	status      StatusNode
-	statusTasks = map[StatusNode]func(){}
-	statusTrans = map[StatusNode][]func() *StatusNode{}
-)
+func (s StatusNode) Name() string {
+	return s.name
+}
+
+// 状态及其进入、退出回调
+type state struct {
+	task    func()
+	onEnter func()
+	onExit  func()
+}
+
+// Event 状态切换记录
+type Event struct {
+	Time time.Time
+	From StatusNode
+	To   StatusNode
+}
+
+// 事件记录最多保留条数
+const maxEvents = 1000
+
+// FSM 状态机
+//
+// 每次Step先按注册顺序检查当前状态的转换条件, 第一个满足的生效,
+// 然后执行(切换后)当前状态的task. Run按interval循环调用Step,
+// 测试时直接调用Step即可得到确定的结果.
+type FSM struct {
+	mu        sync.Mutex
+	status    StatusNode
+	started   bool
+	enteredAt time.Time
+	states    map[StatusNode]*state
+	trans     map[StatusNode][]func() *StatusNode
+	events    []Event
+	interval  time.Duration
+	now       func() time.Time
+}
+
+func NewFSM(interval time.Duration) *FSM {
+	return &FSM{
+		states:   make(map[StatusNode]*state),
+		trans:    make(map[StatusNode][]func() *StatusNode),
+		interval: interval,
+		now:      time.Now,
+	}
+}
 
-func RegisterStatus(newStatus StatusNode, task func()) {
-	status = newStatus
-	statusTasks[status] = task
+// SetClock 替换时钟, 用于测试
+func (f *FSM) SetClock(now func() time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
 }
 
-func RegisterTrans(status StatusNode, task func() *StatusNode) {
-	statusTrans[status] = append(statusTrans[status], task)
+func (f *FSM) getState(status StatusNode) *state {
+	s, ok := f.states[status]
+	if !ok {
+		s = &state{}
+		f.states[status] = s
+	}
+	return s
 }
 
-func Start(startNode StatusNode) {
-	status = startNode
+// RegisterStatus 注册状态, 处于该状态时每次Step执行task
+func (f *FSM) RegisterStatus(status StatusNode, task func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.getState(status).task = task
+}
 
-	// 循环更新status
-	go doStatusTrans()
+// OnEnter 进入状态时执行
+func (f *FSM) OnEnter(status StatusNode, hook func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.getState(status).onEnter = hook
+}
 
-	// 循环运行task
-	doStatusTask()
+// OnExit 离开状态时执行
+func (f *FSM) OnExit(status StatusNode, hook func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.getState(status).onExit = hook
 }
 
-func doStatusTrans() {
-	for true {
-		tranList := statusTrans[status]
-		for _, trans := range tranList {
-			statusNode := trans()
-			if statusNode != nil {
-				status = *statusNode
-			}
+// RegisterTrans 注册转换, task返回非nil时切换到返回的状态
+func (f *FSM) RegisterTrans(status StatusNode, task func() *StatusNode) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.getState(status)
+	f.trans[status] = append(f.trans[status], task)
+}
+
+// AddTransition 注册带条件的转换, guard为true时从from切换到to
+func (f *FSM) AddTransition(from, to StatusNode, guard func() bool) {
+	f.RegisterTrans(from, func() *StatusNode {
+		if guard() {
+			return &to
 		}
+		return nil
+	})
+}
+
+// Current 当前状态
+func (f *FSM) Current() StatusNode {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status
+}
+
+// Elapsed 进入当前状态后经过的时间
+func (f *FSM) Elapsed() time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now().Sub(f.enteredAt)
+}
+
+// Events 状态切换记录
+func (f *FSM) Events() []Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Event{}, f.events...)
+}
+
+// Start 设置初始状态并执行其进入回调
+func (f *FSM) Start(startNode StatusNode) error {
+	f.mu.Lock()
+	s, ok := f.states[startNode]
+	if !ok {
+		f.mu.Unlock()
+		return errors.New("status not registered: " + startNode.name)
+	}
+	f.status = startNode
+	f.started = true
+	f.enteredAt = f.now()
+	f.mu.Unlock()
+
+	if s.onEnter != nil {
+		s.onEnter()
 	}
+	return nil
 }
 
-func doStatusTask() {
-	for true {
-		task := statusTasks[status]
+// Step 执行一次状态转换检查和当前状态的task, 返回是否发生了切换
+func (f *FSM) Step() (bool, error) {
+	f.mu.Lock()
+	if !f.started {
+		f.mu.Unlock()
+		return false, errors.New("fsm not started")
+	}
+	from := f.status
+	tranList := f.trans[from]
+	f.mu.Unlock()
+
+	// 回调在锁外执行, 回调里可以调用Current/Elapsed
+	var next *StatusNode
+	for _, trans := range tranList {
+		if next = trans(); next != nil {
+			break
+		}
+	}
+
+	changed := false
+	if next != nil && *next != from {
+		f.mu.Lock()
+		fromState := f.states[from]
+		nextState, ok := f.states[*next]
+		f.mu.Unlock()
+		if !ok {
+			return false, errors.New("status not registered: " + next.name)
+		}
+		if fromState.onExit != nil {
+			fromState.onExit()
+		}
+
+		f.mu.Lock()
+		f.status = *next
+		f.enteredAt = f.now()
+		f.events = append(f.events, Event{Time: f.enteredAt, From: from, To: *next})
+		if len(f.events) > maxEvents {
+			f.events = f.events[len(f.events)-maxEvents:]
+		}
+		f.mu.Unlock()
+
+		if nextState.onEnter != nil {
+			nextState.onEnter()
+		}
+		changed = true
+	}
+
+	f.mu.Lock()
+	task := f.states[f.status].task
+	f.mu.Unlock()
+	if task != nil {
 		task()
 	}
+
+	return changed, nil
+}
+
+// Run 从startNode开始按interval循环执行, 直到ctx取消
+func (f *FSM) Run(ctx context.Context, startNode StatusNode) error {
+	if err := f.Start(startNode); err != nil {
+		return err
+	}
+
+	interval := f.interval
+	if interval <= 0 {
+		interval = 100 * time.Millisecond // 避免空转占满CPU
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := f.Step(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
 }
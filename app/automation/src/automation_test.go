@@ -0,0 +1,85 @@
+package src
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFSMStep(t *testing.T) {
+	fsm := NewFSM(0)
+	clock := time.Unix(0, 0)
+	fsm.SetClock(func() time.Time { return clock })
+
+	Idle := NewStatus("Idle")
+	Run := NewStatus("Run")
+	var calls []string
+	x := 0
+	fsm.RegisterStatus(Idle, func() { calls = append(calls, "idle") })
+	fsm.RegisterStatus(Run, func() { calls = append(calls, "run") })
+	fsm.OnEnter(Run, func() { calls = append(calls, "enter run") })
+	fsm.OnExit(Idle, func() { calls = append(calls, "exit idle") })
+	fsm.AddTransition(Idle, Run, func() bool { return x > 1000 })
+	fsm.AddTransition(Run, Idle, func() bool { return fsm.Elapsed() >= time.Second })
+
+	if _, err := fsm.Step(); err == nil {
+		t.Fatal("step before start should fail")
+	}
+	if err := fsm.Start(Idle); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, _ := fsm.Step()
+	if changed || fsm.Current() != Idle {
+		t.Fatalf("unexpected transition to %s", fsm.Current().Name())
+	}
+
+	x = 1200
+	clock = clock.Add(time.Second)
+	changed, _ = fsm.Step()
+	if !changed || fsm.Current() != Run {
+		t.Fatalf("want Run, got %s", fsm.Current().Name())
+	}
+
+	// 刚进入Run, 还没到1秒不会切回
+	changed, _ = fsm.Step()
+	if changed {
+		t.Fatal("guard on elapsed time fired too early")
+	}
+	clock = clock.Add(time.Second)
+	changed, _ = fsm.Step()
+	if !changed || fsm.Current() != Idle {
+		t.Fatalf("want Idle, got %s", fsm.Current().Name())
+	}
+
+	want := []string{"idle", "exit idle", "enter run", "run", "run", "idle"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+
+	events := fsm.Events()
+	if len(events) != 2 || events[0].From != Idle || events[0].To != Run || !events[0].Time.Equal(time.Unix(1, 0)) {
+		t.Fatalf("unexpected events %v", events)
+	}
+}
+
+func TestFSMRunCancel(t *testing.T) {
+	fsm := NewFSM(time.Millisecond)
+	Idle := NewStatus("Idle")
+	steps := 0
+	fsm.RegisterStatus(Idle, func() { steps++ })
+
+	if err := fsm.Run(context.Background(), NewStatus("Missing")); err == nil {
+		t.Fatal("run with unregistered status should fail")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := fsm.Run(ctx, Idle); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if steps == 0 || steps > 60 {
+		t.Fatalf("unexpected step count %d", steps)
+	}
+}
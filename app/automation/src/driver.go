@@ -0,0 +1,78 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Driver 鼠标键盘操作, 实际运行用robotgo实现, 测试时用FakeDriver
+type Driver interface {
+	Location() (int, int)
+	Move(x, y int)
+	Click(button string, double bool)
+	Type(text string)
+	KeyTap(key string, modifiers ...string)
+	PixelColor(x, y int) string // 十六进制颜色, 如 "ff0000"
+	Sleep(duration time.Duration)
+}
+
+// ContextSleeper 可以被ctx打断的等待, Driver实现时回放使用, 否则用Sleep
+type ContextSleeper interface {
+	SleepContext(ctx context.Context, duration time.Duration) error
+}
+
+// FakeDriver 记录所有操作, 不操作真实桌面
+type FakeDriver struct {
+	X, Y    int
+	Pixels  map[[2]int]string // 坐标 - 颜色
+	Actions []string
+	Slept   time.Duration
+}
+
+func NewFakeDriver() *FakeDriver {
+	return &FakeDriver{Pixels: make(map[[2]int]string)}
+}
+
+func (d *FakeDriver) Location() (int, int) {
+	return d.X, d.Y
+}
+
+func (d *FakeDriver) Move(x, y int) {
+	d.X, d.Y = x, y
+	d.Actions = append(d.Actions, fmt.Sprintf("move %d,%d", x, y))
+}
+
+func (d *FakeDriver) Click(button string, double bool) {
+	if double {
+		d.Actions = append(d.Actions, "double click "+button)
+		return
+	}
+	d.Actions = append(d.Actions, "click "+button)
+}
+
+func (d *FakeDriver) Type(text string) {
+	d.Actions = append(d.Actions, "type "+text)
+}
+
+func (d *FakeDriver) KeyTap(key string, modifiers ...string) {
+	d.Actions = append(d.Actions, "key "+strings.Join(append(modifiers, key), "+"))
+}
+
+func (d *FakeDriver) PixelColor(x, y int) string {
+	return d.Pixels[[2]int{x, y}]
+}
+
+func (d *FakeDriver) Sleep(duration time.Duration) {
+	d.Slept += duration
+	d.Actions = append(d.Actions, "wait "+duration.String())
+}
+
+func (d *FakeDriver) SleepContext(ctx context.Context, duration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d.Sleep(duration)
+	return nil
+}
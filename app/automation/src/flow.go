@@ -0,0 +1,266 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Flow yaml描述的自动化流程
+//
+//	name: demo
+//	start: Idle
+//	interval: 100ms
+//	states:
+//	  - name: Idle
+//	    transitions:
+//	      - to: Save
+//	        when: {cursor: {x1: 1000, y1: 0, x2: 1920, y2: 1080}}
+//	  - name: Save
+//	    enter:
+//	      - click: left
+//	      - key: ctrl+s
+//	      - wait: 500ms
+//	    transitions:
+//	      - to: Done
+//	        when: {pixel: {x: 10, y: 10, color: "ffffff"}, elapsed: 2s}
+//	  - name: Done
+//	    final: true
+type Flow struct {
+	Name     string      `yaml:"name"`
+	Start    string      `yaml:"start"`
+	Interval string      `yaml:"interval"`
+	States   []FlowState `yaml:"states"`
+}
+
+// FlowState 状态, enter/exit进入离开时执行一次, actions每次Step执行
+type FlowState struct {
+	Name        string           `yaml:"name"`
+	Enter       []Action         `yaml:"enter"`
+	Actions     []Action         `yaml:"actions"`
+	Exit        []Action         `yaml:"exit"`
+	Transitions []FlowTransition `yaml:"transitions"`
+	Final       bool             `yaml:"final"` // 进入后结束流程
+}
+
+// FlowTransition 转换, when中的条件全部满足时切换, 不写条件则直接切换
+type FlowTransition struct {
+	To   string    `yaml:"to"`
+	When Condition `yaml:"when"`
+}
+
+// Action 一个操作, 每项只填一个字段
+type Action struct {
+	Move        *Point `yaml:"move"`
+	Click       string `yaml:"click"`        // left/right/center
+	DoubleClick string `yaml:"double_click"` // left/right/center
+	Type        string `yaml:"type"`
+	Key         string `yaml:"key"` // 如 enter、ctrl+s
+	Wait        string `yaml:"wait"`
+}
+
+type Point struct {
+	X int `yaml:"x"`
+	Y int `yaml:"y"`
+}
+
+// Region 矩形区域, 包含边界
+type Region struct {
+	X1 int `yaml:"x1"`
+	Y1 int `yaml:"y1"`
+	X2 int `yaml:"x2"`
+	Y2 int `yaml:"y2"`
+}
+
+func (r Region) Contains(x, y int) bool {
+	return x >= r.X1 && x <= r.X2 && y >= r.Y1 && y <= r.Y2
+}
+
+type Pixel struct {
+	X     int    `yaml:"x"`
+	Y     int    `yaml:"y"`
+	Color string `yaml:"color"`
+}
+
+type Condition struct {
+	Cursor  *Region `yaml:"cursor"`  // 鼠标在区域内
+	Pixel   *Pixel  `yaml:"pixel"`   // 坐标颜色一致
+	Elapsed string  `yaml:"elapsed"` // 进入当前状态超过多久
+}
+
+// LoadFlow 读取流程文件
+func LoadFlow(filePath string) (*Flow, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+
+	flow := &Flow{}
+	if err := yaml.Unmarshal(data, flow); err != nil {
+		return nil, fmt.Errorf("解析 YAML 失败: %w", err)
+	}
+	if err := flow.validate(); err != nil {
+		return nil, err
+	}
+
+	return flow, nil
+}
+
+func (flow *Flow) validate() error {
+	if len(flow.States) == 0 {
+		return errors.New("flow has no states")
+	}
+	if _, err := parseFlowDuration(flow.Interval); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	for _, state := range flow.States {
+		if state.Name == "" {
+			return errors.New("state name is empty")
+		}
+		if names[state.Name] {
+			return errors.New("duplicate state: " + state.Name)
+		}
+		names[state.Name] = true
+	}
+	if flow.Start != "" && !names[flow.Start] {
+		return errors.New("start state not found: " + flow.Start)
+	}
+
+	for _, state := range flow.States {
+		for _, action := range append(append(append([]Action{}, state.Enter...), state.Actions...), state.Exit...) {
+			if _, err := parseFlowDuration(action.Wait); err != nil {
+				return fmt.Errorf("state %s: %w", state.Name, err)
+			}
+		}
+		for _, trans := range state.Transitions {
+			if !names[trans.To] {
+				return fmt.Errorf("state %s: transition to unknown state %s", state.Name, trans.To)
+			}
+			if _, err := parseFlowDuration(trans.When.Elapsed); err != nil {
+				return fmt.Errorf("state %s: %w", state.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Build 根据流程创建状态机, 进入final状态时调用done
+func (flow *Flow) Build(driver Driver, done func()) (*FSM, StatusNode, error) {
+	if err := flow.validate(); err != nil {
+		return nil, StatusNode{}, err
+	}
+	interval, _ := parseFlowDuration(flow.Interval)
+	fsm := NewFSM(interval)
+
+	for _, state := range flow.States {
+		state := state
+		node := NewStatus(state.Name)
+		fsm.RegisterStatus(node, func() {
+			runActions(driver, state.Actions)
+		})
+		fsm.OnEnter(node, func() {
+			runActions(driver, state.Enter)
+			if state.Final && done != nil {
+				done()
+			}
+		})
+		fsm.OnExit(node, func() {
+			runActions(driver, state.Exit)
+		})
+		for _, trans := range state.Transitions {
+			when := trans.When
+			fsm.AddTransition(node, NewStatus(trans.To), func() bool {
+				return when.Match(driver, fsm.Elapsed())
+			})
+		}
+	}
+
+	start := flow.Start
+	if start == "" {
+		start = flow.States[0].Name
+	}
+	return fsm, NewStatus(start), nil
+}
+
+// RunFlow 运行流程直到进入final状态或ctx取消
+func RunFlow(ctx context.Context, flow *Flow, driver Driver) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	fsm, start, err := flow.Build(driver, cancel)
+	if err != nil {
+		return err
+	}
+	if err := fsm.Run(ctx, start); err != nil && err != context.Canceled {
+		return err
+	}
+	return nil
+}
+
+// Match 条件是否全部满足
+func (c Condition) Match(driver Driver, elapsed time.Duration) bool {
+	if c.Cursor != nil {
+		x, y := driver.Location()
+		if !c.Cursor.Contains(x, y) {
+			return false
+		}
+	}
+	if c.Pixel != nil {
+		color := driver.PixelColor(c.Pixel.X, c.Pixel.Y)
+		if !strings.EqualFold(strings.TrimPrefix(color, "#"), strings.TrimPrefix(c.Pixel.Color, "#")) {
+			return false
+		}
+	}
+	if c.Elapsed != "" {
+		duration, _ := parseFlowDuration(c.Elapsed)
+		if elapsed < duration {
+			return false
+		}
+	}
+	return true
+}
+
+func runActions(driver Driver, actions []Action) {
+	for _, action := range actions {
+		action.Do(driver)
+	}
+}
+
+// Do 执行操作
+func (a Action) Do(driver Driver) {
+	if a.Move != nil {
+		driver.Move(a.Move.X, a.Move.Y)
+	}
+	if a.Click != "" {
+		driver.Click(a.Click, false)
+	}
+	if a.DoubleClick != "" {
+		driver.Click(a.DoubleClick, true)
+	}
+	if a.Type != "" {
+		driver.Type(a.Type)
+	}
+	if a.Key != "" {
+		keys := strings.Split(a.Key, "+")
+		driver.KeyTap(keys[len(keys)-1], keys[:len(keys)-1]...)
+	}
+	if a.Wait != "" {
+		duration, _ := parseFlowDuration(a.Wait)
+		driver.Sleep(duration)
+	}
+}
+
+func parseFlowDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(value)
+}
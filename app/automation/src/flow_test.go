@@ -0,0 +1,103 @@
+package src
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testFlow = `
+name: save
+start: Idle
+interval: 1ms
+states:
+  - name: Idle
+    transitions:
+      - to: Save
+        when: {cursor: {x1: 1000, y1: 0, x2: 1920, y2: 1080}}
+  - name: Save
+    enter:
+      - move: {x: 1200, y: 30}
+      - click: left
+      - key: ctrl+s
+      - type: report
+      - wait: 500ms
+    transitions:
+      - to: Done
+        when: {pixel: {x: 10, y: 10, color: "#FFFFFF"}}
+  - name: Done
+    final: true
+`
+
+func loadTestFlow(t *testing.T, content string) *Flow {
+	path := filepath.Join(t.TempDir(), "flow.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	flow, err := LoadFlow(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return flow
+}
+
+func TestFlowStep(t *testing.T) {
+	flow := loadTestFlow(t, testFlow)
+	driver := NewFakeDriver()
+	done := false
+	fsm, start, err := flow.Build(driver, func() { done = true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fsm.Start(start); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = fsm.Step()
+	if fsm.Current().Name() != "Idle" {
+		t.Fatalf("want Idle, got %s", fsm.Current().Name())
+	}
+
+	driver.X = 1500
+	_, _ = fsm.Step()
+	if fsm.Current().Name() != "Save" {
+		t.Fatalf("want Save, got %s", fsm.Current().Name())
+	}
+	want := []string{"move 1200,30", "click left", "key ctrl+s", "type report", "wait 500ms"}
+	if !reflect.DeepEqual(driver.Actions, want) {
+		t.Fatalf("actions = %v, want %v", driver.Actions, want)
+	}
+
+	driver.Pixels[[2]int{10, 10}] = "ffffff"
+	_, _ = fsm.Step()
+	if fsm.Current().Name() != "Done" || !done {
+		t.Fatalf("want Done, got %s", fsm.Current().Name())
+	}
+}
+
+func TestRunFlowStopsAtFinal(t *testing.T) {
+	flow := loadTestFlow(t, testFlow)
+	driver := NewFakeDriver()
+	driver.X = 1500
+	driver.Pixels[[2]int{10, 10}] = "FFFFFF"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := RunFlow(ctx, flow, driver); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("flow did not stop at final state")
+	}
+}
+
+func TestLoadFlowInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.yaml")
+	_ = os.WriteFile(path, []byte("states:\n  - name: A\n    transitions:\n      - to: B\n"), 0644)
+	if _, err := LoadFlow(path); err == nil {
+		t.Fatal("transition to unknown state should fail")
+	}
+}
@@ -0,0 +1,186 @@
+package src
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	EventMove  = "move"
+	EventClick = "click"
+	EventKey   = "key"
+)
+
+// InputEvent 一次输入, Offset为相对录制开始的时间
+type InputEvent struct {
+	Offset    time.Duration `json:"offset"`
+	Time      time.Time     `json:"-"` // 输入源产生事件的时间, 录制时换算成Offset
+	Kind      string        `json:"kind"`
+	X         int           `json:"x,omitempty"`
+	Y         int           `json:"y,omitempty"`
+	Button    string        `json:"button,omitempty"`
+	Double    bool          `json:"double,omitempty"`
+	Key       string        `json:"key,omitempty"`
+	Modifiers []string      `json:"modifiers,omitempty"`
+}
+
+// Timeline 录制结果
+type Timeline struct {
+	Name   string       `json:"name"`
+	Events []InputEvent `json:"events"`
+}
+
+// InputSource 输入来源, Start后持续发送事件, ctx取消后关闭通道
+type InputSource interface {
+	Start(ctx context.Context) (<-chan InputEvent, error)
+}
+
+// SliceSource 按顺序发送固定事件, 用于测试
+type SliceSource []InputEvent
+
+func (s SliceSource) Start(ctx context.Context) (<-chan InputEvent, error) {
+	events := make(chan InputEvent)
+	go func() {
+		defer close(events)
+		for _, event := range s {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// Recorder 录制输入
+type Recorder struct {
+	Source  InputSource
+	MinMove int // 与上一个位置距离小于该值的移动不记录
+}
+
+// Record 录制直到ctx取消或输入源结束
+func (r *Recorder) Record(ctx context.Context, name string) (*Timeline, error) {
+	events, err := r.Source.Start(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := &Timeline{Name: name}
+	var start time.Time
+	lastX, lastY, moved := 0, 0, false
+	for event := range events {
+		if event.Time.IsZero() {
+			event.Time = time.Now()
+		}
+		if start.IsZero() {
+			start = event.Time
+		}
+		if event.Kind == EventMove {
+			if moved && abs(event.X-lastX) < r.MinMove && abs(event.Y-lastY) < r.MinMove {
+				continue
+			}
+			lastX, lastY, moved = event.X, event.Y, true
+		}
+		event.Offset = event.Time.Sub(start)
+		timeline.Events = append(timeline.Events, event)
+	}
+
+	return timeline, nil
+}
+
+// SaveTimeline 保存录制文件
+func SaveTimeline(filePath string, timeline *Timeline) error {
+	data, err := json.MarshalIndent(timeline, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
+
+// LoadTimeline 读取录制文件
+func LoadTimeline(filePath string) (*Timeline, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	timeline := &Timeline{}
+	if err := json.Unmarshal(data, timeline); err != nil {
+		return nil, fmt.Errorf("解析录制文件失败: %w", err)
+	}
+	return timeline, nil
+}
+
+// Player 回放录制的输入
+type Player struct {
+	Driver Driver
+	Speed  float64 // 回放速度倍数, 2表示两倍速, <=0按1处理
+	Loops  int     // 回放次数, 0按1处理, 小于0一直循环直到ctx取消
+}
+
+// Play 回放
+func (p *Player) Play(ctx context.Context, timeline *Timeline) error {
+	speed := p.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	loops := p.Loops
+	if loops == 0 {
+		loops = 1
+	}
+
+	if len(timeline.Events) == 0 {
+		return nil
+	}
+
+	for i := 0; loops < 0 || i < loops; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var last time.Duration
+		for _, event := range timeline.Events {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if wait := event.Offset - last; wait > 0 {
+				if err := p.sleep(ctx, time.Duration(float64(wait)/speed)); err != nil {
+					return err
+				}
+			}
+			last = event.Offset
+			p.apply(event)
+		}
+	}
+
+	return nil
+}
+
+func (p *Player) sleep(ctx context.Context, duration time.Duration) error {
+	if sleeper, ok := p.Driver.(ContextSleeper); ok {
+		return sleeper.SleepContext(ctx, duration)
+	}
+	p.Driver.Sleep(duration)
+	return ctx.Err()
+}
+
+func (p *Player) apply(event InputEvent) {
+	switch event.Kind {
+	case EventMove:
+		p.Driver.Move(event.X, event.Y)
+	case EventClick:
+		p.Driver.Move(event.X, event.Y)
+		p.Driver.Click(event.Button, event.Double)
+	case EventKey:
+		p.Driver.KeyTap(event.Key, event.Modifiers...)
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,91 @@
+package src
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordAndPlay(t *testing.T) {
+	start := time.Unix(100, 0)
+	source := SliceSource{
+		{Time: start, Kind: EventMove, X: 10, Y: 10},
+		{Time: start.Add(100 * time.Millisecond), Kind: EventMove, X: 12, Y: 11}, // 距离太小, 丢弃
+		{Time: start.Add(200 * time.Millisecond), Kind: EventClick, X: 50, Y: 60, Button: "left"},
+		{Time: start.Add(1200 * time.Millisecond), Kind: EventKey, Key: "s", Modifiers: []string{"ctrl"}},
+	}
+	recorder := &Recorder{Source: source, MinMove: 5}
+	timeline, err := recorder.Record(context.Background(), "save")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(timeline.Events) != 3 || timeline.Events[2].Offset != 1200*time.Millisecond {
+		t.Fatalf("unexpected timeline %+v", timeline.Events)
+	}
+
+	path := filepath.Join(t.TempDir(), "timeline.json")
+	if err := SaveTimeline(path, timeline); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadTimeline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	driver := NewFakeDriver()
+	player := &Player{Driver: driver, Speed: 2, Loops: 2}
+	if err := player.Play(context.Background(), loaded); err != nil {
+		t.Fatal(err)
+	}
+	once := []string{"move 10,10", "wait 100ms", "move 50,60", "click left", "wait 500ms", "key ctrl+s"}
+	want := append(append([]string{}, once...), once...)
+	if !reflect.DeepEqual(driver.Actions, want) {
+		t.Fatalf("actions = %v, want %v", driver.Actions, want)
+	}
+}
+
+func TestPlayCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	player := &Player{Driver: NewFakeDriver(), Loops: -1}
+	timeline := &Timeline{Events: []InputEvent{{Kind: EventMove}}}
+	if err := player.Play(ctx, timeline); err != context.Canceled {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestPlayEmptyLoop(t *testing.T) {
+	player := &Player{Driver: NewFakeDriver(), Loops: -1}
+	done := make(chan error, 1)
+	go func() { done <- player.Play(context.Background(), &Timeline{}) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("空的录制循环回放没有返回")
+	}
+}
+
+// 等待中取消时立即返回
+type blockingDriver struct {
+	*FakeDriver
+}
+
+func (d blockingDriver) SleepContext(ctx context.Context, duration time.Duration) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestPlayCancelSleep(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	player := &Player{Driver: blockingDriver{NewFakeDriver()}, Loops: -1}
+	timeline := &Timeline{Events: []InputEvent{{Kind: EventMove}, {Offset: time.Hour, Kind: EventMove}}}
+	if err := player.Play(ctx, timeline); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build automation
+
+package main
+
+import (
+	"raselper/app/automation/robot"
+	"raselper/app/automation/src"
+)
+
+// robotgo依赖cgo和X11开发库, 需要 go build -tags automation 才带桌面自动化
+func automationBackend() (src.Driver, src.InputSource, error) {
+	return robot.NewDriver(), robot.NewHookSource(), nil
+}
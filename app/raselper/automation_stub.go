@@ -0,0 +1,12 @@
+//go:build !automation
+
+package main
+
+import (
+	"errors"
+	"raselper/app/automation/src"
+)
+
+func automationBackend() (src.Driver, src.InputSource, error) {
+	return nil, nil, errors.New("automation requires building with -tags automation (robotgo, cgo)")
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"raselper/app/automation"
 	"raselper/app/component/filehelper"
 	"raselper/app/component/md5"
 	"strings"
@@ -59,5 +60,14 @@ func main() {
 		if err := filehelper.Run(args); err != nil {
 			fmt.Println(err)
 		}
+	case "automation":
+		driver, source, err := automationBackend()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if err := automation.Run(args, driver, source); err != nil {
+			fmt.Println(err)
+		}
 	}
 }
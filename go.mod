@@ -7,6 +7,7 @@ require (
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/go-vgo/robotgo v0.110.0
 	github.com/godoes/gorm-dameng v0.7.2
+	github.com/robotn/gohook v0.41.0
 	golang.org/x/text v0.29.0
 	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/driver/mysql v1.6.0
@@ -0,0 +1,96 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"raselper/src/first/component"
+	"raselper/src/secondary/watch"
+	"strings"
+	"syscall"
+)
+
+// InstanceWatch 监听目录, 新文件稳定后执行配置的raseper命令
+// watch <watch.yaml>
+type InstanceWatch struct {
+	component.Instance
+	Dispatch func(args []string) bool // 执行一条raseper命令, 没有匹配的组件时返回false
+}
+
+func (r InstanceWatch) SelectComponent(args []string) bool {
+	return args[1] == "watch"
+}
+
+func (r InstanceWatch) Run(args []string) {
+	configPath := "watch.yaml"
+	if len(args) > 2 {
+		configPath = args[2]
+	}
+	config, err := watch.LoadConfig(configPath)
+	if err != nil {
+		panic(err)
+	}
+
+	watcher, err := watch.NewWatcher(config, func(rule watch.Rule, file string) error {
+		return r.runRule(args[0], rule, file)
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Println("watch start:", configPath)
+	_ = watcher.Run(ctx)
+	log.Println("watch stop")
+}
+
+// 依次执行规则中的命令, 任意一条失败就停止
+func (r InstanceWatch) runRule(program string, rule watch.Rule, file string) error {
+	lines := rule.Command
+	if rule.Script != "" {
+		content, err := os.ReadFile(rule.Script)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, strings.Split(string(content), "\n")...)
+	}
+
+	replacer := strings.NewReplacer(
+		"{file}", file,
+		"{dir}", filepath.Dir(file),
+		"{name}", filepath.Base(file),
+	)
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// 先分割再替换, 路径中有空格也不会被拆开
+		fields := strings.Fields(line)
+		for i, field := range fields {
+			fields[i] = replacer.Replace(field)
+		}
+		if err := r.dispatch(append([]string{program}, fields...)); err != nil {
+			return fmt.Errorf("%s: %w", line, err)
+		}
+	}
+
+	return nil
+}
+
+// 组件出错时会panic, 这里转成error; 没有匹配的组件也是错误
+func (r InstanceWatch) dispatch(args []string) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("%v", e)
+		}
+	}()
+	if !r.Dispatch(args) {
+		return fmt.Errorf("未知命令: %s", args[1])
+	}
+	return nil
+}
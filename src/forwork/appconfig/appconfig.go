@@ -0,0 +1,47 @@
+// Package appconfig 只读工具(topo, model)共用的app.yaml, 格式与handle_topo相同
+package appconfig
+
+import (
+	"fmt"
+	"os"
+
+	dameng "github.com/godoes/gorm-dameng"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+type Config struct {
+	Owner  string   `yaml:"owner"`
+	Feeder string   `yaml:"feeder"` // 逗号分隔; topo check为空时检查owner下所有馈线, model export只能一条
+	DB     DBConfig `yaml:"db"`
+}
+
+type DBConfig struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Port     string `yaml:"port"`
+	IP       string `yaml:"ip"`
+	Database string `yaml:"database"`
+}
+
+// 读取配置, owner不能为空
+func Read(filePath string) (*Config, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("解析 YAML 失败: %v", err)
+	}
+	if config.Owner == "" {
+		return nil, fmt.Errorf("owner 不能为空")
+	}
+	return config, nil
+}
+
+// 连接达梦数据库
+func OpenDB(config DBConfig) (*gorm.DB, error) {
+	dsn := fmt.Sprintf("dm://%s:%s@%s:%s", config.Username, config.Password, config.IP, config.Port)
+	return gorm.Open(dameng.Open(dsn), &gorm.Config{})
+}
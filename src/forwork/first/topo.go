@@ -2,27 +2,25 @@ package first
 
 import (
 	"fmt"
+	"raselper/src/secondary/topology"
 )
 
+// 连通分量, 以分量中第一个设备为key; 没有连接节点的设备不在结果中
 func BuildGraph(topoList []Topo) map[string][]string {
-	topoMap := make(map[string][]string)
-	visited := make(map[string]bool) // 已经击中的id
-	idMap, nodeMap, _, _, _ := GetNodeIDMap(topoList)
-
 	if len(topoList) == 0 {
 		fmt.Printf("❌ 无拓扑数据，无法构建图\n")
 	}
 
+	return NewTopoGraph(topoList).Groups()
+}
+
+// 用拓扑记录构建设备拓扑图
+func NewTopoGraph(topoList []Topo) *topology.Graph {
+	graph := topology.New()
 	for _, topo := range topoList {
-		connected := RecusionGraph(visited, idMap, nodeMap, topoMap, topo.ID)
-		if len(connected) > 0 {
-			for id := range connected {
-				topoMap[topo.ID] = append(topoMap[topo.ID], id)
-			}
-		}
+		graph.AddDevice(topo.ID, topo.FirstNodeID, topo.SecondNodeID)
 	}
-
-	return topoMap
+	return graph
 }
 
 func GetNodeIDMap(topoList []Topo) (map[string][]string, map[string][]string, map[string][]string, map[string]Topo, map[string][]Topo) {
@@ -83,28 +81,6 @@ func GetNodeIDMap(topoList []Topo) (map[string][]string, map[string][]string, ma
 	return idMap, nodeMap, idConnectMap, idEntityMap, duplicateTopoMap
 }
 
-func RecusionGraph(hitMap map[string]bool, idMap, nodeMap, topoMap map[string][]string, startTopo string) map[string]bool {
-	connected := make(map[string]bool)
-
-	// 是否已经查询到了
-	if hitMap[startTopo] {
-		return connected
-	}
-	hitMap[startTopo] = true // 标记查询状态
-
-	for _, node := range idMap[startTopo] {
-		for _, topo := range nodeMap[node] {
-			connected[topo] = true
-			subconnected := RecusionGraph(hitMap, idMap, nodeMap, topoMap, topo)
-			for id := range subconnected {
-				connected[id] = true
-			}
-		}
-	}
-
-	return connected
-}
-
 func Contain(list []string, str string) bool {
 	for _, s := range list {
 		if s == str {
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 执行前的节点和执行的修改, 用于回退
+type BeforeImage struct {
+	Owner    string       `json:"owner"`
+	FeederID string       `json:"feeder_id"`
+	Saved    time.Time    `json:"saved"`
+	Rows     []Topo       `json:"rows"`
+	Changes  []NodeChange `json:"changes"`
+}
+
+func topoTable() string {
+	return config.DB.Database + ".SG_CON_DPWRGRID_R_TOPO"
+}
+
+// 计划文件名: <owner>_<feeder>.json
+func planFile(dir string, plan *RepairPlan) string {
+	name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(plan.Owner + "_" + plan.FeederID)
+	return filepath.Join(dir, name+".json")
+}
+
+// 执行前数据文件名: <计划文件名>.<执行时间>.before.json, 多次执行不互相覆盖
+func beforeFile(planFile string, now time.Time) string {
+	return strings.TrimSuffix(planFile, ".json") + "." + now.Format("20060102-150405.000") + ".before.json"
+}
+
+func savePlan(file string, plan *RepairPlan) error {
+	return writeJSON(file, plan)
+}
+
+func loadPlan(file string) (*RepairPlan, error) {
+	var plan RepairPlan
+	return &plan, readJSON(file, &plan)
+}
+
+func writeJSON(file string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+func readJSON(file string, v interface{}) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// 在一个事务中执行一条馈线的计划
+//
+// 先把涉及设备的原始节点写到临时文件, 每条修改都要求当前值等于计划中的旧值,
+// 计划生成后数据被改过时整体回滚; 提交后临时文件才改名为beforeFile,
+// 回滚时不会覆盖上一次执行保存的beforeFile。未收敛的计划需要force
+func applyPlan(db *gorm.DB, plan *RepairPlan, beforeFile string, force bool) error {
+	if !plan.Converged && !force {
+		return fmt.Errorf("%s/%s 计划未收敛: %s", plan.Owner, plan.FeederID, plan.Message)
+	}
+	if len(plan.Changes) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(plan.Changes))
+	seen := make(map[string]bool)
+	for _, change := range plan.Changes {
+		if change.Column != columnFirst && change.Column != columnSecond {
+			return fmt.Errorf("设备 %s: 不支持修改列 %s", change.ID, change.Column)
+		}
+		if !seen[change.ID] {
+			seen[change.ID] = true
+			ids = append(ids, change.ID)
+		}
+	}
+
+	tmpFile := beforeFile + ".tmp"
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var before []Topo
+		if err := tx.Table(topoTable()).
+			Where("OWNER = ? AND FEEDER_ID = ? AND ID IN ?", plan.Owner, plan.FeederID, ids).
+			Find(&before).Error; err != nil {
+			return err
+		}
+		image := BeforeImage{Owner: plan.Owner, FeederID: plan.FeederID, Saved: time.Now(), Rows: before, Changes: plan.Changes}
+		if err := writeJSON(tmpFile, image); err != nil {
+			return fmt.Errorf("保存执行前数据失败: %w", err)
+		}
+
+		for _, change := range plan.Changes {
+			query, args := nodeEquals(change.Column, change.Old)
+			result := tx.Table(topoTable()).
+				Where("OWNER = ? AND FEEDER_ID = ? AND ID = ?", plan.Owner, plan.FeederID, change.ID).
+				Where(query, args...).
+				Update(change.Column, change.New)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("设备 %s 的 %s 已不是 %q, 计划已过期", change.ID, change.Column, change.Old)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	if err := os.Rename(tmpFile, beforeFile); err != nil {
+		return fmt.Errorf("已提交, 但保存执行前数据失败, 回退请使用 %s: %w", tmpFile, err)
+	}
+	return nil
+}
+
+// 空节点在库中可能是NULL
+func nodeEquals(column, value string) (string, []interface{}) {
+	if value == "" {
+		return fmt.Sprintf("(%s IS NULL OR %s = '')", column, column), nil
+	}
+	return column + " = ?", []interface{}{value}
+}
+
+// 在一个事务中按相反顺序撤销执行的修改
+//
+// 与执行时一样, 每条要求当前值仍是计划中的新值, 执行后数据被改过时整体回滚
+func revertPlan(db *gorm.DB, image *BeforeImage) error {
+	if len(image.Changes) == 0 && len(image.Rows) > 0 {
+		return fmt.Errorf("%s/%s 执行前数据没有记录执行的修改, 无法校验当前值", image.Owner, image.FeederID)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i := len(image.Changes) - 1; i >= 0; i-- {
+			change := image.Changes[i]
+			if change.Column != columnFirst && change.Column != columnSecond {
+				return fmt.Errorf("设备 %s: 不支持修改列 %s", change.ID, change.Column)
+			}
+			query, args := nodeEquals(change.Column, change.New)
+			result := tx.Table(topoTable()).
+				Where("OWNER = ? AND FEEDER_ID = ? AND ID = ?", image.Owner, image.FeederID, change.ID).
+				Where(query, args...).
+				Update(change.Column, change.Old)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("设备 %s 的 %s 已不是 %q, 执行后数据被修改过, 不能回退", change.ID, change.Column, change.New)
+			}
+		}
+		return nil
+	})
+}
@@ -4,7 +4,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
-	"sort"
+	"os"
+	"path/filepath"
+	"raselper/src/forwork/first"
+	"raselper/src/secondary/nodeid"
+	"strings"
 	"time"
 
 	dameng "github.com/godoes/gorm-dameng"
@@ -15,17 +19,7 @@ import (
 var config Config
 
 // 拓扑连接关系表结构
-type Topo struct {
-	D             string `gorm:"column:D"`
-	EffectiveTime string `gorm:"column:EFFECTIVE_TIME"`
-	ExpiryTime    string `gorm:"column:EXPIRY_TIME"`
-	FeederID      string `gorm:"column:FEEDER_ID"`
-	FirstNodeID   string `gorm:"column:FIRST_NODE_ID"`
-	ID            string `gorm:"column:ID"`
-	Owner         string `gorm:"column:OWNER"`
-	SecondNodeID  string `gorm:"column:SECOND_NODE_ID"`
-	Stamp         string `gorm:"column:STAMP"`
-}
+type Topo = first.Topo
 
 // 分组信息
 type GroupInfo struct {
@@ -42,9 +36,13 @@ type DisconnectedTopoGroup struct {
 
 // Config 结构体用于映射 yaml 配置
 type Config struct {
-	Owner  string   `yaml:"owner"`
-	Feeder string   `yaml:"feeder"`
-	DB     DBConfig `yaml:"db"`
+	Owner         string        `yaml:"owner"`
+	Feeder        string        `yaml:"feeder"`
+	DB            DBConfig      `yaml:"db"`
+	PlanDir       string        `yaml:"plan-dir"`       // 修复计划和执行前数据的目录, 默认 topo_plan
+	MaxIterations int           `yaml:"max-iterations"` // 连接孤立岛的最大轮数, 默认10
+	MaxDegree     int           `yaml:"max-degree"`     // 节点最多连接的设备数, 默认7
+	NodeID        nodeid.Config `yaml:"node-id"`        // 新节点ID分配, 未配置时使用号段表 <database>.NODE_ID_RANGE
 }
 type DBConfig struct {
 	Username string `yaml:"username"`
@@ -68,10 +66,43 @@ func ReadAppConfig(filePath string) (*Config, error) {
 		return nil, fmt.Errorf("解析 YAML 失败: %v", err)
 	}
 
+	if config.PlanDir == "" {
+		config.PlanDir = "topo_plan"
+	}
+	if config.MaxIterations <= 0 {
+		config.MaxIterations = 10
+	}
+	if config.MaxDegree <= 0 {
+		config.MaxDegree = 7
+	}
+	if config.NodeID.Sequence == "" && config.NodeID.Table == "" {
+		config.NodeID.Table = config.DB.Database + ".NODE_ID_RANGE"
+	}
 	return &config, nil
 }
 
+// 用法: handle_topo [plan|apply|revert|run] [-force] [文件或目录]
+//
+//	plan    计算各馈线的修复计划, 写入plan-dir, 不修改数据库
+//	apply   执行plan-dir或指定的计划文件, 每条馈线一个事务, 执行前数据保存为 *.<执行时间>.before.json
+//	revert  用 *.before.json 撤销, 当前值不是计划的新值时不回退
+//	run     计算后直接执行, 默认
+//
+// 未收敛的计划不执行, 除非指定 -force
 func main() {
+	mode, target, force := "run", "", false
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == "plan" || args[0] == "apply" || args[0] == "revert" || args[0] == "run") {
+		mode, args = args[0], args[1:]
+	}
+	for _, arg := range args {
+		if arg == "-force" {
+			force = true
+		} else {
+			target = arg
+		}
+	}
+
 	config, err := ReadAppConfig("app.yaml")
 	if err != nil {
 		log.Fatalf("读取配置失败: %v", err)
@@ -87,18 +118,30 @@ func main() {
 		log.Fatal("连接数据库失败:", err)
 	}
 
-	// 计算不相连的拓扑组
-	err = CalculateAndPrintTopoGroupsSequentially(db)
+	switch mode {
+	case "plan":
+		err = planAll(db)
+	case "apply":
+		if target == "" {
+			target = config.PlanDir
+		}
+		err = applyFiles(db, target, force)
+	case "revert":
+		if target == "" {
+			log.Fatal("revert 需要指定 *.before.json 文件")
+		}
+		err = revertFile(db, target)
+	default:
+		err = runAll(db, force)
+	}
 	if err != nil {
-		log.Fatal("计算拓扑组失败:", err)
+		log.Fatal(err)
 	}
 }
 
-// 逐个分组计算并打印结果
-func CalculateAndPrintTopoGroupsSequentially(db *gorm.DB) error {
-	// 首先获取所有唯一的分组（owner + feederID）
+func queryGroups(db *gorm.DB) ([]GroupInfo, error) {
 	var groups []GroupInfo
-	tx := db.Table(config.DB.Database + ".SG_CON_DPWRGRID_R_TOPO").
+	tx := db.Table(topoTable()).
 		Select("DISTINCT OWNER, FEEDER_ID").
 		Order("OWNER, FEEDER_ID")
 	tx.Where("OWNER = ?", config.Owner)
@@ -106,218 +149,147 @@ func CalculateAndPrintTopoGroupsSequentially(db *gorm.DB) error {
 		tx.Where("FEEDER_ID in (" + config.Feeder + ")")
 	}
 	result := tx.Find(&groups)
-	if result.Error != nil {
-		return result.Error
-	}
+	return groups, result.Error
+}
 
+// 逐个分组计算修复计划
+func buildPlans(db *gorm.DB, visit func(plan *RepairPlan) error) error {
+	groups, err := queryGroups(db)
+	if err != nil {
+		return err
+	}
 	fmt.Printf("=== 找到 %d 个分组需要处理 ===\n\n", len(groups))
 
-	// 逐个分组处理
+	nodeIDs, err := nodeid.New(db, config.NodeID)
+	if err != nil {
+		return err
+	}
+	options := planOptions{maxIterations: config.MaxIterations, maxDegree: config.MaxDegree, nodeIDs: nodeIDs}
 	for i, group := range groups {
 		fmt.Printf("🚀 处理分组 %d/%d: Owner=%s, FeederID=%s\n",
 			i+1, len(groups), group.Owner, group.FeederID)
-
-		{
-			fmt.Println("处理没有拓扑节点的..")
-			// 处理没有拓扑节点的
-			handleNoneTopo(db, group)
-		}
-		// 处理孤立岛
-		fmt.Println("处理孤立岛...")
-		graph := make(map[string][]string)
-		for len(graph) != 1 {
-			// 刷新数据
-			topoList := queryTopoData(db, group.Owner, group.FeederID)
-			fmt.Printf("   该分组有 %d 条拓扑记录\n", len(topoList))
-			if len(topoList) == 0 {
-				fmt.Printf("   ⚠️  该分组没有拓扑数据，跳过处理\n\n")
-				continue
-			}
-			// 构建图并计算连通分量
-			graph = buildGraph(topoList)
-			for key, connected := range graph {
-				fmt.Println(key, ": ", len(connected))
-			}
-			connnectTopo(topoList, graph, db) // 连接topo
+		topoList := queryTopoData(db, group.Owner, group.FeederID)
+		if len(topoList) == 0 {
+			fmt.Printf("   ⚠️  该分组没有拓扑数据，跳过处理\n\n")
+			continue
 		}
-		// 处理拓扑异常
-		fmt.Println("处理拓扑异常...")
-		{
-			topoList := queryTopoData(db, group.Owner, group.FeederID)
-			_, nodeMap, idConnect, idEntityMap, _ := GetNodeIDMap(topoList)
-			handleDupliConnnect(db, nodeMap, idConnect, idEntityMap)
+		plan, err := buildRepairPlan(group.Owner, group.FeederID, topoList, options)
+		if err != nil {
+			return err
 		}
-		//connectedComponents := findConnectedComponentsWithUnionFind(graph)
-		// 处理重复拓扑节点
-		fmt.Println("处理重复拓扑节点...")
-		{
-			topoList := queryTopoData(db, group.Owner, group.FeederID)
-			handleDupliTopo(topoList, db)
+		printPlan(plan)
+		if err := visit(plan); err != nil {
+			return err
 		}
-
-		// 打印当前分组的结果
-		//printGroupResult(group.Owner, group.FeederID, connectedComponents)
-
 		fmt.Println() // 空行分隔不同分组
 	}
-
-	fmt.Printf("✅ 所有分组处理完成！\n")
 	return nil
 }
 
-func handleNoneTopo(db *gorm.DB, group GroupInfo) {
-	var waitConnectedTopo Topo
-	topoList := queryTopoData(db, group.Owner, group.FeederID)
+func printPlan(plan *RepairPlan) {
+	counts := make(map[string]int)
+	for _, change := range plan.Changes {
+		counts[change.Step]++
+	}
+	fmt.Printf("   %d 条拓扑记录, %d 处修改: 无节点 %d, 孤立岛 %d, 节点连接过多 %d, 重复拓扑 %d\n",
+		plan.Rows, len(plan.Changes), counts[stepNoneTopo], counts[stepIsland], counts[stepHighDegree], counts[stepDuplicate])
+	if !plan.Converged {
+		fmt.Printf("   ❌ 未收敛: %s\n", plan.Message)
+	}
+}
 
-	for _, topo := range topoList {
-		if topo.FirstNodeID != "" && topo.SecondNodeID != "" {
-			waitConnectedTopo = topo
-			break
-		}
+func planAll(db *gorm.DB) error {
+	err := buildPlans(db, func(plan *RepairPlan) error {
+		file := planFile(config.PlanDir, plan)
+		fmt.Printf("   计划已保存: %s\n", file)
+		return savePlan(file, plan)
+	})
+	if err == nil {
+		fmt.Printf("✅ 计划已生成, 审核后执行: handle_topo apply %s\n", config.PlanDir)
 	}
-	for _, topo := range topoList {
-		if topo.FirstNodeID == "" && topo.SecondNodeID == "" {
-			newId := "170135" + fmt.Sprintf("%d", time.Now().UnixMicro())
-			db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-				Where("ID = ?", topo.ID).
-				Updates(map[string]interface{}{
-					"FIRST_NODE_ID":  waitConnectedTopo.SecondNodeID,
-					"SECOND_NODE_ID": newId,
-				})
-			waitConnectedTopo.FirstNodeID = waitConnectedTopo.SecondNodeID
-			waitConnectedTopo.SecondNodeID = newId
+	return err
+}
+
+// 计算后直接执行, 计划和执行前数据同样保存在plan-dir中
+func runAll(db *gorm.DB, force bool) error {
+	err := buildPlans(db, func(plan *RepairPlan) error {
+		file := planFile(config.PlanDir, plan)
+		if err := savePlan(file, plan); err != nil {
+			return err
 		}
+		return applyOne(db, plan, file, force)
+	})
+	if err == nil {
+		fmt.Printf("✅ 所有分组处理完成！\n")
 	}
+	return err
 }
 
-func handleDupliTopo(list []Topo, db *gorm.DB) {
-	_, _, _, _, duplicateTopoMap := GetNodeIDMap(list)
-	for _, topo := range duplicateTopoMap {
-		if len(topo) <= 1 {
-			continue
-		}
+func applyOne(db *gorm.DB, plan *RepairPlan, file string, force bool) error {
+	before := beforeFile(file, time.Now())
+	if err := applyPlan(db, plan, before, force); err != nil {
+		// 一条馈线失败不影响其他馈线
+		log.Printf("❌ 未执行: %v", err)
+		return nil
+	}
+	if len(plan.Changes) == 0 {
+		fmt.Printf("   %s/%s 无需修改\n", plan.Owner, plan.FeederID)
+		return nil
+	}
+	fmt.Printf("   ✅ %s/%s 已执行 %d 处修改, 回退: handle_topo revert %s\n", plan.Owner, plan.FeederID, len(plan.Changes), before)
+	if topoList := queryTopoData(db, plan.Owner, plan.FeederID); len(topoList) > 0 {
+		printGroupResult(plan.Owner, plan.FeederID, first.NewTopoGraph(topoList).Components())
+	}
+	return nil
+}
 
-		for _, t := range topo {
-			newNode := "170135" + fmt.Sprintf("%d", time.Now().UnixMicro())
-			if t.FirstNodeID == "" && t.SecondNodeID == "" {
-				continue
-			}
-			if t.FirstNodeID == "" {
-				db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-					Where("ID = ?", t.ID).
-					Updates(map[string]interface{}{"FIRST_NODE_ID": newNode})
-				fmt.Printf("   节点 %s 重复，已修改为 %s\n", t.ID, newNode)
-				continue
+// 执行一个计划文件或目录下的所有计划文件
+func applyFiles(db *gorm.DB, target string, force bool) error {
+	files := []string{target}
+	if info, err := os.Stat(target); err != nil {
+		return err
+	} else if info.IsDir() {
+		matches, err := filepath.Glob(filepath.Join(target, "*.json"))
+		if err != nil {
+			return err
+		}
+		files = files[:0]
+		for _, file := range matches {
+			if !strings.HasSuffix(file, ".before.json") {
+				files = append(files, file)
 			}
-			if t.SecondNodeID == "" {
-				db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-					Where("ID = ?", t.ID).
-					Updates(map[string]interface{}{"SECOND_NODE_ID": newNode})
-				fmt.Printf("   节点 %s 重复，已修改为 %s\n", t.ID, newNode)
-				continue
-			}
-			db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-				Where("ID = ?", t.ID).
-				Updates(map[string]interface{}{"SECOND_NODE_ID": newNode})
-			fmt.Printf("   节点 %s 重复，已修改为 %s\n", t.ID, newNode)
 		}
+	}
 
-		if topo[0].FirstNodeID == "" {
-			duplicateTopoMap[topo[0].FirstNodeID] = make([]Topo, 0)
+	for _, file := range files {
+		plan, err := loadPlan(file)
+		if err != nil {
+			return fmt.Errorf("读取计划 %s 失败: %w", file, err)
 		}
-		if topo[0].SecondNodeID == "" {
-			duplicateTopoMap[topo[0].SecondNodeID] = make([]Topo, 0)
+		fmt.Printf("执行计划 %s\n", file)
+		printPlan(plan)
+		if err := applyOne(db, plan, file, force); err != nil {
+			return err
 		}
-		duplicateTopoMap[topo[0].FirstNodeID+topo[0].SecondNodeID] = make([]Topo, 0)
-		duplicateTopoMap[topo[0].SecondNodeID+topo[0].FirstNodeID] = make([]Topo, 0)
 	}
+	return nil
 }
 
-func handleDupliConnnect(db *gorm.DB, nodeMap, topoConnectMap map[string][]string, entityMap map[string]Topo) {
-	for node, idList := range nodeMap {
-		idSet := make([]string, 0)
-		idSet = append(idSet)
-		for _, id := range idList {
-			if !Contain(idSet, entityMap[id].FirstNodeID) {
-				idSet = append(idSet, entityMap[id].FirstNodeID)
-			}
-			if !Contain(idSet, entityMap[id].SecondNodeID) {
-				idSet = append(idSet, entityMap[id].SecondNodeID)
-			}
-		}
-		if len(idList) > 7 {
-			fmt.Println(node, "存在多个节点相连的情况")
-		} else {
-			continue
-		}
-
-		var lastTopo Topo
-		for _, id := range idList[7:] {
-			if &lastTopo == nil {
-				if result := db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-					Where("ID = ?", id).
-					Find(&lastTopo); result.Error != nil {
-					log.Println(result.Error)
-				}
-				continue
-			}
-
-			newNode := "170135" + fmt.Sprintf("%d", time.Now().UnixMicro())
-			if lastTopo.FirstNodeID == node && lastTopo.SecondNodeID == "" {
-				db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-					Where("ID = ?", lastTopo.ID).
-					Updates(map[string]interface{}{
-						"SECOND_NODE_ID": newNode,
-					})
-				lastTopo.SecondNodeID = newNode
-			}
-			if lastTopo.SecondNodeID == node && lastTopo.FirstNodeID == "" {
-				db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-					Where("ID = ?", lastTopo.ID).
-					Updates(map[string]interface{}{
-						"FIRST_NODE_ID": newNode,
-					})
-				lastTopo.FirstNodeID = newNode
-			}
-			if lastTopo.FirstNodeID != "" && lastTopo.SecondNodeID != "" {
-				newNode = lastTopo.FirstNodeID
-				if newNode == node { // 是重复node
-					newNode = lastTopo.SecondNodeID
-				}
-			}
-
-			var currentTopo Topo
-			if result := db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-				Where("ID = ?", id).
-				Find(&currentTopo); result.Error != nil {
-				log.Println(result.Error)
-			}
-			if currentTopo.FirstNodeID == node {
-				db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-					Where("ID = ?", id).
-					Updates(map[string]interface{}{
-						"FIRST_NODE_ID": newNode,
-					})
-				currentTopo.FirstNodeID = newNode
-			}
-			if currentTopo.SecondNodeID == node {
-				db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-					Where("ID = ?", id).
-					Updates(map[string]interface{}{
-						"SECOND_NODE_ID": newNode,
-					})
-				currentTopo.SecondNodeID = newNode
-			}
-			lastTopo.ID = currentTopo.ID
-			lastTopo.FirstNodeID = currentTopo.FirstNodeID
-			lastTopo.SecondNodeID = currentTopo.SecondNodeID
-		}
+func revertFile(db *gorm.DB, file string) error {
+	var image BeforeImage
+	if err := readJSON(file, &image); err != nil {
+		return fmt.Errorf("读取执行前数据 %s 失败: %w", file, err)
 	}
+	if err := revertPlan(db, &image); err != nil {
+		return err
+	}
+	fmt.Printf("✅ %s/%s 已撤销 %d 处修改\n", image.Owner, image.FeederID, len(image.Changes))
+	return nil
 }
 
 func queryTopoData(db *gorm.DB, owner string, feederId string) []Topo {
 	var topoList []Topo
-	result := db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
+	result := db.Table(topoTable()).
 		Where("OWNER = ? AND FEEDER_ID = ?", owner, feederId).
 		Find(&topoList)
 	if result.Error != nil {
@@ -327,115 +299,6 @@ func queryTopoData(db *gorm.DB, owner string, feederId string) []Topo {
 	return topoList
 }
 
-func connnectTopo(topoList []Topo, graph map[string][]string, db *gorm.DB) {
-	_, nodeMap, idConnect, idEntityMap, _ := GetNodeIDMap(topoList)
-
-	if len(graph) == 1 {
-		// 没有孤立岛
-		return
-	}
-
-	var lastTopo *Topo
-	var lastTopoNode string
-	var lastKey string // 上一次循环的key
-	for startTopo, strings := range graph {
-		var endTopoNode string
-		if lastKey != "" { // 有上次的key, 找它的末端节点
-			topoKeys := graph[lastKey]
-			topoList := make([]Topo, 0)
-			for _, key := range topoKeys {
-				topo := idEntityMap[key]
-				topoList = append(topoList, topo)
-			}
-			if _, s, b := FindEndTopo(topoList); b {
-				endTopoNode = s
-			} else {
-				endTopoNode = lastTopoNode
-			}
-		}
-
-		for i, topo := range strings {
-			topoModel := idEntityMap[topo]
-			if topoModel.FirstNodeID == "" && topoModel.SecondNodeID == "" {
-				fmt.Printf("topo: %v\n", topoModel)
-				if lastTopoNode == "" { // 都空就更新
-					db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-						Where("ID = ?", topo).
-						Updates(map[string]interface{}{"FIRST_NODE_ID": lastTopoNode})
-					lastTopo.FirstNodeID = lastTopoNode
-				}
-			}
-			if lastTopo == nil && len(idConnect[topo]) < 5 {
-				t := idEntityMap[topo]
-				lastTopo = &t
-				if t.FirstNodeID == "" {
-					lastTopoNode = t.SecondNodeID
-				}
-				if t.SecondNodeID == "" {
-					lastTopoNode = t.FirstNodeID
-				}
-				break
-			}
-			if lastTopo == nil && (topoModel.FirstNodeID == "" || topoModel.SecondNodeID == "") {
-				t := topoModel
-				lastTopo = &t
-				if t.FirstNodeID == "" {
-					lastTopoNode = t.SecondNodeID
-				}
-				if t.SecondNodeID == "" {
-					lastTopoNode = t.FirstNodeID
-				}
-				if lastTopoNode == "" { // 都空就更新
-					db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-						Where("ID = ?", topo).
-						Updates(map[string]interface{}{"FIRST_NODE_ID": idEntityMap[startTopo].FirstNodeID})
-					lastTopo.FirstNodeID = idEntityMap[startTopo].FirstNodeID
-				}
-				break
-			}
-
-			if topoModel.FirstNodeID == "" {
-				// 末端节点
-				fmt.Printf("FirstNodeID %v\n", topoModel)
-				// 更新末端节点为startTopo的其中一个节点
-				db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-					Where("ID = ?", topo).
-					Updates(map[string]interface{}{"FIRST_NODE_ID": lastTopoNode})
-			}
-			if topoModel.SecondNodeID == "" {
-				// 末端节点
-				fmt.Printf("SecondNodeID %v\n", topoModel)
-				// 更新末端节点为startTopo的其中一个节点
-				db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-					Where("ID = ?", topo).
-					Updates(map[string]interface{}{"SECOND_NODE_ID": lastTopoNode})
-			}
-			if len(nodeMap[topoModel.FirstNodeID]) == 1 { // 末端节点
-				fmt.Printf("End FirstNodeID %v\n", topoModel)
-				db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-					Where("ID = ?", topo).
-					Updates(map[string]interface{}{"FIRST_NODE_ID": endTopoNode})
-				break
-			}
-			if len(nodeMap[topoModel.SecondNodeID]) == 1 { // 末端节点
-				fmt.Printf("End SecondNodeID %v\n", topoModel)
-				db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-					Where("ID = ?", topo).
-					Updates(map[string]interface{}{"SECOND_NODE_ID": endTopoNode})
-				break
-			}
-			if i == len(strings)-1 { // 最后一个, 说明是环, 取first做连接
-				fmt.Printf("Circle FirstNodeID %v\n", topoModel)
-				db.Table(config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
-					Where("ID = ?", topo).
-					Updates(map[string]interface{}{"FIRST_NODE_ID": endTopoNode})
-				break
-			}
-		}
-		lastKey = startTopo
-	}
-}
-
 // FindEndTopo 找到末端拓扑
 func FindEndTopo(topoList []Topo) (Topo, string, bool) {
 	// 统计每个节点出现的次数
@@ -468,198 +331,13 @@ func FindEndTopo(topoList []Topo) (Topo, string, bool) {
 	return Topo{}, "", false
 }
 
-// 构建图的邻接表
-func buildGraph(topoList []Topo) map[string][]string {
-	topoMap := make(map[string][]string)
-	visited := make(map[string]bool) // 已经击中的id
-	idMap, nodeMap, _, _, _ := GetNodeIDMap(topoList)
-
-	if len(topoList) == 0 {
-		fmt.Printf("❌ 无拓扑数据，无法构建图\n")
-	}
-
-	for _, topo := range topoList {
-		connected := RecusionGraph(visited, idMap, nodeMap, topoMap, topo.ID)
-		if len(connected) > 0 {
-			for id := range connected {
-				topoMap[topo.ID] = append(topoMap[topo.ID], id)
-			}
-		}
-	}
-
-	return topoMap
-}
-
-func GetNodeIDMap(topoList []Topo) (map[string][]string, map[string][]string, map[string][]string, map[string]Topo, map[string][]Topo) {
-	idMap := make(map[string][]string)        // id - node
-	nodeMap := make(map[string][]string)      // node - id
-	idConnectMap := make(map[string][]string) // id - idList
-	idEntityMap := make(map[string]Topo)
-	duplicateTopoMap := make(map[string][]Topo)
-
-	for _, topo := range topoList {
-		idEntityMap[topo.ID] = topo
-		if topo.FirstNodeID != "" {
-			if !Contain(nodeMap[topo.FirstNodeID], topo.ID) {
-				nodeMap[topo.FirstNodeID] = append(nodeMap[topo.FirstNodeID], topo.ID)
-			}
-		}
-		if topo.SecondNodeID != "" {
-			if !Contain(nodeMap[topo.SecondNodeID], topo.ID) {
-				nodeMap[topo.SecondNodeID] = append(nodeMap[topo.SecondNodeID], topo.ID)
-			}
-		}
-		if !Contain(idMap[topo.ID], topo.FirstNodeID) {
-			idMap[topo.ID] = append(idMap[topo.ID], topo.FirstNodeID)
-		}
-		if !Contain(idMap[topo.ID], topo.SecondNodeID) {
-			idMap[topo.ID] = append(idMap[topo.ID], topo.SecondNodeID)
-		}
-		{ // 查重复拓扑
-			if topo.FirstNodeID == "" {
-				duplicateTopoMap[topo.FirstNodeID] = append(duplicateTopoMap[topo.FirstNodeID], topo)
-				continue
-			}
-			if topo.SecondNodeID == "" {
-				duplicateTopoMap[topo.SecondNodeID] = append(duplicateTopoMap[topo.SecondNodeID], topo)
-				continue
-			}
-			duplicateTopoMap[topo.FirstNodeID+topo.SecondNodeID] = append(duplicateTopoMap[topo.FirstNodeID+topo.SecondNodeID], topo)
-			duplicateTopoMap[topo.SecondNodeID+topo.FirstNodeID] = append(duplicateTopoMap[topo.SecondNodeID+topo.FirstNodeID], topo)
-			continue
-		}
-	}
-
-	for id, nodeList := range idMap {
-		for _, node := range nodeList {
-			idList := nodeMap[node]
-			for _, connect := range idList {
-				if connect == id {
-					continue
-				}
-				if Contain(idConnectMap[id], connect) {
-					continue
-				}
-				idConnectMap[id] = append(idConnectMap[id], connect)
-			}
-		}
-	}
-
-	return idMap, nodeMap, idConnectMap, idEntityMap, duplicateTopoMap
-}
-
-func RecusionGraph(hitMap map[string]bool, idMap, nodeMap, topoMap map[string][]string, startTopo string) map[string]bool {
-	connected := make(map[string]bool)
-
-	// 是否已经查询到了
-	if hitMap[startTopo] {
-		return connected
-	}
-	hitMap[startTopo] = true // 标记查询状态
-
-	for _, node := range idMap[startTopo] {
-		for _, topo := range nodeMap[node] {
-			connected[topo] = true
-			subconnected := RecusionGraph(hitMap, idMap, nodeMap, topoMap, topo)
-			for id := range subconnected {
-				connected[id] = true
-			}
-		}
-	}
-
-	return connected
-}
-
-// 使用并查集算法计算连通分量
-type UnionFind struct {
-	parent map[string]string
-	rank   map[string]int
-}
-
-func NewUnionFind() *UnionFind {
-	return &UnionFind{
-		parent: make(map[string]string),
-		rank:   make(map[string]int),
-	}
-}
-
-func (uf *UnionFind) Find(x string) string {
-	if uf.parent[x] != x {
-		uf.parent[x] = uf.Find(uf.parent[x]) // 路径压缩
-	}
-	return uf.parent[x]
-}
-
-func (uf *UnionFind) Union(x, y string) {
-	rootX := uf.Find(x)
-	rootY := uf.Find(y)
-
-	if rootX != rootY {
-		// 按秩合并
-		if uf.rank[rootX] > uf.rank[rootY] {
-			uf.parent[rootY] = rootX
-		} else if uf.rank[rootX] < uf.rank[rootY] {
-			uf.parent[rootX] = rootY
-		} else {
-			uf.parent[rootY] = rootX
-			uf.rank[rootX]++
-		}
-	}
-}
-
-func (uf *UnionFind) AddNode(node string) {
-	if _, exists := uf.parent[node]; !exists {
-		uf.parent[node] = node
-		uf.rank[node] = 0
-	}
-}
-
-// 使用并查集查找连通分量
-func findConnectedComponentsWithUnionFind(graph map[string][]string) [][]string {
-	uf := NewUnionFind()
-
-	// 初始化并查集
-	for node := range graph {
-		uf.AddNode(node)
-	}
-
-	// 合并相连的节点
-	for node, neighbors := range graph {
-		for _, neighbor := range neighbors {
-			uf.Union(node, neighbor)
-		}
-	}
-
-	// 收集连通分量
-	components := make(map[string][]string)
-	for node := range graph {
-		root := uf.Find(node)
-		components[root] = append(components[root], node)
-	}
-
-	// 转换为结果格式，并对每个组内的节点排序（便于查看）
-	var result [][]string
-	for _, component := range components {
-		// 对节点ID进行排序
-		sort.Strings(component)
-		result = append(result, component)
-	}
-
-	// 按组的大小排序（大组在前）
-	sort.Slice(result, func(i, j int) bool {
-		return len(result[i]) > len(result[j])
-	})
-
-	return result
-}
-
 // 打印分组结果，包括每个拓扑组的首节点ID
 func printGroupResult(owner, feederID string, groups [][]string) {
 	fmt.Printf("📊 分组计算结果: Owner=%s, FeederID=%s\n", owner, feederID)
 	fmt.Printf("   发现 %d 个不相连的拓扑组:\n", len(groups))
 
 	for i, group := range groups {
-		// 获取首节点ID（排序后的第一个节点）
+		// 获取首节点ID（遍历的起点设备）
 		firstNodeID := ""
 		if len(group) > 0 {
 			firstNodeID = group[0]
@@ -704,12 +382,3 @@ func saveGroupResult(db *gorm.DB, owner, feederID string, groups [][]string) err
 
 	return nil
 }
-
-func Contain(list []string, str string) bool {
-	for _, s := range list {
-		if s == str {
-			return true
-		}
-	}
-	return false
-}
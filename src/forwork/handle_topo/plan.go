@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"raselper/src/forwork/first"
+	"raselper/src/secondary/nodeid"
+	"time"
+)
+
+// 修复步骤
+const (
+	stepNoneTopo   = "none_topo"   // 没有拓扑节点的设备
+	stepIsland     = "island"      // 孤立岛
+	stepHighDegree = "high_degree" // 节点连接设备过多
+	stepDuplicate  = "duplicate"   // 重复拓扑
+)
+
+const (
+	columnFirst  = "FIRST_NODE_ID"
+	columnSecond = "SECOND_NODE_ID"
+)
+
+// 一条节点修改
+type NodeChange struct {
+	ID     string `json:"id"`
+	Column string `json:"column"` // FIRST_NODE_ID 或 SECOND_NODE_ID
+	Old    string `json:"old"`
+	New    string `json:"new"`
+	Step   string `json:"step"`
+	Reason string `json:"reason"`
+}
+
+// 一条馈线的修复计划, 计算时不修改数据库, 审核后再执行
+type RepairPlan struct {
+	Owner      string       `json:"owner"`
+	FeederID   string       `json:"feeder_id"`
+	Created    time.Time    `json:"created"`
+	Rows       int          `json:"rows"`
+	Iterations int          `json:"iterations"` // 连接孤立岛的轮数
+	Converged  bool         `json:"converged"`  // 是否已连成一个整体
+	Message    string       `json:"message,omitempty"`
+	Changes    []NodeChange `json:"changes"`
+}
+
+type planOptions struct {
+	maxIterations int // 连接孤立岛的最大轮数
+	maxDegree     int // 节点最多连接的设备数
+	nodeIDs       nodeid.Allocator
+}
+
+// 在内存中的拓扑副本上按原handle_topo的顺序修复并记录每条修改:
+// 没有节点的设备 -> 孤立岛 -> 节点连接过多 -> 重复拓扑
+//
+// 新节点ID在计算计划时就从分配器预留, 计划文件中的ID不会与其他进程重复
+func buildRepairPlan(owner, feeder string, rows []Topo, options planOptions) (*RepairPlan, error) {
+	p := &planner{
+		plan: &RepairPlan{
+			Owner:    owner,
+			FeederID: feeder,
+			Created:  time.Now(),
+			Rows:     len(rows),
+			Changes:  make([]NodeChange, 0),
+		},
+		rows:    append([]Topo(nil), rows...),
+		index:   make(map[string][]int, len(rows)),
+		options: options,
+	}
+	for i, row := range p.rows {
+		if _, ok := p.index[row.ID]; !ok {
+			p.order = append(p.order, row.ID)
+		}
+		p.index[row.ID] = append(p.index[row.ID], i)
+	}
+
+	p.planNoneTopo()
+	p.planIslands()
+	p.planHighDegree()
+	p.planDuplicate()
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.plan, nil
+}
+
+type planner struct {
+	plan    *RepairPlan
+	rows    []Topo           // 修改后的拓扑
+	index   map[string][]int // ID - rows下标, 同一ID可能有多条记录
+	order   []string         // ID按出现顺序
+	options planOptions
+	err     error // 分配节点ID失败
+}
+
+func (p *planner) newNode() string {
+	id, err := p.options.nodeIDs.Next()
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return id
+}
+
+func (p *planner) row(id string) Topo {
+	return p.rows[p.index[id][0]]
+}
+
+func (p *planner) rowsOf(ids []string) []Topo {
+	list := make([]Topo, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, p.row(id))
+	}
+	return list
+}
+
+// 修改设备的一端节点, 同一ID的所有记录一起修改
+func (p *planner) set(id, column, value, step, reason string) {
+	old := p.row(id).FirstNodeID
+	if column == columnSecond {
+		old = p.row(id).SecondNodeID
+	}
+	for _, i := range p.index[id] {
+		if column == columnFirst {
+			p.rows[i].FirstNodeID = value
+		} else {
+			p.rows[i].SecondNodeID = value
+		}
+	}
+	p.plan.Changes = append(p.plan.Changes, NodeChange{ID: id, Column: column, Old: old, New: value, Step: step, Reason: reason})
+}
+
+// 把没有节点的设备依次串接在第一台两端都有节点的设备之后
+func (p *planner) planNoneTopo() {
+	anchor := ""
+	for _, id := range p.order {
+		if row := p.row(id); row.FirstNodeID != "" && row.SecondNodeID != "" {
+			anchor = row.SecondNodeID
+			break
+		}
+	}
+	for _, id := range p.order {
+		if row := p.row(id); row.FirstNodeID != "" || row.SecondNodeID != "" {
+			continue
+		}
+		if anchor == "" {
+			anchor = p.newNode()
+		}
+		next := p.newNode()
+		p.set(id, columnFirst, anchor, stepNoneTopo, "设备没有拓扑节点, 串接到节点 "+anchor)
+		p.set(id, columnSecond, next, stepNoneTopo, "设备没有拓扑节点, 新建节点")
+		anchor = next
+	}
+}
+
+// 把孤立岛逐个接到主网末端, 直到连成一个整体或达到最大轮数
+func (p *planner) planIslands() {
+	for {
+		components := first.NewTopoGraph(p.rows).Components()
+		if len(components) <= 1 {
+			p.plan.Converged = true
+			return
+		}
+		if p.plan.Iterations >= p.options.maxIterations {
+			p.plan.Message = fmt.Sprintf("%d 轮后仍有 %d 个连通分量, 无法收敛", p.plan.Iterations, len(components))
+			return
+		}
+		p.plan.Iterations++
+
+		main := 0
+		for i, component := range components {
+			if len(component) > len(components[main]) {
+				main = i
+			}
+		}
+		mainRows := p.rowsOf(components[main])
+		progress := false
+		for i, island := range components {
+			if i == main {
+				continue
+			}
+			node := mainEndNode(mainRows)
+			if node == "" || !p.attachIsland(island, node) {
+				continue
+			}
+			progress = true
+			mainRows = append(mainRows, p.rowsOf(island)...)
+		}
+		if !progress {
+			p.plan.Message = fmt.Sprintf("第 %d 轮没有可连接的孤立岛, 剩余 %d 个连通分量", p.plan.Iterations, len(components))
+			return
+		}
+	}
+}
+
+// 主网的末端节点, 没有末端(成环)时取第一台设备的节点
+func mainEndNode(rows []Topo) string {
+	if _, node, ok := FindEndTopo(rows); ok {
+		return node
+	}
+	for _, row := range rows {
+		if row.FirstNodeID != "" {
+			return row.FirstNodeID
+		}
+		if row.SecondNodeID != "" {
+			return row.SecondNodeID
+		}
+	}
+	return ""
+}
+
+// 孤立岛接入主网节点: 优先用一端为空的设备, 其次用孤立岛的末端节点, 成环时改第一台设备的首端
+func (p *planner) attachIsland(island []string, node string) bool {
+	reason := fmt.Sprintf("孤立岛(%d 台设备)接入主网节点 %s", len(island), node)
+	for _, id := range island {
+		row := p.row(id)
+		if row.FirstNodeID == "" {
+			p.set(id, columnFirst, node, stepIsland, reason+", 使用空端子")
+			return true
+		}
+		if row.SecondNodeID == "" {
+			p.set(id, columnSecond, node, stepIsland, reason+", 使用空端子")
+			return true
+		}
+	}
+
+	if end, endNode, ok := FindEndTopo(p.rowsOf(island)); ok {
+		column := columnFirst
+		if end.SecondNodeID == endNode {
+			column = columnSecond
+		}
+		p.set(end.ID, column, node, stepIsland, reason+", 替换末端节点 "+endNode)
+		return true
+	}
+
+	if len(island) == 0 {
+		return false
+	}
+	p.set(island[0], columnFirst, node, stepIsland, reason+", 孤立岛成环, 替换首端节点")
+	return true
+}
+
+// 节点连接的设备超过maxDegree时, 多出的设备依次串接到前一台设备的另一端
+func (p *planner) planHighDegree() {
+	graph := first.NewTopoGraph(p.rows)
+	for _, node := range graph.NodesOverDegree(p.options.maxDegree) {
+		devices := append([]string(nil), graph.NodeDevices(node)...)
+		reason := fmt.Sprintf("节点 %s 连接 %d 台设备, 超过 %d", node, len(devices), p.options.maxDegree)
+		at := node // 前一台设备连接在哪个节点上
+		for i := p.options.maxDegree; i < len(devices); i++ {
+			previous := p.row(devices[i-1])
+			attach := otherNode(previous, at)
+			if attach == "" {
+				attach = p.newNode()
+				column := columnSecond
+				if previous.FirstNodeID == "" {
+					column = columnFirst
+				}
+				p.set(previous.ID, column, attach, stepHighDegree, reason+", 为串接补充节点")
+			}
+
+			current := p.row(devices[i])
+			column := columnFirst
+			if current.FirstNodeID != node {
+				column = columnSecond
+			}
+			p.set(current.ID, column, attach, stepHighDegree, fmt.Sprintf("%s, 串接到 %s 的另一端", reason, previous.ID))
+			at = attach
+		}
+	}
+}
+
+// 设备不在node上的一端, 两端都在node上时返回空
+func otherNode(row Topo, node string) string {
+	if row.FirstNodeID == node {
+		if row.SecondNodeID == node {
+			return ""
+		}
+		return row.SecondNodeID
+	}
+	return row.FirstNodeID
+}
+
+// 重复拓扑: 连接同一对节点的设备保留第一台, 其余的末端改为新节点; 多台设备一端为空时空端补充新节点
+func (p *planner) planDuplicate() {
+	pairs := make(map[[2]string][]string)
+	var dangling []string
+	for _, id := range p.order {
+		row := p.row(id)
+		switch {
+		case row.FirstNodeID == "" && row.SecondNodeID == "":
+		case row.FirstNodeID == "" || row.SecondNodeID == "":
+			dangling = append(dangling, id)
+		case row.FirstNodeID != row.SecondNodeID:
+			pair := [2]string{row.FirstNodeID, row.SecondNodeID}
+			if pair[0] > pair[1] {
+				pair[0], pair[1] = pair[1], pair[0]
+			}
+			pairs[pair] = append(pairs[pair], id)
+		}
+	}
+
+	if len(dangling) > 1 {
+		for _, id := range dangling {
+			column := columnFirst
+			if p.row(id).SecondNodeID == "" {
+				column = columnSecond
+			}
+			p.set(id, column, p.newNode(), stepDuplicate, "一端为空, 补充新节点")
+		}
+	}
+	for _, id := range p.order {
+		row := p.row(id)
+		pair := [2]string{row.FirstNodeID, row.SecondNodeID}
+		if pair[0] > pair[1] {
+			pair[0], pair[1] = pair[1], pair[0]
+		}
+		devices := pairs[pair]
+		if len(devices) <= 1 || devices[0] == id {
+			continue
+		}
+		p.set(id, columnSecond, p.newNode(), stepDuplicate,
+			fmt.Sprintf("与 %s 连接同一对节点 %s/%s", devices[0], pair[0], pair[1]))
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"raselper/src/forwork/first"
+	"raselper/src/secondary/nodeid"
+	"strings"
+	"testing"
+	"time"
+)
+
+func topoRow(id, node1, node2 string) Topo {
+	return Topo{Owner: "O", FeederID: "F", ID: id, FirstNodeID: node1, SecondNodeID: node2}
+}
+
+func testOptions(maxIterations int) planOptions {
+	return planOptions{maxIterations: maxIterations, maxDegree: 3, nodeIDs: nodeid.NewFake()}
+}
+
+func mustPlan(t *testing.T, rows []Topo, options planOptions) *RepairPlan {
+	plan, err := buildRepairPlan("O", "F", rows, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plan
+}
+
+// 按计划修改后的拓扑
+func applyChanges(rows []Topo, plan *RepairPlan) []Topo {
+	rows = append([]Topo(nil), rows...)
+	for _, change := range plan.Changes {
+		for i := range rows {
+			if rows[i].ID != change.ID {
+				continue
+			}
+			if change.Column == columnFirst {
+				rows[i].FirstNodeID = change.New
+			} else {
+				rows[i].SecondNodeID = change.New
+			}
+		}
+	}
+	return rows
+}
+
+func TestBuildRepairPlan(t *testing.T) {
+	rows := []Topo{
+		topoRow("breaker", "n1", "n2"),
+		topoRow("line1", "n2", "n3"),
+		topoRow("line2", "n3", "n4"),
+		topoRow("none", "", ""),
+		topoRow("island1", "n8", "n9"),
+		topoRow("island2", "n9", "n10"),
+		topoRow("ring1", "r1", "r2"),
+		topoRow("ring2", "r2", "r1"),
+		topoRow("load1", "n4", "l1"),
+		topoRow("load2", "n4", "l2"),
+		topoRow("load3", "n4", "l3"),
+		topoRow("load4", "n4", "l4"),
+	}
+	plan := mustPlan(t, rows, testOptions(10))
+	if !plan.Converged || plan.Message != "" {
+		t.Fatalf("plan should converge: %+v", plan)
+	}
+
+	fixed := applyChanges(rows, plan)
+	graph := first.NewTopoGraph(fixed)
+	if components := graph.Components(); len(components) != 1 || len(graph.Unconnected()) != 0 {
+		t.Fatalf("unexpected components %v", components)
+	}
+	if nodes := graph.NodesOverDegree(3); len(nodes) != 0 {
+		t.Fatalf("nodes over degree %v", nodes)
+	}
+
+	counts := make(map[string]int)
+	for _, change := range plan.Changes {
+		counts[change.Step]++
+		if change.Reason == "" {
+			t.Errorf("change without reason %+v", change)
+		}
+	}
+	if counts[stepNoneTopo] != 2 || counts[stepIsland] == 0 || counts[stepHighDegree] == 0 {
+		t.Fatalf("unexpected steps %v", counts)
+	}
+	// 原始数据不变
+	if rows[3].FirstNodeID != "" {
+		t.Fatal("input rows modified")
+	}
+}
+
+func TestBuildRepairPlanDuplicate(t *testing.T) {
+	rows := []Topo{
+		topoRow("a", "n1", "n2"),
+		topoRow("b", "n2", "n1"),
+		topoRow("c", "n1", "n2"),
+	}
+	plan := mustPlan(t, rows, testOptions(10))
+	if len(plan.Changes) != 2 || plan.Changes[0].ID != "b" || plan.Changes[1].ID != "c" {
+		t.Fatalf("unexpected changes %+v", plan.Changes)
+	}
+	for _, change := range plan.Changes {
+		if change.Step != stepDuplicate || change.Column != columnSecond {
+			t.Fatalf("unexpected change %+v", change)
+		}
+	}
+}
+
+func TestBuildRepairPlanNotConverged(t *testing.T) {
+	rows := []Topo{
+		topoRow("a", "n1", "n2"),
+		topoRow("b", "n3", "n4"),
+	}
+	plan := mustPlan(t, rows, testOptions(0))
+	if plan.Converged || plan.Message == "" || plan.Iterations != 0 {
+		t.Fatalf("plan should not converge: %+v", plan)
+	}
+	if err := applyPlan(nil, plan, "", false); err == nil {
+		t.Fatal("unconverged plan should be rejected")
+	}
+
+	plan = mustPlan(t, rows, testOptions(1))
+	if !plan.Converged || plan.Iterations != 1 {
+		t.Fatalf("plan should converge in one iteration: %+v", plan)
+	}
+}
+
+func TestBeforeFile(t *testing.T) {
+	now := time.Date(2026, 10, 19, 8, 30, 5, 123e6, time.Local)
+	file := beforeFile("plans/O_F.json", now)
+	if file != "plans/O_F.20261019-083005.123.before.json" || !strings.HasSuffix(file, ".before.json") {
+		t.Fatalf("unexpected before file %s", file)
+	}
+	if beforeFile("plans/O_F.json", now.Add(time.Millisecond)) == file {
+		t.Fatal("before files of different runs should not collide")
+	}
+}
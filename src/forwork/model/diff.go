@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"raselper/src/forwork/read_model/util"
+	"sort"
+	"strings"
+)
+
+const (
+	fieldName    = "IdentifiedObject.name"
+	fieldCircuit = "PowerSystemResource.Circuit"
+	fieldClass   = "class"
+)
+
+// 一个对象
+type ObjectRef struct {
+	ID    string `json:"id"`
+	Class string `json:"class"`
+	Name  string `json:"name"`
+}
+
+// 同一rdf:ID的一个字段变化, 引用类字段为rdf:resource
+type FieldChange struct {
+	ObjectRef
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// 设备端子连接的节点变化, 节点按ID排序
+type ConnectionChange struct {
+	ObjectRef
+	Old []string `json:"old"`
+	New []string `json:"new"`
+}
+
+// 两个版本的图模异动, 按rdf:ID对比; 端子和连接节点不单独列出, 归到设备的连接变化中
+type ModelDiff struct {
+	Old         string             `json:"old"`
+	New         string             `json:"new"`
+	Added       []ObjectRef        `json:"added"`
+	Removed     []ObjectRef        `json:"removed"`
+	Renamed     []FieldChange      `json:"renamed"`
+	Feeders     []FieldChange      `json:"feeders"` // 所属馈线变化
+	Connections []ConnectionChange `json:"connections"`
+	Attributes  []FieldChange      `json:"attributes"`
+}
+
+func (d *ModelDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Renamed) == 0 &&
+		len(d.Feeders) == 0 && len(d.Connections) == 0 && len(d.Attributes) == 0
+}
+
+func diffFiles(oldFile, newFile string) (*ModelDiff, error) {
+	oldModel, err := util.ParseCIMModel(oldFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", oldFile, err)
+	}
+	newModel, err := util.ParseCIMModel(newFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", newFile, err)
+	}
+	diff := diffModels(oldModel, newModel)
+	diff.Old, diff.New = oldFile, newFile
+	return diff, nil
+}
+
+// 不按对象对比的类
+func topological(object *util.CIMObject) bool {
+	return object.Class == "Terminal" || object.Class == "ConnectivityNode"
+}
+
+func ref(object *util.CIMObject) ObjectRef {
+	return ObjectRef{ID: object.ID, Class: object.Class, Name: strings.TrimSpace(object.Value(fieldName))}
+}
+
+func diffModels(oldModel, newModel *util.CIMModel) *ModelDiff {
+	diff := &ModelDiff{
+		Added:       make([]ObjectRef, 0),
+		Removed:     make([]ObjectRef, 0),
+		Renamed:     make([]FieldChange, 0),
+		Feeders:     make([]FieldChange, 0),
+		Connections: make([]ConnectionChange, 0),
+		Attributes:  make([]FieldChange, 0),
+	}
+	oldNodes, newNodes := terminalNodes(oldModel), terminalNodes(newModel)
+
+	for _, object := range oldModel.Objects {
+		if topological(object) || object.ID == "" {
+			continue
+		}
+		if newModel.Object(object.ID) == nil {
+			diff.Removed = append(diff.Removed, ref(object))
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, object := range newModel.Objects {
+		if topological(object) || object.ID == "" || seen[object.ID] {
+			continue
+		}
+		seen[object.ID] = true
+		old := oldModel.Object(object.ID)
+		if old == nil {
+			diff.Added = append(diff.Added, ref(object))
+			continue
+		}
+
+		r := ref(object)
+		if old.Class != object.Class {
+			diff.Attributes = append(diff.Attributes, FieldChange{ObjectRef: r, Field: fieldClass, Old: old.Class, New: object.Class})
+		}
+		for _, field := range fields(old, object) {
+			before, after := fieldValue(old, field), fieldValue(object, field)
+			if before == after {
+				continue
+			}
+			change := FieldChange{ObjectRef: r, Field: field, Old: before, New: after}
+			switch field {
+			case fieldName:
+				diff.Renamed = append(diff.Renamed, change)
+			case fieldCircuit:
+				diff.Feeders = append(diff.Feeders, change)
+			default:
+				diff.Attributes = append(diff.Attributes, change)
+			}
+		}
+		if before, after := oldNodes[object.ID], newNodes[object.ID]; !equalNodes(before, after) {
+			diff.Connections = append(diff.Connections, ConnectionChange{ObjectRef: r, Old: before, New: after})
+		}
+	}
+	return diff
+}
+
+// 两个版本中出现过的属性名, 按名称排序
+func fields(a, b *util.CIMObject) []string {
+	set := make(map[string]bool)
+	for _, object := range []*util.CIMObject{a, b} {
+		for _, p := range object.Properties {
+			set[p.Name] = true
+		}
+	}
+	list := make([]string, 0, len(set))
+	for field := range set {
+		list = append(list, field)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// 引用取rdf:resource, 否则取文本
+func fieldValue(object *util.CIMObject, field string) string {
+	if resource := object.Resource(field); resource != "" {
+		return resource
+	}
+	return strings.TrimSpace(object.Value(field))
+}
+
+// 设备 - 端子连接的节点, 排序后对比, 与端子ID和顺序无关
+func terminalNodes(model *util.CIMModel) map[string][]string {
+	nodes := make(map[string][]string)
+	for _, terminal := range model.Class("Terminal") {
+		device := strings.TrimPrefix(terminal.Resource("Terminal.ConductingEquipment"), "#")
+		node := strings.TrimPrefix(terminal.Resource("Terminal.ConnectivityNode"), "#")
+		nodes[device] = append(nodes[device], node)
+	}
+	for _, list := range nodes {
+		sort.Strings(list)
+	}
+	return nodes
+}
+
+func equalNodes(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func printDiff(w io.Writer, diff *ModelDiff) {
+	fmt.Fprintf(w, "旧: %s\n新: %s\n", diff.Old, diff.New)
+	if diff.Empty() {
+		fmt.Fprintln(w, "没有变化")
+		return
+	}
+
+	fmt.Fprintf(w, "\n新增 %d:\n", len(diff.Added))
+	for _, r := range diff.Added {
+		fmt.Fprintf(w, "  + %s %s %s\n", r.Class, r.ID, r.Name)
+	}
+	fmt.Fprintf(w, "\n删除 %d:\n", len(diff.Removed))
+	for _, r := range diff.Removed {
+		fmt.Fprintf(w, "  - %s %s %s\n", r.Class, r.ID, r.Name)
+	}
+	fmt.Fprintf(w, "\n改名 %d:\n", len(diff.Renamed))
+	for _, c := range diff.Renamed {
+		fmt.Fprintf(w, "  %s %s: %s -> %s\n", c.Class, c.ID, c.Old, c.New)
+	}
+	fmt.Fprintf(w, "\n馈线变化 %d:\n", len(diff.Feeders))
+	for _, c := range diff.Feeders {
+		fmt.Fprintf(w, "  %s %s %s: %s -> %s\n", c.Class, c.ID, c.Name, c.Old, c.New)
+	}
+	fmt.Fprintf(w, "\n连接变化 %d:\n", len(diff.Connections))
+	for _, c := range diff.Connections {
+		fmt.Fprintf(w, "  %s %s %s: %v -> %v\n", c.Class, c.ID, c.Name, c.Old, c.New)
+	}
+	fmt.Fprintf(w, "\n属性变化 %d:\n", len(diff.Attributes))
+	for _, c := range diff.Attributes {
+		fmt.Fprintf(w, "  %s %s %s %s: %q -> %q\n", c.Class, c.ID, c.Name, c.Field, c.Old, c.New)
+	}
+}
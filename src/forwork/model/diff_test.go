@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"raselper/src/forwork/read_model/util"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const header = `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:cim="http://www.sgcc.com.cn/SG-CIM/2010MAY#" xmlns:iescim="http://www.ieslab.com.cn">
+`
+
+const oldCIM = header + `<cim:Circuit rdf:ID="F1"><cim:IdentifiedObject.name>一线</cim:IdentifiedObject.name></cim:Circuit>
+<cim:Breaker rdf:ID="b1"><cim:IdentifiedObject.name>开关</cim:IdentifiedObject.name><cim:PowerSystemResource.Circuit rdf:resource="#F1"/></cim:Breaker>
+<cim:ACLineSegment rdf:ID="l1"><cim:IdentifiedObject.name>线路</cim:IdentifiedObject.name><cim:PowerSystemResource.Circuit rdf:resource="#F1"/></cim:ACLineSegment>
+<cim:Fuse rdf:ID="f1"><cim:IdentifiedObject.name>熔断器</cim:IdentifiedObject.name></cim:Fuse>
+<cim:Terminal rdf:ID="t1"><cim:Terminal.ConductingEquipment rdf:resource="#b1"/><cim:Terminal.ConnectivityNode rdf:resource="#n1"/></cim:Terminal>
+<cim:Terminal rdf:ID="t2"><cim:Terminal.ConductingEquipment rdf:resource="#b1"/><cim:Terminal.ConnectivityNode rdf:resource="#n2"/></cim:Terminal>
+<cim:Terminal rdf:ID="t3"><cim:Terminal.ConductingEquipment rdf:resource="#l1"/><cim:Terminal.ConnectivityNode rdf:resource="#n2"/></cim:Terminal>
+</rdf:RDF>`
+
+const newCIM = header + `<cim:Circuit rdf:ID="F1"><cim:IdentifiedObject.name>一线</cim:IdentifiedObject.name></cim:Circuit>
+<cim:Circuit rdf:ID="F2"><cim:IdentifiedObject.name>二线</cim:IdentifiedObject.name></cim:Circuit>
+<cim:Breaker rdf:ID="b1"><cim:IdentifiedObject.name>开关</cim:IdentifiedObject.name><cim:PowerSystemResource.Circuit rdf:resource="#F1"/>
+<iescim:PowerSystemResource.IsYK>1</iescim:PowerSystemResource.IsYK></cim:Breaker>
+<cim:ACLineSegment rdf:ID="l1"><cim:IdentifiedObject.name>新线路</cim:IdentifiedObject.name><cim:PowerSystemResource.Circuit rdf:resource="#F2"/></cim:ACLineSegment>
+<cim:Terminal rdf:ID="x2"><cim:Terminal.ConductingEquipment rdf:resource="#b1"/><cim:Terminal.ConnectivityNode rdf:resource="#n2"/></cim:Terminal>
+<cim:Terminal rdf:ID="x1"><cim:Terminal.ConductingEquipment rdf:resource="#b1"/><cim:Terminal.ConnectivityNode rdf:resource="#n1"/></cim:Terminal>
+<cim:Terminal rdf:ID="x3"><cim:Terminal.ConductingEquipment rdf:resource="#l1"/><cim:Terminal.ConnectivityNode rdf:resource="#n3"/></cim:Terminal>
+</rdf:RDF>`
+
+func decode(t *testing.T, content string) *util.CIMModel {
+	model, err := util.DecodeCIMModel(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model
+}
+
+func TestDiffModels(t *testing.T) {
+	diff := diffModels(decode(t, oldCIM), decode(t, newCIM))
+
+	if !reflect.DeepEqual(diff.Added, []ObjectRef{{ID: "F2", Class: "Circuit", Name: "二线"}}) {
+		t.Errorf("unexpected added %+v", diff.Added)
+	}
+	if !reflect.DeepEqual(diff.Removed, []ObjectRef{{ID: "f1", Class: "Fuse", Name: "熔断器"}}) {
+		t.Errorf("unexpected removed %+v", diff.Removed)
+	}
+	if len(diff.Renamed) != 1 || diff.Renamed[0].ID != "l1" || diff.Renamed[0].Old != "线路" || diff.Renamed[0].New != "新线路" {
+		t.Errorf("unexpected renamed %+v", diff.Renamed)
+	}
+	if len(diff.Feeders) != 1 || diff.Feeders[0].Old != "#F1" || diff.Feeders[0].New != "#F2" {
+		t.Errorf("unexpected feeders %+v", diff.Feeders)
+	}
+	// b1的端子ID和顺序变了, 节点没变
+	if len(diff.Connections) != 1 || diff.Connections[0].ID != "l1" ||
+		!reflect.DeepEqual(diff.Connections[0].Old, []string{"n2"}) || !reflect.DeepEqual(diff.Connections[0].New, []string{"n3"}) {
+		t.Errorf("unexpected connections %+v", diff.Connections)
+	}
+	if len(diff.Attributes) != 1 || diff.Attributes[0].Field != "PowerSystemResource.IsYK" || diff.Attributes[0].New != "1" {
+		t.Errorf("unexpected attributes %+v", diff.Attributes)
+	}
+
+	var out bytes.Buffer
+	printDiff(&out, diff)
+	if !strings.Contains(out.String(), "馈线变化 1") || !strings.Contains(out.String(), "- Fuse f1 熔断器") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestDiffModelsSame(t *testing.T) {
+	diff := diffModels(decode(t, oldCIM), decode(t, oldCIM))
+	if !diff.Empty() {
+		t.Fatalf("expected no changes: %+v", diff)
+	}
+}
+
+func TestDiffFiles(t *testing.T) {
+	diff, err := diffFiles("../data/连湖变10kV连濯线912线路_单线图.xml", "../data/110kV连湖变10kV连濯线912线路_单线图.xml")
+	if err != nil {
+		t.Skip(err)
+	}
+	if len(diff.Added) == 0 || len(diff.Removed) == 0 || len(diff.Renamed) == 0 {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"raselper/src/forwork/first"
+	"raselper/src/forwork/read_model/util"
+
+	"gorm.io/gorm"
+)
+
+// 设备表与CIM类的对应, 与generate_g_from_dm的modelList一致
+type deviceTable struct {
+	Class string // CIM类
+	Table string // 设备表 _B
+	Map   string // 源端ID映射表 _C
+}
+
+var deviceTables = []deviceTable{
+	{Class: "ACLineSegment", Table: "SG_DEV_LOWVOLLINE_B", Map: "SG_DEV_LOWVOLLINE_C"},
+	{Class: "PowerTransformer", Table: "SG_DEV_DPWRTRANSFM_B", Map: "SG_DEV_DPWRTRANSFM_C"},
+	{Class: "Breaker", Table: "SG_DEV_DBREAKER_B", Map: "SG_DEV_DBREAKER_C"},
+	{Class: "Breaker", Table: "SG_DEV_DLOADSWITCH_B", Map: "SG_DEV_DLOADSWITCH_C"}, // 源端单线图中负荷开关也是Breaker
+	{Class: "Fuse", Table: "SG_DEV_DFUSE_B", Map: "SG_DEV_DFUSE_C"},
+	{Class: "Disconnector", Table: "SG_DEV_DDIS_B", Map: "SG_DEV_DDIS_C"},
+	{Class: "BusbarSection", Table: "SG_DEV_DBUS_B", Map: "SG_DEV_DBUS_C"},
+}
+
+// 要导出的设备
+type ExportDevice struct {
+	ID    string // 库中的设备ID
+	RdfID string // 源端rdf:ID, 没有映射时使用ID
+	Class string
+	Name  string
+}
+
+// 一条馈线的导出数据
+type FeederExport struct {
+	ID      string // 库中的馈线ID
+	RdfID   string
+	Name    string
+	Devices []ExportDevice
+	Topo    []first.Topo // ID为库中的设备ID
+}
+
+type deviceRow struct {
+	ID   string `gorm:"column:ID"`
+	Name string `gorm:"column:NAME"`
+}
+
+type rdfIDRow struct {
+	ID    string `gorm:"column:ID"`
+	RdfID string `gorm:"column:RDF_ID"`
+}
+
+// 查询一条馈线的设备、源端ID映射和拓扑, 只读
+func queryFeederExport(db *gorm.DB, database, owner, feeder string) (*FeederExport, error) {
+	export := &FeederExport{ID: feeder, RdfID: feeder}
+
+	var feederRows []deviceRow
+	if err := db.Table(database+".SG_CON_FEEDERLINE_B").
+		Select("ID, NAME").
+		Where("ID = ?", feeder).
+		Find(&feederRows).Error; err != nil {
+		return nil, err
+	}
+	if len(feederRows) == 0 {
+		return nil, fmt.Errorf("馈线 %s 不存在", feeder)
+	}
+	export.Name = feederRows[0].Name
+	var feederC []util.FeederC
+	if err := db.Table(database+".SG_CON_FEEDERLINE_C").
+		Where("DCLOUD_ID = ?", feeder).
+		Find(&feederC).Error; err != nil {
+		return nil, err
+	}
+	if len(feederC) > 0 && feederC[0].PmsRdfID != "" {
+		export.RdfID = feederC[0].PmsRdfID
+	}
+
+	for _, table := range deviceTables {
+		var rows []deviceRow
+		if err := db.Table(database+"."+table.Table).
+			Select("ID, NAME").
+			Where("OWNER = ? AND FEEDER_ID = ?", owner, feeder).
+			Order("ID").
+			Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("查询%s失败: %w", table.Table, err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		ids := make([]string, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		rdfIDs, err := queryRdfIDs(db, database, table, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			export.Devices = append(export.Devices, ExportDevice{ID: row.ID, RdfID: rdfIDs[row.ID], Class: table.Class, Name: row.Name})
+		}
+	}
+
+	if err := db.Table(database+".SG_CON_DPWRGRID_R_TOPO").
+		Where("OWNER = ? AND FEEDER_ID = ?", owner, feeder).
+		Order("ID").
+		Find(&export.Topo).Error; err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// 源端rdf:ID, 先查_C表, 没有的再查ID_MAP
+func queryRdfIDs(db *gorm.DB, database string, table deviceTable, ids []string) (map[string]string, error) {
+	rdfIDs := make(map[string]string, len(ids))
+	for start := 0; start < len(ids); start += 1000 {
+		end := min(start+1000, len(ids))
+		var rows []rdfIDRow
+		if err := db.Table(database+"."+table.Map).
+			Select("DCLOUD_ID AS ID, PMS_RDF_ID AS RDF_ID").
+			Where("DCLOUD_ID IN ?", ids[start:end]).
+			Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("查询%s失败: %w", table.Map, err)
+		}
+		var idMap []rdfIDRow
+		if err := db.Table(database+".ID_MAP").
+			Select("ID, RDF_ID").
+			Where("ID IN ?", ids[start:end]).
+			Find(&idMap).Error; err != nil {
+			return nil, fmt.Errorf("查询ID_MAP失败: %w", err)
+		}
+		for _, row := range idMap {
+			if row.RdfID != "" {
+				rdfIDs[row.ID] = row.RdfID
+			}
+		}
+		for _, row := range rows {
+			if row.RdfID != "" {
+				rdfIDs[row.ID] = row.RdfID
+			}
+		}
+	}
+	return rdfIDs, nil
+}
+
+// 按ParseCIMXML能读取的格式写出: Circuit, 设备, ConnectivityNode, Terminal
+//
+// 连接节点使用库中的节点ID, 端子ID为 <设备rdf:ID>_1 / _2; 拓扑中没有对应设备的记录跳过
+func writeCIM(w io.Writer, export *FeederExport) error {
+	out := bufio.NewWriter(w)
+	devices := make(map[string]ExportDevice, len(export.Devices))
+	for _, device := range export.Devices {
+		if device.RdfID == "" {
+			device.RdfID = device.ID
+		}
+		devices[device.ID] = device
+	}
+
+	fmt.Fprintf(out, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n\n")
+	fmt.Fprintf(out, "<rdf:RDF xmlns:rdf=%q xmlns:cim=%q xmlns:iescim=%q>\n\n", util.RDFNS, util.CIMNS, util.IESNS)
+
+	fmt.Fprintf(out, "<cim:Circuit rdf:ID=\"%s\">\n", escape(export.RdfID))
+	fmt.Fprintf(out, "<cim:IdentifiedObject.name>%s</cim:IdentifiedObject.name>\n", escape(export.Name))
+	fmt.Fprintf(out, "<cim:iscurrentfeeder>1</cim:iscurrentfeeder>\n")
+	fmt.Fprintf(out, "</cim:Circuit>\n")
+
+	for _, device := range export.Devices {
+		device = devices[device.ID]
+		fmt.Fprintf(out, "<cim:%s rdf:ID=\"%s\">\n", device.Class, escape(device.RdfID))
+		fmt.Fprintf(out, "<cim:IdentifiedObject.name>%s</cim:IdentifiedObject.name>\n", escape(device.Name))
+		fmt.Fprintf(out, "<cim:PowerSystemResource.Circuit rdf:resource=\"#%s\" />\n", escape(export.RdfID))
+		fmt.Fprintf(out, "<iescim:PowerSystemResource.DeviceID>%s</iescim:PowerSystemResource.DeviceID>\n", escape(device.ID))
+		fmt.Fprintf(out, "</cim:%s>\n", device.Class)
+	}
+
+	seen := make(map[string]bool)
+	var terminals []util.Terminal
+	skipped := 0
+	for _, row := range effectiveTopo(export.ID, export.Topo) {
+		device, ok := devices[row.ID]
+		if !ok {
+			skipped++
+			continue
+		}
+		for i, node := range []string{row.FirstNodeID, row.SecondNodeID} {
+			if node == "" {
+				continue
+			}
+			if !seen[node] {
+				seen[node] = true
+				fmt.Fprintf(out, "<cim:ConnectivityNode rdf:ID=\"%s\">\n</cim:ConnectivityNode>\n", escape(node))
+			}
+			var terminal util.Terminal
+			terminal.ID = fmt.Sprintf("%s_%d", device.RdfID, i+1)
+			terminal.ConductingEquipment.Resource = "#" + device.RdfID
+			terminal.ConnectivityNode.Resource = "#" + node
+			terminals = append(terminals, terminal)
+		}
+	}
+	if skipped > 0 {
+		log.Printf("馈线 %s: %d 条拓扑记录没有对应的设备, 已跳过", export.ID, skipped)
+	}
+
+	for _, terminal := range terminals {
+		fmt.Fprintf(out, "<cim:Terminal rdf:ID=\"%s\">\n", escape(terminal.ID))
+		fmt.Fprintf(out, "<cim:Terminal.ConductingEquipment rdf:resource=\"%s\"/>\n", escape(terminal.ConductingEquipment.Resource))
+		fmt.Fprintf(out, "<cim:Terminal.ConnectivityNode rdf:resource=\"%s\"/>\n", escape(terminal.ConnectivityNode.Resource))
+		fmt.Fprintf(out, "</cim:Terminal>\n")
+	}
+	fmt.Fprintf(out, "\n</rdf:RDF>\n")
+	return out.Flush()
+}
+
+// 同一设备有多条拓扑记录时只保留一条, 否则端子rdf:ID重复且端子超过两个;
+// 优先没有失效时间的, 其次生效时间最晚的, 都相同时取第一条
+func effectiveTopo(feeder string, rows []first.Topo) []first.Topo {
+	index := make(map[string]int, len(rows))
+	result := make([]first.Topo, 0, len(rows))
+	duplicated := 0
+	for _, row := range rows {
+		i, ok := index[row.ID]
+		if !ok {
+			index[row.ID] = len(result)
+			result = append(result, row)
+			continue
+		}
+		duplicated++
+		current := result[i]
+		if (current.ExpiryTime != "") != (row.ExpiryTime != "") {
+			if row.ExpiryTime == "" {
+				result[i] = row
+			}
+		} else if row.EffectiveTime > current.EffectiveTime {
+			result[i] = row
+		}
+	}
+	if duplicated > 0 {
+		log.Printf("馈线 %s: %d 条重复的设备拓扑记录, 每个设备只导出一条", feeder, duplicated)
+	}
+	return result
+}
+
+func escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"raselper/src/forwork/first"
+	"raselper/src/forwork/read_model/util"
+	"reflect"
+	"testing"
+)
+
+func testExport() *FeederExport {
+	return &FeederExport{
+		ID:    "F100",
+		RdfID: "PD_F1",
+		Name:  "一线<912>",
+		Devices: []ExportDevice{
+			{ID: "B100", RdfID: "PD_b1", Class: "Breaker", Name: "开关&1"},
+			{ID: "L100", Class: "ACLineSegment", Name: "线路"},
+			{ID: "T100", RdfID: "PD_t1", Class: "PowerTransformer", Name: "配变"},
+		},
+		Topo: []first.Topo{
+			{ID: "B100", FirstNodeID: "N1", SecondNodeID: "N2"},
+			{ID: "L100", FirstNodeID: "N2", SecondNodeID: "N3"},
+			{ID: "T100", FirstNodeID: "N3"},
+			{ID: "X100", FirstNodeID: "N3", SecondNodeID: "N4"}, // 没有对应设备
+		},
+	}
+}
+
+func TestWriteCIM(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeCIM(&out, testExport()); err != nil {
+		t.Fatal(err)
+	}
+	rdf, index, err := util.DecodeCIMXML(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+
+	if len(rdf.Circuits) != 1 || rdf.Circuits[0].ID != "PD_F1" || rdf.Circuits[0].Name != "一线<912>" || rdf.Circuits[0].IsCurrentFeeder != "1" {
+		t.Errorf("unexpected circuits %+v", rdf.Circuits)
+	}
+	if len(rdf.Breakers) != 1 || rdf.Breakers[0].Name != "开关&1" || rdf.Breakers[0].DeviceID != "B100" {
+		t.Errorf("unexpected breakers %+v", rdf.Breakers)
+	}
+	if len(rdf.ACLineSegments) != 1 || rdf.ACLineSegments[0].ID != "L100" {
+		t.Errorf("没有rdf:ID映射时应使用库中ID: %+v", rdf.ACLineSegments)
+	}
+	if len(rdf.ConnectivityNodes) != 3 || len(rdf.Terminals) != 5 {
+		t.Errorf("unexpected nodes %d terminals %d", len(rdf.ConnectivityNodes), len(rdf.Terminals))
+	}
+
+	want := map[string][]string{
+		"PD_b1": {"N1", "N2"},
+		"L100":  {"N2", "N3"},
+		"PD_t1": {"N3"},
+	}
+	if !reflect.DeepEqual(index.IDNodeMap, want) {
+		t.Errorf("unexpected topology %v", index.IDNodeMap)
+	}
+	for id, feeder := range index.DeviceFeederMap {
+		if feeder != "#PD_F1" {
+			t.Errorf("%s 的馈线为 %s", id, feeder)
+		}
+	}
+	if len(index.Passed()) != 0 {
+		t.Errorf("unexpected passed %v", index.Passed())
+	}
+}
+
+// 导出的文件再按rdf:ID对比应没有变化
+func TestWriteCIMDiff(t *testing.T) {
+	var a, b bytes.Buffer
+	if err := writeCIM(&a, testExport()); err != nil {
+		t.Fatal(err)
+	}
+	export := testExport()
+	export.Devices = export.Devices[:2]
+	if err := writeCIM(&b, export); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := diffModels(decode(t, a.String()), decode(t, a.String())); !diff.Empty() {
+		t.Errorf("expected no changes: %+v", diff)
+	}
+	diff := diffModels(decode(t, a.String()), decode(t, b.String()))
+	if len(diff.Removed) != 1 || diff.Removed[0].ID != "PD_t1" {
+		t.Errorf("unexpected removed %+v", diff.Removed)
+	}
+}
+
+func TestEffectiveTopo(t *testing.T) {
+	rows := []first.Topo{
+		{ID: "A", FirstNodeID: "N1", EffectiveTime: "2024-01-01 00:00:00", ExpiryTime: "2025-01-01 00:00:00"},
+		{ID: "B", FirstNodeID: "N1", EffectiveTime: "2024-01-01 00:00:00"},
+		{ID: "A", FirstNodeID: "N2", EffectiveTime: "2023-01-01 00:00:00"}, // 没有失效时间优先
+		{ID: "B", FirstNodeID: "N2", EffectiveTime: "2025-01-01 00:00:00"}, // 生效时间最晚
+		{ID: "B", FirstNodeID: "N3", EffectiveTime: "2025-01-01 00:00:00"},
+	}
+	got := effectiveTopo("F1", rows)
+	if len(got) != 2 || got[0].ID != "A" || got[0].FirstNodeID != "N2" || got[1].ID != "B" || got[1].FirstNodeID != "N2" {
+		t.Fatalf("unexpected topo %+v", got)
+	}
+
+	export := testExport()
+	export.Topo = append(export.Topo, first.Topo{ID: "B100", FirstNodeID: "N5", SecondNodeID: "N6"})
+	var out bytes.Buffer
+	if err := writeCIM(&out, export); err != nil {
+		t.Fatal(err)
+	}
+	_, index, err := util.DecodeCIMXML(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(index.IDNodeMap["PD_b1"], []string{"N1", "N2"}) {
+		t.Errorf("unexpected terminals %v", index.IDNodeMap["PD_b1"])
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"raselper/src/forwork/appconfig"
+	"strings"
+)
+
+// 用法: model diff [-format text|json] old.xml new.xml
+//
+//	model export [-o file] [-feeder id] [app.yaml]
+//
+//	diff    按rdf:ID对比同一馈线的两个单线图版本(图模异动), 不连接数据库
+//	export  按owner/馈线从库中读取设备和拓扑, 写出ParseCIMXML能读取的单线图xml, 只读
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "diff":
+		runDiff(os.Args[2:])
+	case "export":
+		runExport(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: model diff [-format text|json] old.xml new.xml")
+	fmt.Fprintln(os.Stderr, "      model export [-o file] [-feeder id] [app.yaml]")
+	os.Exit(2)
+}
+
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := flags.String("format", "text", "输出格式 text|json")
+	flags.Parse(args)
+	if flags.NArg() != 2 || (*format != "text" && *format != "json") {
+		usage()
+	}
+
+	diff, err := diffFiles(flags.Arg(0), flags.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diff); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printDiff(os.Stdout, diff)
+}
+
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	output := flags.String("o", "", "输出文件, 默认标准输出")
+	feeder := flags.String("feeder", "", "馈线ID, 默认取配置中的feeder")
+	flags.Parse(args)
+	configFile := "app.yaml"
+	if flags.NArg() > 0 {
+		configFile = flags.Arg(0)
+	}
+
+	config, err := appconfig.Read(configFile)
+	if err != nil {
+		log.Fatalf("读取配置失败: %v", err)
+	}
+	if *feeder == "" {
+		*feeder = config.Feeder
+	}
+	if *feeder == "" || strings.Contains(*feeder, ",") {
+		log.Fatal("需要指定一条馈线: -feeder 或配置中的feeder")
+	}
+	db, err := appconfig.OpenDB(config.DB)
+	if err != nil {
+		log.Fatal("连接数据库失败:", err)
+	}
+	export, err := queryFeederExport(db, config.DB.Database, config.Owner, *feeder)
+	if err != nil {
+		log.Fatalf("查询馈线失败: %v", err)
+	}
+	log.Printf("馈线 %s 共 %d 个设备, %d 条拓扑记录", *feeder, len(export.Devices), len(export.Topo))
+
+	out := os.Stdout
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			log.Fatal(err)
+		}
+		defer out.Close()
+	}
+	if err := writeCIM(out, export); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,11 +1,34 @@
 package data
 
-import "gorm.io/gorm"
+import (
+	"raselper/src/secondary/nodeid"
+	"sync"
+
+	"gorm.io/gorm"
+)
 
 var Config AppConfig
 var DB *gorm.DB
 var DB2 *gorm.DB
 
+var (
+	nodeIDs     nodeid.Allocator
+	nodeIDsErr  error
+	nodeIDsOnce sync.Once
+)
+
+// 节点ID分配器, 第一次使用时按Config.NodeID和DB创建, 各worker共用
+func NodeIDs() (nodeid.Allocator, error) {
+	nodeIDsOnce.Do(func() {
+		config := Config.NodeID
+		if config.Sequence == "" && config.Table == "" {
+			config.Table = Config.DB.Database + ".NODE_ID_RANGE"
+		}
+		nodeIDs, nodeIDsErr = nodeid.New(DB, config)
+	})
+	return nodeIDs, nodeIDsErr
+}
+
 var OwnerOrganMap = map[string]string{
 	"350100": "FZ",
 	"350200": "XM",
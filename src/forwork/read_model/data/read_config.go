@@ -3,6 +3,7 @@ package data
 import (
 	"fmt"
 	"io/ioutil"
+	"raselper/src/secondary/nodeid"
 
 	"gopkg.in/yaml.v3"
 )
@@ -16,11 +17,13 @@ type AppConfig struct {
 		Port string `yaml:"port"`
 		Host string `yaml:"host"`
 	} `yaml:"server"`
-	TargetURL string `yaml:"target_url"`
-	UpdateUrl string `yaml:"update-url"`
-	Slice     int    `yaml:"slice"`
-	Delete    bool   `yaml:"delete"`
-	BackPath  string `yaml:"back-path"`
+	TargetURL string         `yaml:"target_url"`
+	UpdateUrl string         `yaml:"update-url"`
+	Slice     int            `yaml:"slice"`
+	Delete    bool           `yaml:"delete"`
+	BackPath  string         `yaml:"back-path"`
+	NodeID    nodeid.Config  `yaml:"node-id"` // 新建连接节点的ID分配, 未配置时使用号段表 <database>.NODE_ID_RANGE
+	Devices   []DeviceConfig `yaml:"devices"` // 源端设备没有录入时的自动新增, 未配置时只新增低压线路(10100000)
 	Redis     struct {
 		Url      string `yaml:"url"`
 		Username string `yaml:"username"`
@@ -46,6 +49,18 @@ type DBConfig struct {
 	Database string `yaml:"database"` // 新增数据库名配置
 }
 
+// 一类源端设备的新增方式, 按CIM类和rdf:ID前缀匹配, 先配置的优先
+type DeviceConfig struct {
+	Class    string            `yaml:"class"`     // CIM类, 如 ACLineSegment
+	Prefixes []string          `yaml:"prefixes"`  // rdf:ID前缀, 为空时匹配该类所有设备
+	Sequence string            `yaml:"sequence"`  // ID序列, 不含模式名, {organ}替换为地市简称, 如 SG_DEV_LOWVOLLINE_B_{organ}_SEQ
+	Table    string            `yaml:"table"`     // 设备表, 如 SG_DEV_LOWVOLLINE_B
+	MapTable string            `yaml:"map-table"` // 源端ID映射表, 如 SG_DEV_LOWVOLLINE_C
+	TBName   string            `yaml:"tbname"`    // ID_MAP.TBNAME
+	Columns  map[string]string `yaml:"columns"`   // 设备表列 - CIM属性, 如 NAME: IdentifiedObject.name
+	Values   map[string]string `yaml:"values"`    // 设备表列 - 固定值
+}
+
 // ReadAppConfig 读取 app.yaml 配置文件
 func ReadAppConfig(filePath string) (*AppConfig, error) {
 	config := &AppConfig{}
@@ -93,22 +93,16 @@ func ReadOneFileAndDeal(sourcePath string) error {
 		}
 	}()
 
-	// 方法1: 使用解析
-	fmt.Println("=== 方法1: 解析 ===")
-	simpleRdf, err := util.ParseCIMXML(sourcePath)
-
-	if simpleRdf == nil {
+	// 流式解析, 包括RDF结构体没有映射的设备类型
+	model, err := util.ParseCIMFile(sourcePath)
+	if err != nil {
 		log.Println("xml read fail!")
 		return err
 	}
-
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-	} else {
-		fmt.Printf("解析成功\n")
-
-		fmt.Printf("\n统计信息:\n")
-		fmt.Printf("Terminal: %d\n", len(simpleRdf.Terminals))
+	simpleRdf := model.RDF
+	fmt.Printf("解析成功, Terminal: %d\n", len(simpleRdf.Terminals))
+	if unknown := model.UnknownClasses(); len(unknown) > 0 {
+		log.Printf("%s 未处理的类型: %v\n", sourcePath, unknown)
 	}
 
 	// 获取馈线id和主馈线标识
@@ -132,25 +126,29 @@ func ReadOneFileAndDeal(sourcePath string) error {
 		}
 	}
 
-	topoList := util.GetDeviceTopoMap(simpleRdf)
+	topoList := util.GetDeviceTopoMap(model)
 	fmt.Println("topoMap:", len(topoList))
 
 	cloud := util.GetTopoInfoInDCloud(topoList, circuitDCloudMap, owner)
 	fmt.Println("topoInfo:", len(cloud))
 
-	//util.HandleTopo(idNodeMap, nodeIdMap, topoList, rdfDCloudMap, nodeMap, deviceFeederMap, db, data.Config, owner, simpleRdf, circuitDCloudMap)
-	util.HandleDBTopo(cloud, circuitDCloudMap, owner, simpleRdf)
-	util.HandleMultiplyNode(cloud, circuitDCloudMap, owner, simpleRdf)
+	//util.HandleTopo(idNodeMap, nodeIdMap, topoList, rdfDCloudMap, nodeMap, deviceFeederMap, db, data.Config, owner, model, circuitDCloudMap)
+	if err := util.HandleDBTopo(cloud, circuitDCloudMap, owner, model); err != nil {
+		return fmt.Errorf("%s: %w", sourcePath, err)
+	}
+	if err := util.HandleMultiplyNode(cloud, circuitDCloudMap, owner, simpleRdf); err != nil {
+		return fmt.Errorf("%s: %w", sourcePath, err)
+	}
 	util.HandleEmptyTopo(cloud)
 	success := util.MainSubConnect(circuitDCloudMap, circuitMainFeederMap)
 	if !success {
-		success = util.MainSubConnectIfNotConnect(circuitDCloudMap, circuitMainFeederMap, cloud, simpleRdf) // 处理没有拼接成功的
+		success = util.MainSubConnectIfNotConnect(circuitDCloudMap, circuitMainFeederMap, cloud, model) // 处理没有拼接成功的
 	}
 	//if !success {
-	success = util.MainSubConnect_UseSourceInfo(circuitDCloudMap, circuitMainFeederMap, cloud, simpleRdf) // 用源端文件自己来主配拼接
+	success = util.MainSubConnect_UseSourceInfo(circuitDCloudMap, circuitMainFeederMap, cloud, model) // 用源端文件自己来主配拼接
 	//}
-	//success = util.MainSubConnect_UseSourceInfo(circuitDCloudMap, circuitMainFeederMap, cloud, simpleRdf) // 用源端文件自己来主配拼接
-	//util.ConnectMultiplyNode(simpleRdf, owner)
+	//success = util.MainSubConnect_UseSourceInfo(circuitDCloudMap, circuitMainFeederMap, cloud, model) // 用源端文件自己来主配拼接
+	//util.ConnectMultiplyNode(model.Topo, owner)
 
 	// 提示图库程序更新图库馈线
 	for _, circuit := range simpleRdf.Circuits {
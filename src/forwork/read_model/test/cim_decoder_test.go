@@ -0,0 +1,162 @@
+package test
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"raselper/src/forwork/read_model/util"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleFiles(tb testing.TB) []string {
+	files, _ := filepath.Glob("../../data/*.xml")
+	if len(files) == 0 {
+		tb.Skip("no sample files")
+	}
+	return files
+}
+
+// 原来的解析方式: 整个文件读入后Unmarshal
+func unmarshalCIMXML(file string) (*util.RDF, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var rdf util.RDF
+	if err := xml.Unmarshal(data, &rdf); err != nil {
+		return nil, err
+	}
+	return &rdf, nil
+}
+
+func TestDecodeCIMXML(t *testing.T) {
+	for _, file := range sampleFiles(t) {
+		expected, err := unmarshalCIMXML(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rdf, index, err := util.ParseCIMXMLIndex(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(rdf, expected) {
+			t.Errorf("%s: RDF mismatch", file)
+		}
+
+		idNodeMap, nodeIdMap, deviceFeederMap := util.GetTopoMap(expected)
+		if !reflect.DeepEqual(index.IDNodeMap, idNodeMap) ||
+			!reflect.DeepEqual(index.NodeIDMap, nodeIdMap) ||
+			!reflect.DeepEqual(index.DeviceFeederMap, deviceFeederMap) {
+			t.Errorf("%s: index mismatch", file)
+		}
+	}
+}
+
+func TestDecodeCIMXMLTerminalFirst(t *testing.T) {
+	content := `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:cim="http://www.sgcc.com.cn/SG-CIM/2010MAY#">
+<cim:Terminal rdf:ID="t1"><cim:Terminal.ConductingEquipment rdf:resource="#b1"/><cim:Terminal.ConnectivityNode rdf:resource="#n1"/></cim:Terminal>
+<cim:Terminal rdf:ID="t2"><cim:Terminal.ConductingEquipment rdf:resource="#x1"/><cim:Terminal.ConnectivityNode rdf:resource="#n1"/></cim:Terminal>
+<cim:LoadBreakSwitch rdf:ID="s1"><cim:IdentifiedObject.name>跳过</cim:IdentifiedObject.name></cim:LoadBreakSwitch>
+<cim:Breaker rdf:ID="b1"><cim:PowerSystemResource.Circuit rdf:resource="#F1"/></cim:Breaker>
+</rdf:RDF>`
+	rdf, index, err := util.DecodeCIMXML(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rdf.Terminals) != 2 || len(rdf.Breakers) != 1 {
+		t.Fatalf("unexpected rdf %+v", rdf)
+	}
+	if !reflect.DeepEqual(index.IDNodeMap, map[string][]string{"b1": {"n1"}}) ||
+		index.DeviceFeederMap["b1"] != "#F1" ||
+		!reflect.DeepEqual(index.Passed(), []string{"x1"}) {
+		t.Fatalf("unexpected index %+v", index)
+	}
+
+	for _, content := range []string{"", "<Other/>", "<rdf:RDF><cim:Breaker>"} {
+		if _, _, err := util.DecodeCIMXML(strings.NewReader(content)); err == nil {
+			t.Errorf("%q: expected error", content)
+		}
+	}
+}
+
+// 解析单个文件时堆的峰值, 后台每毫秒采样一次HeapAlloc, 包括解析结果
+func peakHeap(parse func() error) (uint64, error) {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	base := stats.HeapAlloc
+
+	done := make(chan struct{})
+	peak := make(chan uint64)
+	go func() {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		var max uint64
+		for {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > max {
+				max = stats.HeapAlloc
+			}
+			select {
+			case <-done:
+				peak <- max
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	err := parse()
+	close(done)
+	max := <-peak
+	if max < base {
+		return 0, err
+	}
+	return max - base, err
+}
+
+// 除B/op外按文件报告堆峰值peak-heap-B, 采样会拖慢ns/op
+func benchmarkParse(b *testing.B, parse func(file string) error) {
+	files := sampleFiles(b)
+	b.ReportAllocs()
+	var max uint64
+	for i := 0; i < b.N; i++ {
+		for _, file := range files {
+			peak, err := peakHeap(func() error { return parse(file) })
+			if err != nil {
+				b.Fatal(err)
+			}
+			if peak > max {
+				max = peak
+			}
+		}
+	}
+	b.ReportMetric(float64(max), "peak-heap-B")
+}
+
+// go test ./src/forwork/read_model/test -run xxx -bench CIM -benchmem
+func BenchmarkUnmarshalCIMXML(b *testing.B) {
+	benchmarkParse(b, func(file string) error {
+		_, err := unmarshalCIMXML(file)
+		return err
+	})
+}
+
+func BenchmarkParseCIMXML(b *testing.B) {
+	benchmarkParse(b, func(file string) error {
+		_, _, err := util.ParseCIMXMLIndex(file)
+		return err
+	})
+}
+
+// 通用模型保留所有对象, 只在模型对比等需要全部属性时使用
+func BenchmarkParseCIMModel(b *testing.B) {
+	benchmarkParse(b, func(file string) error {
+		_, err := util.ParseCIMModel(file)
+		return err
+	})
+}
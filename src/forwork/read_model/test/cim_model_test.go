@@ -0,0 +1,167 @@
+package test
+
+import (
+	"path/filepath"
+	"raselper/src/forwork/read_model/util"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 通用模型转换的RDF与xml.Unmarshal的结果一致
+func TestCIMModelRDF(t *testing.T) {
+	files, _ := filepath.Glob("../../data/*.xml")
+	if len(files) == 0 {
+		t.Skip("no sample files")
+	}
+	for _, file := range files {
+		expected, err := util.ParseCIMXML(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		model, err := util.ParseCIMModel(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(model.RDF(), expected) {
+			t.Errorf("%s: RDF mismatch", file)
+		}
+		if len(model.UnknownClasses()) != 0 {
+			t.Errorf("%s: unexpected unknown classes %v", file, model.UnknownClasses())
+		}
+		cimFile, err := util.ParseCIMFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(cimFile.RDF, expected) {
+			t.Errorf("%s: CIMFile RDF mismatch", file)
+		}
+		if expected.Circuits[0].Name == "" || expected.BaseVoltages[0].NominalVoltage == "" {
+			t.Errorf("%s: empty name or voltage", file)
+		}
+	}
+}
+
+const testCIM = `<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:cim="http://www.sgcc.com.cn/SG-CIM/2010MAY#" xmlns:iescim="http://www.ieslab.com.cn">
+<cim:Circuit rdf:ID="F1"><cim:IdentifiedObject.name>馈线</cim:IdentifiedObject.name></cim:Circuit>
+<cim:Breaker rdf:ID="b1"><cim:IdentifiedObject.name>开关</cim:IdentifiedObject.name><cim:PowerSystemResource.Circuit rdf:resource="#F1"/></cim:Breaker>
+<cim:LoadBreakSwitch rdf:ID="s1">
+  <cim:IdentifiedObject.name>负荷开关</cim:IdentifiedObject.name>
+  <cim:PowerSystemResource.Circuit rdf:resource="#F1"/>
+  <iescim:PowerSystemResource.DeviceType>13</iescim:PowerSystemResource.DeviceType>
+</cim:LoadBreakSwitch>
+<cim:EnergyConsumer rdf:ID="c1"><cim:PowerSystemResource.Circuit rdf:resource="#F1"/></cim:EnergyConsumer>
+<cim:ShuntCompensator rdf:ID="x1"/>
+<iescim:Station rdf:ID="x2"><cim:IdentifiedObject.name>扩展</cim:IdentifiedObject.name><iescim:Nested><a>1</a></iescim:Nested></iescim:Station>
+<cim:Terminal rdf:ID="t1"><cim:Terminal.ConductingEquipment rdf:resource="#s1"/><cim:Terminal.ConnectivityNode rdf:resource="#n1"/></cim:Terminal>
+</rdf:RDF>`
+
+func TestDecodeCIMModel(t *testing.T) {
+	model, err := util.DecodeCIMModel(strings.NewReader(testCIM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(model.Objects) != 7 {
+		t.Fatalf("unexpected objects %d", len(model.Objects))
+	}
+
+	s1 := model.Object("s1")
+	if s1 == nil || s1.Class != "LoadBreakSwitch" || s1.Value("IdentifiedObject.name") != "负荷开关" ||
+		s1.Resource("PowerSystemResource.Circuit") != "#F1" || s1.Value("PowerSystemResource.DeviceType") != "13" {
+		t.Fatalf("unexpected object %+v", s1)
+	}
+	if len(model.Class("Terminal")) != 1 || model.Object("missing") != nil {
+		t.Fatal("unexpected class index")
+	}
+
+	unknown := model.UnknownClasses()
+	if !reflect.DeepEqual(unknown, map[string]int{"cim:ShuntCompensator": 1, "iescim:Station": 1}) {
+		t.Fatalf("unexpected unknown classes %v", unknown)
+	}
+	if model.Classes()["cim:Terminal"] != 1 {
+		t.Fatalf("unexpected classes %v", model.Classes())
+	}
+
+	rdf := model.RDF()
+	if len(rdf.Breakers) != 1 || len(rdf.Circuits) != 1 || len(rdf.Terminals) != 1 {
+		t.Fatalf("unexpected rdf %+v", rdf)
+	}
+}
+
+// 流式解析不保留通用对象, 结果与通用模型一致
+func TestDecodeCIMFile(t *testing.T) {
+	file, err := util.DecodeCIMFile(strings.NewReader(testCIM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := util.DecodeCIMModel(strings.NewReader(testCIM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdf := model.RDF()
+	rdf.XMLName = file.RDF.XMLName
+	if !reflect.DeepEqual(file.RDF, rdf) {
+		t.Fatalf("unexpected rdf %+v", file.RDF)
+	}
+
+	equipments := file.Equipments()
+	var ids []string
+	for _, e := range equipments {
+		ids = append(ids, e.Class+":"+e.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"Breaker:b1", "LoadBreakSwitch:s1", "EnergyConsumer:c1"}) {
+		t.Fatalf("unexpected equipments %v", ids)
+	}
+	if equipments[1].Name != "负荷开关" || equipments[1].Circuit != "#F1" || equipments[1].DeviceType != "13" {
+		t.Fatalf("unexpected equipment %+v", equipments[1])
+	}
+	if !reflect.DeepEqual(file.UnknownClasses(), model.UnknownClasses()) {
+		t.Fatalf("unexpected unknown classes %v", file.UnknownClasses())
+	}
+
+	topo := file.Topo
+	if !reflect.DeepEqual(topo.IDNodeMap, map[string][]string{"s1": {"n1"}}) || topo.DeviceFeederMap["c1"] != "#F1" || len(topo.Passed()) != 0 {
+		t.Fatalf("unexpected topo index %+v", topo)
+	}
+
+	for _, content := range []string{"", "<rdf:RDF><cim:Breaker>", "<Other/>"} {
+		if _, err := util.DecodeCIMFile(strings.NewReader(content)); err == nil {
+			t.Errorf("%q: expected error", content)
+		}
+	}
+}
+
+func TestDecodeCIMModelError(t *testing.T) {
+	for _, content := range []string{"", "<rdf:RDF><cim:Breaker>", "<Other/>"} {
+		if _, err := util.DecodeCIMModel(strings.NewReader(content)); err == nil {
+			t.Errorf("%q: expected error", content)
+		}
+	}
+}
+
+func TestGetDeviceTopoMap(t *testing.T) {
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:cim="http://www.sgcc.com.cn/SG-CIM/2010MAY#">
+<cim:Circuit rdf:ID="F1"><cim:iscurrentfeeder>1</cim:iscurrentfeeder></cim:Circuit>
+<cim:Circuit rdf:ID="F2"/>
+<cim:LoadBreakSwitch rdf:ID="s1"><cim:PowerSystemResource.Circuit rdf:resource="#F1"/></cim:LoadBreakSwitch>
+<cim:Breaker rdf:ID="b2"><cim:PowerSystemResource.Circuit rdf:resource="#F2"/></cim:Breaker>
+<cim:EnergyConsumer rdf:ID="c1"><cim:PowerSystemResource.Circuit rdf:resource="#F1"/></cim:EnergyConsumer>
+<cim:Terminal rdf:ID="t1"><cim:Terminal.ConductingEquipment rdf:resource="#s1"/><cim:Terminal.ConnectivityNode rdf:resource="#n1"/></cim:Terminal>
+<cim:Terminal rdf:ID="t2"><cim:Terminal.ConductingEquipment rdf:resource="#s1"/><cim:Terminal.ConnectivityNode rdf:resource="#n2"/></cim:Terminal>
+<cim:Terminal rdf:ID="t3"><cim:Terminal.ConductingEquipment rdf:resource="#b2"/><cim:Terminal.ConnectivityNode rdf:resource="#n2"/></cim:Terminal>
+</rdf:RDF>`
+	model, err := util.DecodeCIMFile(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 非主馈线和没有端子的设备跳过
+	topoList := util.GetDeviceTopoMap(model)
+	if len(topoList) != 1 || topoList[0].SourceID != "s1" || topoList[0].SourceFeeder != "F1" || len(topoList[0].SourceNode) != 2 {
+		t.Fatalf("unexpected topo %+v", topoList)
+	}
+	if topoList[0].SourceNode[1].ConnectivityNode.Resource != "#n2" {
+		t.Fatalf("unexpected terminals %+v", topoList[0].SourceNode)
+	}
+}
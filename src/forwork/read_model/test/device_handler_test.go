@@ -0,0 +1,146 @@
+package test
+
+import (
+	"raselper/src/forwork/read_model/data"
+	"raselper/src/forwork/read_model/util"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const devicesYAML = `
+devices:
+  - class: Breaker
+    prefixes: [30500000, 30500099]
+    sequence: SG_DEV_DLOADSWITCH_B_{organ}_SEQ
+    table: SG_DEV_DLOADSWITCH_B
+    map-table: SG_DEV_DLOADSWITCH_C
+    tbname: loadbreakswitch
+    columns:
+      NAME: IdentifiedObject.name
+      FEEDER_ID: PowerSystemResource.Circuit
+      VOLTAGE_TYPE: ConductingEquipment.BaseVoltage
+    values:
+      RUNNING_STATE: "1003"
+      OWNER: "x"
+  - class: Breaker
+    sequence: SG_DEV_DBREAKER_B_SEQ
+    table: SG_DEV_DBREAKER_B
+    map-table: SG_DEV_DBREAKER_C
+    tbname: breaker
+`
+
+func TestDeviceHandlers(t *testing.T) {
+	var config data.AppConfig
+	if err := yaml.Unmarshal([]byte(devicesYAML), &config); err != nil {
+		t.Fatal(err)
+	}
+	handlers, err := util.NewDeviceHandlers(config.Devices)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h := util.FindDeviceHandler(handlers, "Breaker", "30500099_1"); h == nil || h.Table != "SG_DEV_DLOADSWITCH_B" {
+		t.Errorf("unexpected handler %+v", h)
+	}
+	if h := util.FindDeviceHandler(handlers, "Breaker", "11100000_1"); h == nil || h.Table != "SG_DEV_DBREAKER_B" {
+		t.Errorf("unexpected handler %+v", h)
+	}
+	if h := util.FindDeviceHandler(handlers, "Fuse", "30500000_1"); h != nil {
+		t.Errorf("unexpected handler %+v", h)
+	}
+
+	sequence, err := handlers[0].SequenceName("DKYPW", "350900")
+	if err != nil || sequence != "DKYPW.SG_DEV_DLOADSWITCH_B_ND_SEQ" {
+		t.Errorf("unexpected sequence %s %v", sequence, err)
+	}
+	if _, err := handlers[0].SequenceName("DKYPW", "999999"); err == nil {
+		t.Error("expected error for unknown owner")
+	}
+	if sequence, _ := handlers[1].SequenceName("DKYPW", "999999"); sequence != "DKYPW.SG_DEV_DBREAKER_B_SEQ" {
+		t.Errorf("unexpected sequence %s", sequence)
+	}
+
+	device := util.Equipment{ID: "30500000_1", Class: "Breaker", Name: "负荷开关", Circuit: "#F1", BaseVoltage: "#BV10"}
+	records := handlers[0].Records("100", device, "350900", "F100", time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local))
+	want := map[string]interface{}{
+		"ID":            "100",
+		"NAME":          "负荷开关",
+		"FEEDER_ID":     "F100", // 公共列不被覆盖
+		"OWNER":         "350900",
+		"VOLTAGE_TYPE":  "BV10",
+		"RUNNING_STATE": "1003",
+		"DCC_ID":        "0021350900",
+		"STAMP":         "350000_00613500000001_2026-01-02 03:04:05",
+	}
+	for column, value := range want {
+		if records.Device[column] != value {
+			t.Errorf("%s = %v, want %v", column, records.Device[column], value)
+		}
+	}
+	if len(records.Device) != len(want) {
+		t.Errorf("unexpected device columns %v", records.Device)
+	}
+	if records.IDMap["TBNAME"] != "loadbreakswitch" || records.IDMap["RDF_ID"] != "30500000_1" {
+		t.Errorf("unexpected ID_MAP %v", records.IDMap)
+	}
+	if records.Map["DCLOUD_ID"] != "100" || records.Map["PMS_RDF_ID"] != "30500000_1" {
+		t.Errorf("unexpected map %v", records.Map)
+	}
+}
+
+func TestDeviceHandlersInvalid(t *testing.T) {
+	valid := util.DefaultDeviceConfigs[0]
+	cases := map[string]func(c *data.DeviceConfig){
+		"不支持":  func(c *data.DeviceConfig) { c.Class = "ShuntCompensator" },
+		"不能为空": func(c *data.DeviceConfig) { c.MapTable = "" },
+		"不存在":  func(c *data.DeviceConfig) { c.Columns = map[string]string{"NAME": "IdentifiedObject.nam"} },
+	}
+	for message, change := range cases {
+		config := valid
+		change(&config)
+		if _, err := util.NewDeviceHandlers([]data.DeviceConfig{config}); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("%s: unexpected error %v", message, err)
+		}
+	}
+	if _, err := util.NewDeviceHandlers(util.DefaultDeviceConfigs); err != nil {
+		t.Error(err)
+	}
+	// RDF结构体中没有的导电设备类也可以配置
+	for _, class := range []string{"LoadBreakSwitch", "GroundDisconnector", "EnergyConsumer", "Compensator", "Junction"} {
+		config := valid
+		config.Class = class
+		if _, err := util.NewDeviceHandlers([]data.DeviceConfig{config}); err != nil {
+			t.Errorf("%s: %v", class, err)
+		}
+	}
+}
+
+func TestModelEquipment(t *testing.T) {
+	model, err := util.ParseCIMFile("../../data/西农线922线路_单线图_10000100@7606_20251106110425.xml")
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, fuse := range model.RDF.Fuses {
+		device, ok := model.Equipment(fuse.ID)
+		if !ok || device.Class != "Fuse" || device.Name != fuse.Name || device.Attribute("PowerSystemResource.Circuit") != strings.TrimPrefix(fuse.Circuit.Resource, "#") {
+			t.Errorf("unexpected equipment %+v", device)
+		}
+	}
+	if _, ok := model.Equipment("not-exist"); ok {
+		t.Error("expected not found")
+	}
+
+	model, err = util.DecodeCIMFile(strings.NewReader(testCIM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device, ok := model.Equipment("s1"); !ok || device.Class != "LoadBreakSwitch" || device.Name != "负荷开关" || device.Attribute("PowerSystemResource.Circuit") != "F1" {
+		t.Errorf("unexpected equipment %+v", device)
+	}
+	if _, ok := model.Equipment("t1"); ok {
+		t.Error("terminal is not equipment")
+	}
+}
@@ -0,0 +1,228 @@
+package util
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// 解析时增量建立的拓扑索引, RDF结构体中有的设备与GetTopoMap相同, 另外还包括负荷开关、用户等
+type TopoIndex struct {
+	IDNodeMap       map[string][]string // 设备 - 节点
+	NodeIDMap       map[string][]string // 节点 - 设备
+	DeviceFeederMap map[string]string   // 设备 - 馈线(带#号)
+
+	pending map[string][]string // 设备还没出现的端子, 设备 - 节点
+}
+
+func newTopoIndex() *TopoIndex {
+	return &TopoIndex{
+		IDNodeMap:       make(map[string][]string),
+		NodeIDMap:       make(map[string][]string),
+		DeviceFeederMap: make(map[string]string),
+		pending:         make(map[string][]string),
+	}
+}
+
+func (index *TopoIndex) addDevice(id, feeder string) {
+	index.DeviceFeederMap[id] = feeder
+	for _, node := range index.pending[id] {
+		index.addNode(id, node)
+	}
+	delete(index.pending, id)
+}
+
+func (index *TopoIndex) addTerminal(terminal Terminal) {
+	id := strings.Replace(terminal.ConductingEquipment.Resource, "#", "", -1)
+	node := strings.Replace(terminal.ConnectivityNode.Resource, "#", "", -1)
+	if _, ok := index.DeviceFeederMap[id]; !ok {
+		index.pending[id] = append(index.pending[id], node)
+		return
+	}
+	index.addNode(id, node)
+}
+
+func (index *TopoIndex) addNode(id, node string) {
+	index.IDNodeMap[id] = append(index.IDNodeMap[id], node)
+	index.NodeIDMap[node] = append(index.NodeIDMap[node], id)
+}
+
+// 端子连接的设备在文件中没有定义, GetTopoMap中会跳过
+func (index *TopoIndex) Passed() []string {
+	ids := make([]string, 0, len(index.pending))
+	for id := range index.pending {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// 流式解析的结果, 只保留RDF结构体、参与拓扑的设备和拓扑索引, 不保留通用对象
+type CIMFile struct {
+	RDF     *RDF
+	Topo    *TopoIndex
+	Devices []Equipment // 参与拓扑的设备, 按文件顺序, 包括RDF结构体中没有的类型
+
+	devices map[string]int // rdf:ID - Devices下标
+	unknown map[string]int // 没有类型访问方法的类及数量
+}
+
+// 流式解析XML文件
+func ParseCIMFile(filePath string) (*CIMFile, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return DecodeCIMFile(file)
+}
+
+// 逐个对象转换为RDF结构体并建立拓扑索引, 对象用完即丢弃
+func DecodeCIMFile(r io.Reader) (*CIMFile, error) {
+	file := &CIMFile{
+		RDF:     &RDF{},
+		Topo:    newTopoIndex(),
+		devices: make(map[string]int),
+		unknown: make(map[string]int),
+	}
+	root, err := decodeCIMObjects(r, file.add)
+	if err != nil {
+		return nil, err
+	}
+	file.RDF.XMLName = root
+	return file, nil
+}
+
+func (f *CIMFile) add(object *CIMObject) {
+	if !knownClasses[object.Class] {
+		f.unknown[object.QualifiedClass()]++
+		return
+	}
+	f.RDF.add(object)
+	switch {
+	case topoDeviceClasses[object.Class]:
+		f.devices[object.ID] = len(f.Devices)
+		f.Devices = append(f.Devices, object.equipment())
+		f.Topo.addDevice(object.ID, object.Resource("PowerSystemResource.Circuit"))
+	case object.Class == "Terminal":
+		f.Topo.addTerminal(object.terminal())
+	}
+}
+
+// 没有类型访问方法的类及数量, 包括iescim扩展
+func (f *CIMFile) UnknownClasses() map[string]int {
+	return f.unknown
+}
+
+// 所有导电设备, 按文件顺序
+func (f *CIMFile) Equipments() []Equipment {
+	var list []Equipment
+	for _, device := range f.Devices {
+		if isEquipmentClass[device.Class] {
+			list = append(list, device)
+		}
+	}
+	return list
+}
+
+// 按rdf:ID查找导电设备, 包括RDF结构体中没有的类型
+func (f *CIMFile) Equipment(id string) (Equipment, bool) {
+	i, ok := f.devices[id]
+	if !ok || !isEquipmentClass[f.Devices[i].Class] {
+		return Equipment{}, false
+	}
+	return f.Devices[i], true
+}
+
+// 流式解析XML文件, 同时建立拓扑索引
+func ParseCIMXMLIndex(filePath string) (*RDF, *TopoIndex, error) {
+	file, err := ParseCIMFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file.RDF, file.Topo, nil
+}
+
+// 不需要把整个文件读入内存, 结果与xml.Unmarshal相同, 见DecodeCIMFile
+//
+// 设备在端子之后出现时, 节点-设备列表的顺序按设备出现的顺序
+func DecodeCIMXML(r io.Reader) (*RDF, *TopoIndex, error) {
+	file, err := DecodeCIMFile(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file.RDF, file.Topo, nil
+}
+
+// 逐个token读取, 第二层元素为对象, 第三层元素为属性, 更深的元素忽略;
+// 每读完一个对象调用一次handle, 返回根元素名
+func decodeCIMObjects(r io.Reader, handle func(*CIMObject)) (xml.Name, error) {
+	decoder := xml.NewDecoder(r)
+	var rootName xml.Name
+	depth, root := 0, false
+	var object *CIMObject
+	var property *CIMProperty
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return rootName, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch depth {
+			case 1:
+				if t.Name.Local != "RDF" {
+					return rootName, fmt.Errorf("expected element type <RDF> but have <%s>", t.Name.Local)
+				}
+				rootName, root = t.Name, true
+			case 2:
+				object = &CIMObject{Space: t.Name.Space, Class: t.Name.Local, ID: attr(t, "ID")}
+				if object.ID == "" {
+					if about := attr(t, "about"); len(about) > 0 && about[0] == '#' {
+						object.ID = about[1:]
+					} else {
+						object.ID = about
+					}
+				}
+			case 3:
+				property = &CIMProperty{Space: t.Name.Space, Name: t.Name.Local, Resource: attr(t, "resource")}
+			}
+		case xml.CharData:
+			if depth == 3 {
+				property.Value += string(t)
+			}
+		case xml.EndElement:
+			switch depth {
+			case 2:
+				handle(object)
+			case 3:
+				object.Properties = append(object.Properties, *property)
+			}
+			depth--
+		}
+	}
+	if !root {
+		return rootName, io.EOF
+	}
+	if depth != 0 {
+		return rootName, io.ErrUnexpectedEOF
+	}
+	return rootName, nil
+}
+
+// 不区分命名空间取属性
+func attr(element xml.StartElement, name string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
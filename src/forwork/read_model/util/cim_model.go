@@ -0,0 +1,281 @@
+package util
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+)
+
+// 通用CIM对象, 保留文件中rdf:RDF下的所有元素, 包括RDF结构体没有映射的类型
+type CIMObject struct {
+	Space      string // 命名空间
+	Class      string // 类名, 如 Breaker
+	ID         string // rdf:ID, 没有时取rdf:about
+	Properties []CIMProperty
+}
+
+// 对象的一个属性, 如 <cim:PowerSystemResource.Circuit rdf:resource="#..."/>
+type CIMProperty struct {
+	Space    string
+	Name     string // 如 IdentifiedObject.name
+	Value    string
+	Resource string // rdf:resource, 带#号
+}
+
+// 按属性名取值, 不区分命名空间, 与RDF结构体的xml标签一致
+func (o *CIMObject) Value(name string) string {
+	if p := o.property(name); p != nil {
+		return p.Value
+	}
+	return ""
+}
+
+// 按属性名取引用, 带#号
+func (o *CIMObject) Resource(name string) string {
+	if p := o.property(name); p != nil {
+		return p.Resource
+	}
+	return ""
+}
+
+// 同名属性出现多次时取最后一个, 与xml.Unmarshal一致
+func (o *CIMObject) property(name string) *CIMProperty {
+	for i := len(o.Properties) - 1; i >= 0; i-- {
+		if o.Properties[i].Name == name {
+			return &o.Properties[i]
+		}
+	}
+	return nil
+}
+
+// 带前缀的类名, 如 cim:Breaker, iescim:Xxx
+func (o *CIMObject) QualifiedClass() string {
+	switch o.Space {
+	case CIMNS:
+		return "cim:" + o.Class
+	case IESNS:
+		return "iescim:" + o.Class
+	case RDFNS:
+		return "rdf:" + o.Class
+	case "":
+		return o.Class
+	}
+	return o.Space + " " + o.Class
+}
+
+// 导电设备类型, 按此顺序输出; 后五种RDF结构体中没有, 只能通过CIMModel取到
+var equipmentClasses = []string{
+	"Breaker", "Disconnector", "Fuse", "PowerTransformer", "BusbarSection", "ACLineSegment",
+	"LoadBreakSwitch", "GroundDisconnector", "EnergyConsumer", "Compensator", "Junction",
+}
+
+// 有类型访问方法的类, 与命名空间无关
+var knownClasses = map[string]bool{
+	"BaseVoltage": true, "SubGeographicalRegion": true, "Circuit": true, "Substation": true,
+	"Pole": true, "Faultindicator": true, "ConnectivityNode": true, "Terminal": true,
+}
+
+// 参与拓扑的设备类型, 导电设备加上电杆和故障指示器
+var topoDeviceClasses = map[string]bool{"Pole": true, "Faultindicator": true}
+
+var isEquipmentClass = make(map[string]bool, len(equipmentClasses))
+
+func init() {
+	for _, class := range equipmentClasses {
+		knownClasses[class] = true
+		topoDeviceClasses[class] = true
+		isEquipmentClass[class] = true
+	}
+}
+
+// 通用CIM模型, 保留所有对象, 只导入拓扑时用CIMFile
+type CIMModel struct {
+	Objects []*CIMObject // 按文件顺序
+	byID    map[string]*CIMObject
+	byClass map[string][]*CIMObject
+}
+
+// 解析XML文件为通用模型
+func ParseCIMModel(filePath string) (*CIMModel, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return DecodeCIMModel(file)
+}
+
+// 读取所有对象, 见decodeCIMObjects
+func DecodeCIMModel(r io.Reader) (*CIMModel, error) {
+	model := &CIMModel{
+		byID:    make(map[string]*CIMObject),
+		byClass: make(map[string][]*CIMObject),
+	}
+	if _, err := decodeCIMObjects(r, model.add); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func (m *CIMModel) add(object *CIMObject) {
+	m.Objects = append(m.Objects, object)
+	if object.ID != "" {
+		m.byID[object.ID] = object
+	}
+	m.byClass[object.Class] = append(m.byClass[object.Class], object)
+}
+
+// 按rdf:ID查找对象
+func (m *CIMModel) Object(id string) *CIMObject {
+	return m.byID[id]
+}
+
+// 某个类的所有对象, 按文件顺序
+func (m *CIMModel) Class(class string) []*CIMObject {
+	return m.byClass[class]
+}
+
+// 各类对象的数量, 键为带前缀的类名
+func (m *CIMModel) Classes() map[string]int {
+	counts := make(map[string]int)
+	for _, object := range m.Objects {
+		counts[object.QualifiedClass()]++
+	}
+	return counts
+}
+
+// 没有类型访问方法的类及数量, 包括iescim扩展
+func (m *CIMModel) UnknownClasses() map[string]int {
+	counts := make(map[string]int)
+	for _, object := range m.Objects {
+		if !knownClasses[object.Class] {
+			counts[object.QualifiedClass()]++
+		}
+	}
+	return counts
+}
+
+// 导电设备的通用视图, 包含RDF结构体中没有的类型
+type Equipment struct {
+	ID                 string
+	Class              string
+	Name               string
+	Bianhao            string
+	Circuit            string // 带#号
+	EquipmentContainer string // 带#号
+	BaseVoltage        string // 带#号
+	PowerSystemResource
+}
+
+func (o *CIMObject) equipment() Equipment {
+	return Equipment{
+		ID:                  o.ID,
+		Class:               o.Class,
+		Name:                o.Value("IdentifiedObject.name"),
+		Bianhao:             o.Value("IdentifiedObject.bianhao"),
+		Circuit:             o.Resource("PowerSystemResource.Circuit"),
+		EquipmentContainer:  o.Resource("Equipment.EquipmentContainer"),
+		BaseVoltage:         o.Resource("ConductingEquipment.BaseVoltage"),
+		PowerSystemResource: o.powerSystemResource(),
+	}
+}
+
+// 转换为RDF结构体, 结果与ParseCIMXML相同
+func (m *CIMModel) RDF() *RDF {
+	rdf := &RDF{XMLName: xml.Name{Space: RDFNS, Local: "RDF"}}
+	for _, o := range m.Objects {
+		rdf.add(o)
+	}
+	return rdf
+}
+
+// 按类型追加到对应的列表, 其他类型忽略
+func (rdf *RDF) add(o *CIMObject) {
+	switch o.Class {
+	case "BaseVoltage":
+		rdf.BaseVoltages = append(rdf.BaseVoltages, BaseVoltage{
+			ID:             o.ID,
+			Name:           o.Value("IdentifiedObject.name"),
+			IsDC:           o.Value("BaseVoltage.isDC"),
+			NominalVoltage: o.Value("BaseVoltage.nominalVoltage"),
+			DeviceID:       o.Value("PowerSystemResource.DeviceID"),
+		})
+	case "SubGeographicalRegion":
+		region := SubGeographicalRegion{ID: o.ID, Name: o.Value("IdentifiedObject.name")}
+		region.Region.Resource = o.Resource("Region")
+		rdf.SubGeographicalRegions = append(rdf.SubGeographicalRegions, region)
+	case "Circuit":
+		circuit := Circuit{ID: o.ID, Name: o.Value("IdentifiedObject.name"), IsCurrentFeeder: o.Value("iscurrentfeeder")}
+		circuit.BelongtoHVSubstation.Resource = o.Resource("Circuit.BelongtoHVSubstation")
+		circuit.SubGeographicalRegion.Resource = o.Resource("PowerSystemResource.SubGeographicalRegion")
+		rdf.Circuits = append(rdf.Circuits, circuit)
+	case "Substation":
+		substation := Substation{ID: o.ID, Name: o.Value("IdentifiedObject.name"), PowerSystemResource: o.powerSystemResource()}
+		substation.PSRType.Resource = o.Resource("PowerSystemResource.PSRType")
+		substation.SubGeographicalRegion.Resource = o.Resource("PowerSystemResource.SubGeographicalRegion")
+		rdf.Substations = append(rdf.Substations, substation)
+	case "Breaker":
+		rdf.Breakers = append(rdf.Breakers, o.breaker())
+	case "Disconnector":
+		rdf.Disconnectors = append(rdf.Disconnectors, Disconnector(o.breaker()))
+	case "Fuse":
+		rdf.Fuses = append(rdf.Fuses, Fuse(o.breaker()))
+	case "PowerTransformer":
+		rdf.PowerTransformers = append(rdf.PowerTransformers, PowerTransformer(o.breaker()))
+	case "BusbarSection":
+		rdf.BusbarSections = append(rdf.BusbarSections, BusbarSection(o.breaker()))
+	case "ACLineSegment":
+		rdf.ACLineSegments = append(rdf.ACLineSegments, ACLineSegment(o.breaker()))
+	case "Pole":
+		rdf.Poles = append(rdf.Poles, o.pole())
+	case "Faultindicator":
+		rdf.FaultIndicators = append(rdf.FaultIndicators, FaultIndicator(o.pole()))
+	case "ConnectivityNode":
+		rdf.ConnectivityNodes = append(rdf.ConnectivityNodes, ConnectivityNode{ID: o.ID, PowerSystemResource: o.powerSystemResource()})
+	case "Terminal":
+		rdf.Terminals = append(rdf.Terminals, o.terminal())
+	}
+}
+
+func (o *CIMObject) identifiedObject() IdentifiedObject {
+	return IdentifiedObject{Name: o.Value("IdentifiedObject.name"), Bianhao: o.Value("IdentifiedObject.bianhao")}
+}
+
+func (o *CIMObject) powerSystemResource() PowerSystemResource {
+	return PowerSystemResource{
+		DeviceType:   o.Value("PowerSystemResource.DeviceType"),
+		SubType:      o.Value("PowerSystemResource.SubType"),
+		DeviceID:     o.Value("PowerSystemResource.DeviceID"),
+		MaintainTeam: o.Value("PowerSystemResource.MaintainTeam"),
+		PoleID:       o.Value("PowerSystemResource.PoleID"),
+		IsYK:         o.Value("PowerSystemResource.IsYK"),
+		UserType:     o.Value("PowerSystemResource.UserType"),
+		TaiQuHao:     o.Value("PowerSystemResource.TaiQuHao"),
+		Cxmc:         o.Value("PowerSystemResource.Cxmc"),
+	}
+}
+
+// 断路器、隔离开关、熔断器、变压器、母线、线路段的字段相同, 按Breaker填充后转换
+func (o *CIMObject) breaker() Breaker {
+	b := Breaker{ID: o.ID, IdentifiedObject: o.identifiedObject(), PowerSystemResource: o.powerSystemResource()}
+	b.EquipmentContainer.Resource = o.Resource("Equipment.EquipmentContainer")
+	b.Circuit.Resource = o.Resource("PowerSystemResource.Circuit")
+	b.BaseVoltage.Resource = o.Resource("ConductingEquipment.BaseVoltage")
+	return b
+}
+
+// 电杆与故障指示器的字段相同
+func (o *CIMObject) pole() Pole {
+	p := Pole{ID: o.ID, IdentifiedObject: o.identifiedObject(), PowerSystemResource: o.powerSystemResource()}
+	p.Circuit.Resource = o.Resource("PowerSystemResource.Circuit")
+	p.BaseVoltage.Resource = o.Resource("ConductingEquipment.BaseVoltage")
+	return p
+}
+
+func (o *CIMObject) terminal() Terminal {
+	terminal := Terminal{ID: o.ID}
+	terminal.ConductingEquipment.Resource = o.Resource("Terminal.ConductingEquipment")
+	terminal.ConnectivityNode.Resource = o.Resource("Terminal.ConnectivityNode")
+	return terminal
+}
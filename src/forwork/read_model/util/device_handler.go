@@ -0,0 +1,235 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"raselper/src/forwork/read_model/data"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 未配置devices时使用, 与原来只处理低压线路的逻辑相同
+var DefaultDeviceConfigs = []data.DeviceConfig{
+	{
+		Class:    "ACLineSegment",
+		Prefixes: []string{"10100000"},
+		Sequence: "SG_DEV_LOWVOLLINE_B_{organ}_SEQ",
+		Table:    "SG_DEV_LOWVOLLINE_B",
+		MapTable: "SG_DEV_LOWVOLLINE_C",
+		TBName:   "aclinesegment",
+		Columns: map[string]string{
+			"NAME":         "IdentifiedObject.name",
+			"ABBREVIATION": "IdentifiedObject.name",
+		},
+		Values: map[string]string{
+			"RUNNING_STATE": "1003",
+			"VOLTAGE_TYPE":  "1010",
+			"WIRE_TYPE":     "1001",
+		},
+	},
+}
+
+// 一类源端设备的新增处理
+type DeviceHandler struct {
+	data.DeviceConfig
+}
+
+// 新增一个设备需要写入的记录, ID_MAP, 设备表_B, 映射表_C
+type DeviceRecords struct {
+	IDMap  map[string]interface{}
+	Device map[string]interface{}
+	Map    map[string]interface{}
+}
+
+func NewDeviceHandlers(configs []data.DeviceConfig) ([]*DeviceHandler, error) {
+	handlers := make([]*DeviceHandler, 0, len(configs))
+	for i, config := range configs {
+		if !isEquipmentClass[config.Class] {
+			return nil, fmt.Errorf("devices[%d]: 不支持的设备类 %q", i, config.Class)
+		}
+		if config.Sequence == "" || config.Table == "" || config.MapTable == "" || config.TBName == "" {
+			return nil, fmt.Errorf("devices[%d] %s: sequence、table、map-table、tbname 不能为空", i, config.Class)
+		}
+		for column, attribute := range config.Columns {
+			if !equipmentAttributes[attribute] {
+				return nil, fmt.Errorf("devices[%d] %s: 列 %s 的CIM属性 %q 不存在", i, config.Class, column, attribute)
+			}
+		}
+		handlers = append(handlers, &DeviceHandler{DeviceConfig: config})
+	}
+	return handlers, nil
+}
+
+var (
+	deviceHandlers     []*DeviceHandler
+	deviceHandlersErr  error
+	deviceHandlersOnce sync.Once
+)
+
+// 按data.Config.Devices创建, 第一次使用时校验配置
+func DeviceHandlers() ([]*DeviceHandler, error) {
+	deviceHandlersOnce.Do(func() {
+		configs := data.Config.Devices
+		if len(configs) == 0 {
+			configs = DefaultDeviceConfigs
+		}
+		deviceHandlers, deviceHandlersErr = NewDeviceHandlers(configs)
+	})
+	return deviceHandlers, deviceHandlersErr
+}
+
+// 第一个匹配的处理
+func FindDeviceHandler(handlers []*DeviceHandler, class, id string) *DeviceHandler {
+	for _, handler := range handlers {
+		if handler.Match(class, id) {
+			return handler
+		}
+	}
+	return nil
+}
+
+func (h *DeviceHandler) Match(class, id string) bool {
+	if h.Class != class {
+		return false
+	}
+	if len(h.Prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range h.Prefixes {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 序列名(含模式名), owner没有对应的地市时报错
+func (h *DeviceHandler) SequenceName(database, owner string) (string, error) {
+	name := h.Sequence
+	if strings.Contains(name, "{organ}") {
+		organ := data.OwnerOrganMap[owner]
+		if organ == "" {
+			return "", fmt.Errorf("owner %s 没有对应的地市简称", owner)
+		}
+		name = strings.ReplaceAll(name, "{organ}", organ)
+	}
+	return database + "." + name, nil
+}
+
+// 按配置生成要写入的记录, ID为序列取到的新ID
+func (h *DeviceHandler) Records(id string, device Equipment, owner, feederDCloudID string, now time.Time) DeviceRecords {
+	stamp := "350000_00613500000001_" + now.Format("2006-01-02 15:04:05")
+	records := DeviceRecords{
+		IDMap: map[string]interface{}{
+			"ID":        id,
+			"RDF_ID":    device.ID,
+			"TBNAME":    h.TBName,
+			"REGION_ID": owner,
+		},
+		Device: make(map[string]interface{}, len(h.Columns)+len(h.Values)+5),
+		Map: map[string]interface{}{
+			"DATASOURCE_ID": "0021" + owner,
+			"DCLOUD_ID":     id,
+			"EMS_ID":        device.ID,
+			"OWNER":         owner,
+			"PMS_RDF_ID":    device.ID,
+			"STAMP":         stamp,
+			"UPDATE_TIME":   now.Format("2006-01-02 15:04:05"),
+		},
+	}
+	for column, value := range h.Values {
+		records.Device[column] = value
+	}
+	for column, attribute := range h.Columns {
+		records.Device[column] = device.Attribute(attribute)
+	}
+	// 公共列不允许配置覆盖
+	records.Device["ID"] = id
+	records.Device["FEEDER_ID"] = feederDCloudID
+	records.Device["OWNER"] = owner
+	records.Device["STAMP"] = stamp
+	records.Device["DCC_ID"] = "0021" + owner
+	return records
+}
+
+// 取新ID并在一个事务中写入ID_MAP、设备表和映射表
+func (h *DeviceHandler) Create(db *gorm.DB, database string, device Equipment, owner, feederDCloudID string) (string, error) {
+	sequence, err := h.SequenceName(database, owner)
+	if err != nil {
+		return "", err
+	}
+	var id string
+	if result := db.Raw("select " + sequence + ".NEXTVAL as ID;").Scan(&id); result.Error != nil {
+		return "", result.Error
+	}
+	if id == "" {
+		return "", errors.New(sequence + ": 没有取到ID")
+	}
+
+	records := h.Records(id, device, owner, feederDCloudID, time.Now())
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(database + ".ID_MAP").Create(records.IDMap).Error; err != nil {
+			return fmt.Errorf("插入ID_MAP失败: %w", err)
+		}
+		if err := tx.Table(database + "." + h.Table).Create(records.Device).Error; err != nil {
+			return fmt.Errorf("插入%s失败: %w", h.Table, err)
+		}
+		if err := tx.Table(database + "." + h.MapTable).Create(records.Map).Error; err != nil {
+			return fmt.Errorf("插入%s失败: %w", h.MapTable, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// 可以映射到设备表列的CIM属性
+var equipmentAttributes = map[string]bool{
+	"rdf:ID": true, "IdentifiedObject.name": true, "IdentifiedObject.bianhao": true,
+	"PowerSystemResource.Circuit": true, "Equipment.EquipmentContainer": true, "ConductingEquipment.BaseVoltage": true,
+	"PowerSystemResource.DeviceType": true, "PowerSystemResource.SubType": true, "PowerSystemResource.DeviceID": true,
+	"PowerSystemResource.MaintainTeam": true, "PowerSystemResource.PoleID": true, "PowerSystemResource.IsYK": true,
+	"PowerSystemResource.UserType": true, "PowerSystemResource.TaiQuHao": true, "PowerSystemResource.Cxmc": true,
+}
+
+// 按CIM属性名取值, 引用去掉#号
+func (e *Equipment) Attribute(name string) string {
+	switch name {
+	case "rdf:ID":
+		return e.ID
+	case "IdentifiedObject.name":
+		return e.Name
+	case "IdentifiedObject.bianhao":
+		return e.Bianhao
+	case "PowerSystemResource.Circuit":
+		return strings.TrimPrefix(e.Circuit, "#")
+	case "Equipment.EquipmentContainer":
+		return strings.TrimPrefix(e.EquipmentContainer, "#")
+	case "ConductingEquipment.BaseVoltage":
+		return strings.TrimPrefix(e.BaseVoltage, "#")
+	case "PowerSystemResource.DeviceType":
+		return e.DeviceType
+	case "PowerSystemResource.SubType":
+		return e.SubType
+	case "PowerSystemResource.DeviceID":
+		return e.DeviceID
+	case "PowerSystemResource.MaintainTeam":
+		return e.MaintainTeam
+	case "PowerSystemResource.PoleID":
+		return e.PoleID
+	case "PowerSystemResource.IsYK":
+		return e.IsYK
+	case "PowerSystemResource.UserType":
+		return e.UserType
+	case "PowerSystemResource.TaiQuHao":
+		return e.TaiQuHao
+	case "PowerSystemResource.Cxmc":
+		return e.Cxmc
+	}
+	return ""
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"raselper/src/forwork/read_model/data"
-	"strconv"
 	"strings"
 	"time"
 
@@ -170,107 +169,38 @@ func GetDCloudNodeIDList(config data.AppConfig, db *gorm.DB, nodeIDMap map[strin
 	return resultMap
 }
 
-func GetNoUseNodeInFeeder(feederID string, owner string) string {
-	config := data.Config
-	var nodeMap []NodeMap
-	if owner == "" {
-		return "170135" + fmt.Sprintf("%d", time.Now().UnixMicro())
+// 分配新的连接节点ID
+func newNodeID() (string, error) {
+	allocator, err := data.NodeIDs()
+	if err != nil {
+		return "", fmt.Errorf("节点ID分配器不可用: %w", err)
 	}
-	if feederID == "" {
-		return "170135" + fmt.Sprintf("%d", time.Now().UnixMicro())
-	}
-	if res := data.DB.Table(config.DB.Database+".NODE_MAP_OWNER").Where("NODE_ID like ?", feederID+"%").Find(&nodeMap); res.Error != nil {
-		log.Println(res.Error)
-	}
-	nodeHit := make([]bool, 10000)
-	for _, node := range nodeMap {
-		if len(node.NodeID) < 18 {
-			return "170135" + fmt.Sprintf("%d", time.Now().UnixMicro())
-		}
-		num, _ := strconv.Atoi(node.NodeID[18:])
-		nodeHit[num] = true
-	}
-
-	for i, b := range nodeHit {
-		if !b {
-			n := fmt.Sprintf("%04d", i)
-			return feederID + n
-		}
-	}
-
-	return "170135" + fmt.Sprintf("%d", time.Now().UnixMicro())
+	return allocator.Next()
 }
 
-func GetNoUseNodeInFeeder_NodeMapOwner(feederID string, id string, owner string) string {
+// 源端节点对应的节点ID, 没有时从分配器取新ID并记录到NODE_MAP_OWNER
+func GetNodeID_NodeMapOwner(id string, owner string) (string, error) {
 	var nodeMapOwner NodeOwnerMap
 	data.DB.Table(data.Config.DB.Database+".NODE_MAP_OWNER").
 		Where("Owner = ? and ID = ?", owner, id).
 		Find(&nodeMapOwner)
 	if nodeMapOwner.NodeID != "" {
-		return nodeMapOwner.NodeID
+		return nodeMapOwner.NodeID, nil
 	}
 
-	config := data.Config
-	var nodeMap []NodeMap
-	timeUniId := "170135" + fmt.Sprintf("%d", time.Now().UnixMicro())
-	if owner == "" {
-		if res := data.DB.Table(data.Config.DB.Database + ".NODE_MAP_OWNER").
-			Create(&NodeOwnerMap{
-				ID:     id,
-				NodeID: timeUniId,
-				Owner:  owner,
-			}); res.Error != nil {
-			fmt.Printf("Error: %v\n", res.Error)
-		}
-		return timeUniId
+	nodeID, err := newNodeID()
+	if err != nil {
+		return "", err
 	}
-	if feederID == "" {
-		if res := data.DB.Table(data.Config.DB.Database + ".NODE_MAP_OWNER").
-			Create(&NodeOwnerMap{
-				ID:     id,
-				NodeID: timeUniId,
-				Owner:  owner,
-			}); res.Error != nil {
-			fmt.Printf("Error: %v\n", res.Error)
-		}
-		return timeUniId
-	}
-	if res := data.DB.Table(config.DB.Database+".NODE_MAP_OWNER").Where("NODE_ID like ?", feederID+"%").Find(&nodeMap); res.Error != nil {
-		log.Println(res.Error)
-	}
-	nodeHit := make([]bool, 10000)
-	for _, node := range nodeMap {
-		if len(node.NodeID) < 18 {
-			return timeUniId
-		}
-		num, _ := strconv.Atoi(node.NodeID[18:])
-		nodeHit[num] = true
-	}
-
-	for i, b := range nodeHit {
-		if !b {
-			n := fmt.Sprintf("%04d", i)
-			if res := data.DB.Table(data.Config.DB.Database + ".NODE_MAP_OWNER").
-				Create(&NodeOwnerMap{
-					ID:     id,
-					NodeID: feederID + n,
-					Owner:  owner,
-				}); res.Error != nil {
-				fmt.Printf("Error: %v\n", res.Error)
-			}
-			return feederID + n
-		}
-	}
-
 	if res := data.DB.Table(data.Config.DB.Database + ".NODE_MAP_OWNER").
 		Create(&NodeOwnerMap{
 			ID:     id,
-			NodeID: timeUniId,
+			NodeID: nodeID,
 			Owner:  owner,
 		}); res.Error != nil {
 		fmt.Printf("Error: %v\n", res.Error)
 	}
-	return timeUniId
+	return nodeID, nil
 }
 
 func MainSubConnect(circuitDCloudMap map[string]string, circuitMainFeederMap map[string]bool) bool {
@@ -399,86 +329,28 @@ func MainSubConnect(circuitDCloudMap map[string]string, circuitMainFeederMap map
 	return success
 }
 
-func NewDevice(id string, rdf *RDF, owner string, feederDCloudID string) (string, error) {
-	config := data.Config
-	db := data.DB
-	if strings.HasPrefix(id, "10100000") {
-		fmt.Println("Lost ACLine, fix...")
-		var idFromSeq string
-		if result := db.Raw("select " + config.DB.Database + ".SG_DEV_LOWVOLLINE_B_" + data.OwnerOrganMap[owner] + "_SEQ.NEXTVAL as ID;").Scan(&idFromSeq); result.Error != nil {
-			return "", result.Error
-		}
-		var segmentFind ACLineSegment
-		for _, segment := range rdf.ACLineSegments { // 找对应的设备信息
-			if segment.ID == id {
-				segmentFind = segment
-				break
-			}
-		}
-		// 新增设备
-		if segmentFind.ID != id {
-			return "", errors.New("Device Not Found?")
-		}
-		// 使用事务，并优化时间格式化和错误处理
-		err := db.Transaction(func(tx *gorm.DB) error {
-			// 在事务外部计算时间，确保两个记录使用相同的时间戳
-			currentTime := time.Now()
-			stamp := "350000_00613500000001_" + currentTime.Format("2006-01-02 15:04:05")
-			updateTime := currentTime.Format("2006-01-02 15:04:05")
-
-			// ID_MAP插入
-			if err := tx.Table(config.DB.Database + ".ID_MAP").
-				Create(map[string]interface{}{
-					"ID":        idFromSeq,
-					"RDF_ID":    id,
-					"TBNAME":    "aclinesegment",
-					"REGION_ID": owner,
-				}).Error; err != nil {
-				return fmt.Errorf("插入SG_DEV_LOWVOLLINE_B失败: %w", err)
-			}
-			// 第一个表插入
-			if err := tx.Table(config.DB.Database + ".SG_DEV_LOWVOLLINE_B").
-				Create(map[string]interface{}{
-					"ID":            idFromSeq,
-					"FEEDER_ID":     feederDCloudID,
-					"NAME":          segmentFind.Name,
-					"OWNER":         owner,
-					"STAMP":         stamp,
-					"ABBREVIATION":  segmentFind.Name,
-					"DCC_ID":        "0021" + owner,
-					"RUNNING_STATE": "1003",
-					"VOLTAGE_TYPE":  "1010",
-					"WIRE_TYPE":     "1001",
-				}).Error; err != nil {
-				return fmt.Errorf("插入SG_DEV_LOWVOLLINE_B失败: %w", err)
-			}
-
-			// 第二个表插入
-			if err := tx.Table(config.DB.Database + ".SG_DEV_LOWVOLLINE_C").
-				Create(map[string]interface{}{
-					"DATASOURCE_ID": "0021" + owner,
-					"DCLOUD_ID":     idFromSeq,
-					"EMS_ID":        id,
-					"OWNER":         owner,
-					"PMS_RDF_ID":    id,
-					"STAMP":         stamp,
-					"UPDATE_TIME":   updateTime,
-				}).Error; err != nil {
-				return fmt.Errorf("插入SG_DEV_LOWVOLLINE_C失败: %w", err)
-			}
-
-			return nil
-		})
-		// 处理事务结果
-		if err != nil {
-			// 可以根据错误类型进行不同的处理
-			log.Printf("数据插入失败: %v", err)
-		}
-		log.Println("数据插入成功")
-		return idFromSeq, nil
+// 源端设备没有录入时新增, 按设备类和rdf:ID前缀找到配置的处理, 见data.DeviceConfig
+func NewDevice(id string, model *CIMFile, owner string, feederDCloudID string) (string, error) {
+	handlers, err := DeviceHandlers()
+	if err != nil {
+		return "", err
 	}
-
-	return "", errors.New(id + ": Device Type Not Found")
+	device, ok := model.Equipment(id)
+	if !ok {
+		return "", errors.New(id + ": Device Not Found")
+	}
+	handler := FindDeviceHandler(handlers, device.Class, id)
+	if handler == nil {
+		return "", errors.New(id + ": Device Type Not Found")
+	}
+	log.Printf("Lost %s, fix... %s -> %s", device.Class, id, handler.Table)
+	newID, err := handler.Create(data.DB, data.Config.DB.Database, device, owner, feederDCloudID)
+	if err != nil {
+		log.Printf("数据插入失败: %v", err)
+		return "", err
+	}
+	log.Println("数据插入成功")
+	return newID, nil
 }
 
 type TopoBO struct {
@@ -582,15 +454,21 @@ func GetTopoInfoInDCloud(topoInfo []TopoBO, cloudMap map[string]string, owner st
 	return resultList
 }
 
-func HandleDBTopo(topoList []TopoBO, cloudMap map[string]string, owner string, rdf *RDF) {
-	for _, topoBO := range topoList {
+func HandleDBTopo(topoList []TopoBO, cloudMap map[string]string, owner string, model *CIMFile) error {
+	for i, topoBO := range topoList {
 		fmt.Printf("topo: %v\n", topoBO)                                 // 打印未处理时
 		if topoBO.SourceFirstNode != "" && topoBO.TransFirstNode == "" { // 首节点空
-			newNode := GetNoUseNodeInFeeder_NodeMapOwner(topoBO.DCloudFeeder, topoBO.SourceFirstNode, owner)
+			newNode, err := GetNodeID_NodeMapOwner(topoBO.SourceFirstNode, owner)
+			if err != nil {
+				return fmt.Errorf("设备%s首节点分配失败: %w", topoBO.SourceID, err)
+			}
 			topoBO.TransFirstNode = newNode
 		}
 		if topoBO.SourceSecondNode != "" && topoBO.TransSecondNode == "" { // 尾节点空
-			newNode := GetNoUseNodeInFeeder_NodeMapOwner(topoBO.DCloudFeeder, topoBO.SourceSecondNode, owner)
+			newNode, err := GetNodeID_NodeMapOwner(topoBO.SourceSecondNode, owner)
+			if err != nil {
+				return fmt.Errorf("设备%s尾节点分配失败: %w", topoBO.SourceID, err)
+			}
 			topoBO.TransSecondNode = newNode
 		}
 		// 处理后结果
@@ -600,11 +478,14 @@ func HandleDBTopo(topoList []TopoBO, cloudMap map[string]string, owner string, r
 
 		// 开始录入
 		if topoBO.DCloudID == "" {
-			newID, _ := NewDevice(topoBO.SourceID, rdf, owner, topoBO.DCloudFeeder)
-			if newID == "" { // 没生成跳过
-				fmt.Println("newID is empty, pass:" + topoBO.DCloudID)
+			newID, err := NewDevice(topoBO.SourceID, model, owner, topoBO.DCloudFeeder)
+			if err != nil { // 没生成跳过
+				log.Printf("设备%s新增失败, 跳过: %v", topoBO.SourceID, err)
+				continue
 			}
 			fmt.Println("newID:", newID)
+			topoBO.DCloudID = newID
+			topoList[i].DCloudID = newID // 后面的多端子、主配拼接处理也用新ID
 		}
 
 		if topoBO.ID == "" && topoBO.DCloudID != "" { // 没这个topo
@@ -634,16 +515,20 @@ func HandleDBTopo(topoList []TopoBO, cloudMap map[string]string, owner string, r
 		fmt.Printf("else: %v\n", topoBO)
 		fmt.Println("---------------------")
 	}
+	return nil
 }
 
-func HandleMultiplyNode(topoList []TopoBO, cloudMap map[string]string, owner string, rdf *RDF) {
+func HandleMultiplyNode(topoList []TopoBO, cloudMap map[string]string, owner string, rdf *RDF) error {
 	for _, topoBO := range topoList {
 		if len(topoBO.SourceNode) > 2 {
 			fmt.Printf("topo more than 2: %s\n", topoBO.SourceID)
 			fmt.Printf("topo: %v\n", topoBO)
 			fmt.Printf("cloud: %s, %s, %s\n", topoBO.ID, SafeGetString(topoBO.FirstNodeID), SafeGetString(topoBO.SecondNodeID))
 			for _, terminal := range topoBO.SourceNode[2:] {
-				newNode := GetNoUseNodeInFeeder_NodeMapOwner(topoBO.DCloudFeeder, terminal.ConnectivityNode.Resource, owner)
+				newNode, err := GetNodeID_NodeMapOwner(terminal.ConnectivityNode.Resource, owner)
+				if err != nil {
+					return fmt.Errorf("设备%s多端节点分配失败: %w", topoBO.SourceID, err)
+				}
 				fmt.Println("multiply newNode:", newNode)
 				data.DB.Table(data.Config.DB.Database+".SG_CON_DPWRGRID_R_TOPO").
 					Where("FEEDER_ID = ? and FIRST_NODE_ID = ?", topoBO.DCloudFeeder, newNode).
@@ -658,6 +543,7 @@ func HandleMultiplyNode(topoList []TopoBO, cloudMap map[string]string, owner str
 			}
 		}
 	}
+	return nil
 }
 
 func HandleEmptyTopo(cloud []TopoBO) {
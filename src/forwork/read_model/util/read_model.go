@@ -2,11 +2,9 @@ package util
 
 import (
 	"encoding/xml"
-	"io"
 	"log"
-	"os"
 	"raselper/src/forwork/read_model/data"
-	"raselper/src/secondary/utils"
+	"raselper/src/secondary/topology"
 	"strings"
 
 	"gorm.io/gorm"
@@ -49,21 +47,21 @@ type PowerSystemResource struct {
 	DeviceType   string `xml:"PowerSystemResource.DeviceType"`
 	SubType      string `xml:"PowerSystemResource.SubType"`
 	DeviceID     string `xml:"PowerSystemResource.DeviceID"`
-	MaintainTeam string `xml:"http://www.ieslab.com.cn MaintainTeam"`
-	PoleID       string `xml:"http://www.ieslab.com.cn PoleID"`
-	IsYK         string `xml:"http://www.ieslab.com.cn IsYK"`
-	UserType     string `xml:"http://www.ieslab.com.cn UserType"`
-	TaiQuHao     string `xml:"http://www.ieslab.com.cn TaiQuHao"`
-	Cxmc         string `xml:"http://www.ieslab.com.cn Cxmc"`
+	MaintainTeam string `xml:"http://www.ieslab.com.cn PowerSystemResource.MaintainTeam"`
+	PoleID       string `xml:"http://www.ieslab.com.cn PowerSystemResource.PoleID"`
+	IsYK         string `xml:"http://www.ieslab.com.cn PowerSystemResource.IsYK"`
+	UserType     string `xml:"http://www.ieslab.com.cn PowerSystemResource.UserType"`
+	TaiQuHao     string `xml:"http://www.ieslab.com.cn PowerSystemResource.TaiQuHao"`
+	Cxmc         string `xml:"http://www.ieslab.com.cn PowerSystemResource.Cxmc"`
 }
 
 // 基础电压
 type BaseVoltage struct {
 	ID             string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# ID,attr"`
 	Name           string `xml:"IdentifiedObject.name"`
-	IsDC           string `xml:"isDC"`
-	NominalVoltage string `xml:"nominalVoltage"`
-	DeviceID       string `xml:"http://www.ieslab.com.cn DeviceID"`
+	IsDC           string `xml:"BaseVoltage.isDC"`
+	NominalVoltage string `xml:"BaseVoltage.nominalVoltage"`
+	DeviceID       string `xml:"http://www.ieslab.com.cn PowerSystemResource.DeviceID"`
 }
 
 // 地理区域
@@ -240,26 +238,10 @@ type Terminal struct {
 	} `xml:"Terminal.ConnectivityNode"`
 }
 
-// 解析XML文件
+// 解析XML文件, 流式读取, 见DecodeCIMXML
 func ParseCIMXML(filePath string) (*RDF, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	data, err := io.ReadAll(file)
-	if err != nil {
-		return nil, err
-	}
-
-	var rdf RDF
-	err = xml.Unmarshal(data, &rdf)
-	if err != nil {
-		return nil, err
-	}
-
-	return &rdf, nil
+	rdf, _, err := ParseCIMXMLIndex(filePath)
+	return rdf, err
 }
 
 func GetTopoMap(rdf *RDF) (idNodeMap map[string][]string, nodeIdMap map[string][]string, deviceFeederMap map[string]string) {
@@ -324,145 +306,34 @@ func GetTopoMap(rdf *RDF) (idNodeMap map[string][]string, nodeIdMap map[string][
 	return idNodeMap, nodeIdMap, deviceFeederMap
 }
 
-func GetDeviceTopoMap(rdf *RDF) (resultList []TopoBO) {
+// 主馈线上有端子的设备及其端子, 包括RDF结构体中没有的负荷开关、用户等
+func GetDeviceTopoMap(model *CIMFile) (resultList []TopoBO) {
 	topoCacheMap := make(map[string][]Terminal)
 	mainFeederCacheMap := make(map[string]bool)
 
-	for _, terminal := range rdf.Terminals {
+	for _, terminal := range model.RDF.Terminals {
 		topoCacheMap[terminal.ConductingEquipment.Resource] = append(topoCacheMap[terminal.ConductingEquipment.Resource], terminal)
 	}
 
-	for _, circuit := range rdf.Circuits {
+	for _, circuit := range model.RDF.Circuits {
 		if circuit.IsCurrentFeeder == "1" {
 			mainFeederCacheMap["#"+circuit.ID] = true // 特殊处理, 设备里的带#号
 		}
 	}
-	for _, entity := range rdf.Breakers {
-		terminals := topoCacheMap["#"+entity.ID]
-		if len(terminals) == 0 {
-			log.Println("topo not found!:", entity.ID)
-			continue
-		}
-		if mainFeederCacheMap[entity.Circuit.Resource] == false {
-			log.Println("not in main feeder pass id:", entity.ID)
-			continue
-		}
-		resultList = append(resultList, TopoBO{
-			SourceID:     entity.ID,
-			SourceNode:   terminals,
-			SourceFeeder: strings.ReplaceAll(entity.Circuit.Resource, "#", ""),
-		})
-	}
-	for _, entity := range rdf.Disconnectors {
-		terminals := topoCacheMap["#"+entity.ID]
-		if len(terminals) == 0 {
-			log.Println("topo not found!:", entity.ID)
-			continue
-		}
-		if mainFeederCacheMap[entity.Circuit.Resource] == false {
-			log.Println("not in main feeder pass id:", entity.ID)
-			continue
-		}
-		resultList = append(resultList, TopoBO{
-			SourceID:     entity.ID,
-			SourceNode:   terminals,
-			SourceFeeder: strings.ReplaceAll(entity.Circuit.Resource, "#", ""),
-		})
-	}
-	for _, entity := range rdf.Fuses {
-		terminals := topoCacheMap["#"+entity.ID]
-		if len(terminals) == 0 {
-			log.Println("topo not found!:", entity.ID)
-			continue
-		}
-		if mainFeederCacheMap[entity.Circuit.Resource] == false {
-			log.Println("not in main feeder pass id:", entity.ID)
-			continue
-		}
-		resultList = append(resultList, TopoBO{
-			SourceID:     entity.ID,
-			SourceNode:   terminals,
-			SourceFeeder: strings.ReplaceAll(entity.Circuit.Resource, "#", ""),
-		})
-	}
-	for _, entity := range rdf.PowerTransformers {
-		terminals := topoCacheMap["#"+entity.ID]
-		if len(terminals) == 0 {
-			log.Println("topo not found!:", entity.ID)
-			continue
-		}
-		if mainFeederCacheMap[entity.Circuit.Resource] == false {
-			log.Println("not in main feeder pass id:", entity.ID)
-			continue
-		}
-		resultList = append(resultList, TopoBO{
-			SourceID:     entity.ID,
-			SourceNode:   terminals,
-			SourceFeeder: strings.ReplaceAll(entity.Circuit.Resource, "#", ""),
-		})
-	}
-	for _, entity := range rdf.BusbarSections {
-		terminals := topoCacheMap["#"+entity.ID]
-		if len(terminals) == 0 {
-			log.Println("topo not found!:", entity.ID)
-			continue
-		}
-		if mainFeederCacheMap[entity.Circuit.Resource] == false {
-			log.Println("not in main feeder pass id:", entity.ID)
-			continue
-		}
-		resultList = append(resultList, TopoBO{
-			SourceID:     entity.ID,
-			SourceNode:   terminals,
-			SourceFeeder: strings.ReplaceAll(entity.Circuit.Resource, "#", ""),
-		})
-	}
-	for _, entity := range rdf.ACLineSegments {
-		terminals := topoCacheMap["#"+entity.ID]
-		if len(terminals) == 0 {
-			log.Println("topo not found!:", entity.ID)
-			continue
-		}
-		if mainFeederCacheMap[entity.Circuit.Resource] == false {
-			log.Println("not in main feeder pass id:", entity.ID)
-			continue
-		}
-		resultList = append(resultList, TopoBO{
-			SourceID:     entity.ID,
-			SourceNode:   terminals,
-			SourceFeeder: strings.ReplaceAll(entity.Circuit.Resource, "#", ""),
-		})
-	}
-	for _, entity := range rdf.Poles {
-		terminals := topoCacheMap["#"+entity.ID]
-		if len(terminals) == 0 {
-			log.Println("topo not found!:", entity.ID)
-			continue
-		}
-		if mainFeederCacheMap[entity.Circuit.Resource] == false {
-			log.Println("not in main feeder pass id:", entity.ID)
-			continue
-		}
-		resultList = append(resultList, TopoBO{
-			SourceID:     entity.ID,
-			SourceNode:   terminals,
-			SourceFeeder: strings.ReplaceAll(entity.Circuit.Resource, "#", ""),
-		})
-	}
-	for _, entity := range rdf.FaultIndicators {
+	for _, entity := range model.Devices {
 		terminals := topoCacheMap["#"+entity.ID]
 		if len(terminals) == 0 {
 			log.Println("topo not found!:", entity.ID)
 			continue
 		}
-		if mainFeederCacheMap[entity.Circuit.Resource] == false {
+		if mainFeederCacheMap[entity.Circuit] == false {
 			log.Println("not in main feeder pass id:", entity.ID)
 			continue
 		}
 		resultList = append(resultList, TopoBO{
 			SourceID:     entity.ID,
 			SourceNode:   terminals,
-			SourceFeeder: strings.ReplaceAll(entity.Circuit.Resource, "#", ""),
+			SourceFeeder: strings.ReplaceAll(entity.Circuit, "#", ""),
 		})
 	}
 
@@ -470,7 +341,7 @@ func GetDeviceTopoMap(rdf *RDF) (resultList []TopoBO) {
 }
 
 // HandleTopo @deprecated
-func HandleTopo(idNodeMap, nodeIDMap map[string][]string, topoList []Topo, rdfDCloudMap map[string]IdMap, nodeMap map[string]NodeMap, deviceFeederMap map[string]string, db *gorm.DB, config data.AppConfig, owner string, rdf *RDF, circuitDCloudMap map[string]string, circuitMainFeederMap map[string]bool) {
+func HandleTopo(idNodeMap, nodeIDMap map[string][]string, topoList []Topo, rdfDCloudMap map[string]IdMap, nodeMap map[string]NodeMap, deviceFeederMap map[string]string, db *gorm.DB, config data.AppConfig, owner string, model *CIMFile, circuitDCloudMap map[string]string, circuitMainFeederMap map[string]bool) error {
 	// DCloud的ID-topo Map
 	topoMap := make(map[string]Topo)
 	for _, topo := range topoList {
@@ -502,7 +373,7 @@ func HandleTopo(idNodeMap, nodeIDMap map[string][]string, topoList []Topo, rdfDC
 		if deviceDCloudID == "" {
 			log.Println("ID:", id, " Device Lost!")
 			if isMainFeeder {
-				if newID, err := NewDevice(id, rdf, owner, feederDCloudID); err != nil {
+				if newID, err := NewDevice(id, model, owner, feederDCloudID); err != nil {
 					log.Println("NewDevice error:", err)
 					continue
 				} else {
@@ -586,26 +457,26 @@ func HandleTopo(idNodeMap, nodeIDMap map[string][]string, topoList []Topo, rdfDC
 			log.Println("ID:", topoMap[deviceDCloudID].ID, " Cannot Convert!")
 			groupNodeList = []string{}
 			for _, node := range nodeList {
-				newNodeID := ""
+				nodeID := ""
 				{ // 先查是否有
 					var entity NodeMap
 					if res := db.Table(config.DB.Database+".NODE_MAP").Where("ID = ?", owner+node).Find(&entity); res.Error != nil {
 						log.Println(res.Error)
 					}
 					if entity.NodeID != "" { // 不为空用数据库的
-						newNodeID = entity.NodeID
+						nodeID = entity.NodeID
 					} else { // 为空生成
-						newNodeID = GetNoUseNodeInFeeder(deviceFeederMap[id], owner)
-						if newNodeID == "" {
-							newNodeID = node // 没生成成功先用源端ID
+						var err error
+						if nodeID, err = newNodeID(); err != nil {
+							return err
 						}
 						db.Table(config.DB.Database + ".NODE_MAP").Create(map[string]interface{}{
 							"ID":      owner + node,
-							"NODE_ID": newNodeID,
+							"NODE_ID": nodeID,
 						})
 					}
 				}
-				groupNodeList = append(groupNodeList, newNodeID)
+				groupNodeList = append(groupNodeList, nodeID)
 			}
 			if topoMap[deviceDCloudID].ID == "" { // 表里没数据就新增
 				insertMap := map[string]interface{}{
@@ -679,6 +550,7 @@ func HandleTopo(idNodeMap, nodeIDMap map[string][]string, topoList []Topo, rdfDC
 		}
 		// 源端和数据库
 	}
+	return nil
 }
 
 func listContainID(list []string, item string) bool {
@@ -691,13 +563,13 @@ func listContainID(list []string, item string) bool {
 }
 
 // 处理大于2个的节点
-func ConnectMultiplyNode(rdf *RDF, owner string) {
+func ConnectMultiplyNode(index *TopoIndex, owner string) {
 	if owner == "" {
 		log.Println("Owner Not Exist!")
 		return
 	}
 
-	nodeMap, nodeIDMap, _ := GetTopoMap(rdf)
+	nodeMap, nodeIDMap := index.IDNodeMap, index.NodeIDMap
 	log.Println("nodeIDMap:", len(nodeIDMap))
 	for id, nodeList := range nodeMap {
 		if len(nodeList) <= 2 {
@@ -777,7 +649,7 @@ func ConnectMultiplyNode(rdf *RDF, owner string) {
 	}
 }
 
-func MainSubConnectIfNotConnect(circuitDCloudMap map[string]string, circuitMainFeederMap map[string]bool, cloud []TopoBO, rdf *RDF) bool {
+func MainSubConnectIfNotConnect(circuitDCloudMap map[string]string, circuitMainFeederMap map[string]bool, cloud []TopoBO, model *CIMFile) bool {
 	db := data.DB
 	success := true
 
@@ -801,13 +673,12 @@ func MainSubConnectIfNotConnect(circuitDCloudMap map[string]string, circuitMainF
 		}
 
 		// 处理未拼接线路
-		list := getHVSubstationDevice(rdf, key)
+		list := getHVSubstationDevice(model, key)
 		log.Println("HVList: ", list)
-		idNodeMap, nodeIdMap := getDeviceNodeConnectInfo(rdf, key)
+		graph := getDeviceNodeConnectInfo(model, key)
 		for _, item := range list {
 			if strings.HasPrefix(item, "31100000") {
-				deviceConnectMap := getDeviceConnectMap(idNodeMap, nodeIdMap)
-				connectRoute := getDeviceConnectRoute(item, deviceConnectMap, make(map[string]bool))
+				connectRoute := graph.Routes(item)
 				headTopo, _ := getSubHeadTopo(list, connectRoute)
 				log.Println("head topo:", headTopo, " connect to bus:", item)
 				var busTopo Topo
@@ -960,47 +831,16 @@ func getSubHeadTopo(list []string, route [][]string) (resultSubHeadID string, re
 	return tempMaxTopo, headTopoRoute
 }
 
-func getHVSubstationDevice(rdf *RDF, feeder string) (resultList []string) {
+func getHVSubstationDevice(model *CIMFile, feeder string) (resultList []string) {
 	var substationID string
-
-	for _, circuit := range rdf.Circuits {
+	for _, circuit := range model.RDF.Circuits {
 		if circuit.ID == feeder {
 			substationID = circuit.BelongtoHVSubstation.Resource
 		}
 	}
 
-	for _, entity := range rdf.Breakers {
-		if entity.EquipmentContainer.Resource != substationID {
-			continue
-		}
-		resultList = append(resultList, entity.ID)
-	}
-	for _, entity := range rdf.ACLineSegments {
-		if entity.EquipmentContainer.Resource != substationID {
-			continue
-		}
-		resultList = append(resultList, entity.ID)
-	}
-	for _, entity := range rdf.Fuses {
-		if entity.EquipmentContainer.Resource != substationID {
-			continue
-		}
-		resultList = append(resultList, entity.ID)
-	}
-	for _, entity := range rdf.Disconnectors {
-		if entity.EquipmentContainer.Resource != substationID {
-			continue
-		}
-		resultList = append(resultList, entity.ID)
-	}
-	for _, entity := range rdf.PowerTransformers {
-		if entity.EquipmentContainer.Resource != substationID {
-			continue
-		}
-		resultList = append(resultList, entity.ID)
-	}
-	for _, entity := range rdf.BusbarSections {
-		if entity.EquipmentContainer.Resource != substationID {
+	for _, entity := range model.Equipments() {
+		if entity.EquipmentContainer != substationID {
 			continue
 		}
 		resultList = append(resultList, entity.ID)
@@ -1009,50 +849,6 @@ func getHVSubstationDevice(rdf *RDF, feeder string) (resultList []string) {
 	return
 }
 
-// 用idNode和NodeId对应找出设备连接关系
-func getDeviceConnectMap(idNodeMap map[string][]string, nodeIdMap map[string][]string) map[string][]string {
-	resultMap := make(map[string][]string)
-
-	for id, nodeList := range idNodeMap {
-		for _, node := range nodeList {
-			for _, device := range nodeIdMap[node] {
-				if !utils.ListContainString(resultMap[id], device) && device != id { // 去重判断
-					resultMap[id] = append(resultMap[id], device)
-				}
-			}
-		}
-	}
-
-	return resultMap
-}
-func getDeviceConnectRoute(startID string, deviceConnectMap map[string][]string, hitDevice map[string]bool) [][]string {
-	hitDevice[startID] = true // 标记节点已被查找
-
-	deviceList := deviceConnectMap[startID]
-	var resultList [][]string
-
-	for _, device := range deviceList {
-		if hitDevice[device] { // 为上游节点而非下游节点
-			continue
-		}
-		connectList := getDeviceConnectRoute(device, deviceConnectMap, hitDevice)
-		for _, connect := range connectList {
-			resultList = append(
-				resultList,
-				append([]string{startID}, connect...),
-			)
-		}
-	}
-
-	if len(resultList) == 0 {
-		return [][]string{
-			{startID},
-		}
-	}
-
-	return resultList
-}
-
 //func GetConnectDevice(startID string, idNodeMap map[string][]string, nodeIdMap map[string][]string) []string {
 //	nodeList := idNodeMap[startID]
 //
@@ -1062,59 +858,19 @@ func getDeviceConnectRoute(startID string, deviceConnectMap map[string][]string,
 //	}
 //}
 
-func getDeviceNodeConnectInfo(rdf *RDF, feeder string) (idNodeMap map[string][]string, nodeIdMap map[string][]string) {
+// 线路设备的拓扑图, 母线不限馈线
+func getDeviceNodeConnectInfo(model *CIMFile, feeder string) *topology.Graph {
 	existDevice := make(map[string]bool)
+	graph := topology.New()
 
-	idNodeMap = make(map[string][]string)
-	nodeIdMap = make(map[string][]string)
-
-	for _, breaker := range rdf.Breakers {
-		if "#"+feeder != breaker.Circuit.Resource {
-			continue
-		}
-		existDevice[breaker.ID] = true
-	}
-	for _, segment := range rdf.ACLineSegments {
-		if "#"+feeder != segment.Circuit.Resource {
-			continue
-		}
-		existDevice[segment.ID] = true
-	}
-	for _, fus := range rdf.Fuses {
-		if "#"+feeder != fus.Circuit.Resource {
-			continue
-		}
-		existDevice[fus.ID] = true
-	}
-	for _, disconnector := range rdf.Disconnectors {
-		if "#"+feeder != disconnector.Circuit.Resource {
+	for _, entity := range model.Devices {
+		if entity.Class != "BusbarSection" && "#"+feeder != entity.Circuit {
 			continue
 		}
-		existDevice[disconnector.ID] = true
-	}
-	for _, transformer := range rdf.PowerTransformers {
-		if "#"+feeder != transformer.Circuit.Resource {
-			continue
-		}
-		existDevice[transformer.ID] = true
-	}
-	for _, section := range rdf.BusbarSections {
-		existDevice[section.ID] = true
-	}
-	for _, pole := range rdf.Poles {
-		if "#"+feeder != pole.Circuit.Resource {
-			continue
-		}
-		existDevice[pole.ID] = true
-	}
-	for _, faultIndicator := range rdf.FaultIndicators {
-		if "#"+feeder != faultIndicator.Circuit.Resource {
-			continue
-		}
-		existDevice[faultIndicator.ID] = true
+		existDevice[entity.ID] = true
 	}
 
-	for _, terminal := range rdf.Terminals {
+	for _, terminal := range model.RDF.Terminals {
 		id := strings.Replace(terminal.ConductingEquipment.Resource, "#", "", -1)
 		nodeId := strings.Replace(terminal.ConnectivityNode.Resource, "#", "", -1)
 
@@ -1123,20 +879,13 @@ func getDeviceNodeConnectInfo(rdf *RDF, feeder string) (idNodeMap map[string][]s
 			continue
 		}
 
-		idNodeMap[id] = append(
-			idNodeMap[id],
-			nodeId,
-		)
-		nodeIdMap[nodeId] = append(
-			nodeIdMap[nodeId],
-			id,
-		)
+		graph.AddDevice(id, nodeId)
 	}
 
-	return idNodeMap, nodeIdMap
+	return graph
 }
 
-func MainSubConnect_UseSourceInfo(circuitDCloudMap map[string]string, circuitMainFeederMap map[string]bool, cloud []TopoBO, rdf *RDF) bool {
+func MainSubConnect_UseSourceInfo(circuitDCloudMap map[string]string, circuitMainFeederMap map[string]bool, cloud []TopoBO, model *CIMFile) bool {
 	db := data.DB
 	success := true
 
@@ -1156,13 +905,12 @@ func MainSubConnect_UseSourceInfo(circuitDCloudMap map[string]string, circuitMai
 		}
 
 		// 查主配路径
-		list := getHVSubstationDevice(rdf, key)
+		list := getHVSubstationDevice(model, key)
 		log.Println("HVList: ", list)
-		idNodeMap, nodeIdMap := getDeviceNodeConnectInfo(rdf, key)
+		graph := getDeviceNodeConnectInfo(model, key)
 		for _, item := range list {
 			if strings.HasPrefix(item, "31100000") { // 母线, 开始拓扑
-				deviceConnectMap := getDeviceConnectMap(idNodeMap, nodeIdMap)
-				connectRoute := getDeviceConnectRoute(item, deviceConnectMap, make(map[string]bool))
+				connectRoute := graph.Routes(item)
 				headTopo, subHeadTopoRoute := getSubHeadTopo(list, connectRoute)
 				log.Println("subHeadTopoRoute:", subHeadTopoRoute)
 				log.Println("head topo:", headTopo, " connect to bus:", item)
@@ -26,11 +26,12 @@ func main() {
 		return
 	}
 
-	cimxml, err := util.ParseCIMXML(os.Args[1])
+	_, index, err := util.ParseCIMXMLIndex(os.Args[1])
 	if err != nil {
 		fmt.Println(err)
+		return
 	}
-	idNodeMap, _, _ := util.GetTopoMap(cimxml)
+	idNodeMap := index.IDNodeMap
 	var nodeListEntity []util.NodeMap
 	for id, nodeList := range idNodeMap {
 		if len(nodeList) > 2 {
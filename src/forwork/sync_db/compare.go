@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 比较记录时的容差, 对应配置 sync.compare
+type CompareConfig struct {
+	FloatEpsilon  float64 `yaml:"float_epsilon"`  // 数值允许的误差, 0表示必须相等
+	TimePrecision string  `yaml:"time_precision"` // 时间按该精度截断后比较, 如 1s
+	TrimChar      bool    `yaml:"trim_char"`      // CHAR列忽略尾部空格
+	WallClock     bool    `yaml:"wall_clock"`     // 时间只比较年月日时分秒, 忽略时区
+}
+
+type compareOptions struct {
+	floatEpsilon  float64
+	timePrecision time.Duration
+	trimChar      bool
+	wallClock     bool
+}
+
+func newCompareOptions(config CompareConfig) (compareOptions, error) {
+	options := compareOptions{
+		floatEpsilon: config.FloatEpsilon,
+		trimChar:     config.TrimChar,
+		wallClock:    config.WallClock,
+	}
+	if config.FloatEpsilon < 0 {
+		return options, fmt.Errorf("compare.float_epsilon 不能小于0")
+	}
+	if config.TimePrecision != "" {
+		precision, err := time.ParseDuration(config.TimePrecision)
+		if err != nil {
+			return options, fmt.Errorf("compare.time_precision 格式错误: %w", err)
+		}
+		options.timePrecision = precision
+	}
+	return options, nil
+}
+
+// 按列类型比较源端和目标端记录
+//
+// 驱动返回的值类型不统一: 同一列可能是[]byte或string, 时间带不同时区, DECIMAL是字符串,
+// 所以先按目标端列类型把两边的值统一后再比较; 没有列类型时按值的类型比较
+type recordComparer struct {
+	primaryKeys []string
+	ignore      map[string]bool
+	ignoreCase  map[string]bool
+	types       map[string]string // 目标端列名 -> 统一后的类型名
+	options     compareOptions
+}
+
+func newRecordComparer(config TableConfig, types map[string]string, options compareOptions) *recordComparer {
+	c := &recordComparer{
+		primaryKeys: config.targetPrimaryKey(),
+		ignore:      make(map[string]bool),
+		ignoreCase:  make(map[string]bool),
+		types:       types,
+		options:     options,
+	}
+	for _, column := range config.IgnoreColumns {
+		c.ignore[column] = true
+	}
+	for _, column := range config.IgnoreCaseColumns {
+		c.ignoreCase[column] = true
+	}
+	return c
+}
+
+// 读取目标端列类型并创建比较器, 读取失败时退回按值的类型比较
+func (s *DBSynchronizer) newRecordComparer(config TableConfig) *recordComparer {
+	types, err := columnTypes(s.config.TargetDB, s.targetTableName(config))
+	if err != nil {
+		fmt.Printf("表 %s: 读取目标端列类型失败, 按值的类型比较: %v\n", config.TableName, err)
+	}
+	return newRecordComparer(config, types, s.config.Compare)
+}
+
+// 查询表的列类型, 不读取数据
+func columnTypes(db *gorm.DB, tableName string) (map[string]string, error) {
+	rows, err := db.Table(tableName).Where("1 = 0").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	types := make(map[string]string, len(columns))
+	for _, column := range columns {
+		types[column.Name()] = normalizeType(column.DatabaseTypeName())
+	}
+	return types, nil
+}
+
+// 记录是否相等, 只比较源端记录中的列, 跳过主键和ignore_columns
+func (c *recordComparer) equal(source, target map[string]interface{}) bool {
+	for column, v1 := range source {
+		if c.ignore[column] || containsString(c.primaryKeys, column) {
+			continue
+		}
+		v2, exists := target[column]
+		if !exists || !c.valueEqual(column, v1, v2) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *recordComparer) valueEqual(column string, a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	ta, aTime := a.(time.Time)
+	tb, bTime := b.(time.Time)
+	if aTime && bTime {
+		return c.normalizeTime(ta).Equal(c.normalizeTime(tb))
+	}
+
+	columnType := c.types[column]
+	if isNumericType(columnType) || isNumber(a) || isNumber(b) {
+		na, okA := toBigFloat(a)
+		nb, okB := toBigFloat(b)
+		if okA && okB {
+			return c.numberEqual(na, nb)
+		}
+	}
+
+	if isText(a) && isText(b) {
+		sa, sb := stringValue(a), stringValue(b)
+		if c.options.trimChar && columnType == "CHAR" {
+			sa, sb = strings.TrimRight(sa, " "), strings.TrimRight(sb, " ")
+		}
+		if c.ignoreCase[column] {
+			return strings.EqualFold(sa, sb)
+		}
+		return sa == sb
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func (c *recordComparer) normalizeTime(t time.Time) time.Time {
+	if c.options.wallClock {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	}
+	if c.options.timePrecision > 0 {
+		t = t.Truncate(c.options.timePrecision)
+	}
+	return t
+}
+
+func (c *recordComparer) numberEqual(a, b *big.Float) bool {
+	if c.options.floatEpsilon == 0 {
+		return a.Cmp(b) == 0
+	}
+	diff := new(big.Float).Sub(a, b)
+	return diff.Abs(diff).Cmp(big.NewFloat(c.options.floatEpsilon)) <= 0
+}
+
+func isNumericType(columnType string) bool {
+	switch columnType {
+	case "DECIMAL", "FLOAT", "DOUBLE":
+		return true
+	}
+	return strings.HasSuffix(columnType, "INT")
+}
+
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	}
+	return false
+}
+
+func isText(value interface{}) bool {
+	switch value.(type) {
+	case string, []byte:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordComparerNormalizesValues(t *testing.T) {
+	config := TableConfig{PrimaryKey: []string{"ID"}, IgnoreColumns: []string{"STAMP"}, IgnoreCaseColumns: []string{"CODE"}}
+	types := map[string]string{"ID": "BIGINT", "AMOUNT": "DECIMAL", "NAME": "CHAR", "NOTE": "VARCHAR", "CODE": "VARCHAR"}
+	options, err := newCompareOptions(CompareConfig{FloatEpsilon: 0.001, TimePrecision: "1s", TrimChar: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comparer := newRecordComparer(config, types, options)
+
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	source := map[string]interface{}{
+		"ID":      int64(1),
+		"AMOUNT":  "10.5000",
+		"RATE":    0.1 + 0.2,
+		"NAME":    []byte("abc"),
+		"NOTE":    "x",
+		"CODE":    "abc",
+		"CREATED": at,
+		"STAMP":   int64(100),
+	}
+	target := map[string]interface{}{
+		"ID":      int64(1),
+		"AMOUNT":  []byte("10.5"),
+		"RATE":    0.3,
+		"NAME":    "abc  ",
+		"NOTE":    []byte("x"),
+		"CODE":    "ABC",
+		"CREATED": at.In(time.FixedZone("CST", 8*3600)).Add(300 * time.Millisecond),
+		"STAMP":   int64(200),
+	}
+	if !comparer.equal(source, target) {
+		t.Fatal("records should be equal after normalisation")
+	}
+
+	tests := []struct {
+		column string
+		value  interface{}
+	}{
+		{"AMOUNT", "10.6"},
+		{"NOTE", "x "},
+		{"CREATED", at.Add(time.Second)},
+		{"NAME", nil},
+	}
+	for _, tt := range tests {
+		changed := make(map[string]interface{}, len(target))
+		for k, v := range target {
+			changed[k] = v
+		}
+		changed[tt.column] = tt.value
+		if comparer.equal(source, changed) {
+			t.Errorf("%s = %v should differ", tt.column, tt.value)
+		}
+	}
+}
+
+func TestRecordComparerWallClock(t *testing.T) {
+	config := TableConfig{PrimaryKey: []string{"ID"}}
+	utc := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	local := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CST", 8*3600))
+	source := map[string]interface{}{"ID": 1, "T": utc}
+	target := map[string]interface{}{"ID": 1, "T": local}
+
+	if newRecordComparer(config, nil, compareOptions{}).equal(source, target) {
+		t.Fatal("different instants should differ by default")
+	}
+	if !newRecordComparer(config, nil, compareOptions{wallClock: true}).equal(source, target) {
+		t.Fatal("same wall clock should be equal with wall_clock")
+	}
+}
+
+func TestCompareOptionsInvalid(t *testing.T) {
+	if _, err := newCompareOptions(CompareConfig{TimePrecision: "1x"}); err == nil {
+		t.Fatal("expected error for bad time_precision")
+	}
+	if _, err := newCompareOptions(CompareConfig{FloatEpsilon: -1}); err == nil {
+		t.Fatal("expected error for negative float_epsilon")
+	}
+}
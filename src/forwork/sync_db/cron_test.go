@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	from := time.Date(2024, 2, 28, 23, 58, 30, 0, time.Local) // 周三
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 2, 28, 23, 59, 0, 0, time.Local)},
+		{"*/15 * * * *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.Local)},
+		{"30 2 * * *", time.Date(2024, 2, 29, 2, 30, 0, 0, time.Local)},
+		{"0 8 * * 1-5", time.Date(2024, 2, 29, 8, 0, 0, 0, time.Local)},
+		{"0 8 * * 0", time.Date(2024, 3, 3, 8, 0, 0, 0, time.Local)},
+		{"0 8 * * 7", time.Date(2024, 3, 3, 8, 0, 0, 0, time.Local)},
+		{"0 0 1 * *", time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.Local)},
+		{"0 0 1,15 * 1", time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)},
+		{"5-10/5 9 * * *", time.Date(2024, 2, 29, 9, 5, 0, 0, time.Local)},
+		{"@every 10m", from.Add(10 * time.Minute)},
+	}
+	for _, tt := range tests {
+		schedule, err := parseSchedule(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		if got := schedule.next(from); !got.Equal(tt.want) {
+			t.Errorf("%s: next %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestCronScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "@every 0s", "@every x"} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("%q should be invalid", spec)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 守护进程配置, 对应配置 daemon
+type DaemonConfig struct {
+	Listen  string      `yaml:"listen"`  // HTTP监听地址, 默认只监听本机 127.0.0.1:18090
+	Token   string      `yaml:"token"`   // 配置后所有接口需要 Authorization: Bearer <token>
+	History int         `yaml:"history"` // 内存中保留的运行记录数, 默认100
+	Groups  []SyncGroup `yaml:"groups"`
+}
+
+// 一组按同一计划同步的表
+type SyncGroup struct {
+	Name     string   `yaml:"name"`
+	Schedule string   `yaml:"schedule"` // cron表达式或 @every 10m, 为空时只能通过接口触发
+	Tables   []string `yaml:"tables"`   // 为空时同步所有表
+}
+
+// 检查分组配置, 没有配置分组时默认一个包含所有表的all分组
+func validateDaemonConfig(config *DaemonConfig, tables []TableConfig) error {
+	if config.Listen == "" {
+		config.Listen = "127.0.0.1:18090"
+	}
+	if config.History <= 0 {
+		config.History = 100
+	}
+	if len(config.Groups) == 0 {
+		config.Groups = []SyncGroup{{Name: "all"}}
+	}
+
+	names := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		names[table.TableName] = true
+	}
+	groups := make(map[string]bool, len(config.Groups))
+	for _, group := range config.Groups {
+		if group.Name == "" || groups[group.Name] {
+			return fmt.Errorf("daemon.groups: 分组名 %q 为空或重复", group.Name)
+		}
+		groups[group.Name] = true
+		if group.Schedule != "" {
+			if _, err := parseSchedule(group.Schedule); err != nil {
+				return fmt.Errorf("分组 %s: %w", group.Name, err)
+			}
+		}
+		for _, table := range group.Tables {
+			if !names[table] {
+				return fmt.Errorf("分组 %s: 表 %s 不在配置中", group.Name, table)
+			}
+		}
+	}
+	return nil
+}
+
+// 一次运行记录
+type DaemonRun struct {
+	ID      int        `json:"id"`
+	Group   string     `json:"group"`
+	Trigger string     `json:"trigger"` // schedule 或 api
+	Tables  []string   `json:"tables"`
+	Start   time.Time  `json:"start"`
+	End     time.Time  `json:"end,omitempty"`
+	Skipped string     `json:"skipped,omitempty"` // 没有执行的原因
+	Report  *RunReport `json:"report,omitempty"`
+}
+
+var errRunning = errors.New("上一次同步尚未结束")
+
+// 定时同步的守护进程
+//
+// 同一时间只执行一次同步: 不同分组可能包含同一张表, 并且导出和进度都是按次记录的,
+// 计划时间到达时如果上一次还没结束则跳过本次, 记录到历史中
+type syncDaemon struct {
+	synchronizer *DBSynchronizer
+	config       DaemonConfig
+
+	mu      sync.Mutex
+	running *DaemonRun
+	paused  map[string]bool
+	history []DaemonRun
+	nextRun map[string]time.Time
+	lastID  int
+	wg      sync.WaitGroup
+}
+
+func newSyncDaemon(synchronizer *DBSynchronizer, config DaemonConfig) *syncDaemon {
+	return &syncDaemon{
+		synchronizer: synchronizer,
+		config:       config,
+		paused:       make(map[string]bool),
+		nextRun:      make(map[string]time.Time),
+	}
+}
+
+// 启动计划任务和HTTP接口, 收到退出信号后等待正在执行的同步结束
+func (d *syncDaemon) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, group := range d.config.Groups {
+		if group.Schedule == "" {
+			continue
+		}
+		schedule, err := parseSchedule(group.Schedule)
+		if err != nil {
+			return err
+		}
+		go d.schedule(ctx, group, schedule)
+	}
+
+	server := &http.Server{Addr: d.config.Listen, Handler: d.router()}
+	errs := make(chan error, 1)
+	go func() {
+		fmt.Printf("sync_db 守护进程监听 %s\n", d.config.Listen)
+		if d.config.Token == "" && !loopback(d.config.Listen) {
+			fmt.Println("警告: 接口监听非本机地址且没有配置 daemon.token, 任何人都可以触发同步")
+		}
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	fmt.Println("正在退出, 等待同步结束...")
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := server.Shutdown(shutdown)
+	d.wg.Wait()
+	return err
+}
+
+// 按计划执行一个分组, 下次时间在本次结束后计算
+func (d *syncDaemon) schedule(ctx context.Context, group SyncGroup, schedule *cronSchedule) {
+	for {
+		next := schedule.next(time.Now())
+		d.mu.Lock()
+		d.nextRun[group.Name] = next
+		d.mu.Unlock()
+		if next.IsZero() {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		run, tables, err := d.begin(group.Name, "schedule", group.Tables)
+		if err != nil {
+			fmt.Printf("分组 %s: 跳过计划同步: %v\n", group.Name, err)
+			continue
+		}
+		d.execute(run, tables)
+	}
+}
+
+// 开始一次运行, 已有运行时返回errRunning并记录跳过; 暂停的表不同步
+func (d *syncDaemon) begin(group, trigger string, tableNames []string) (*DaemonRun, []TableConfig, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lastID++
+	run := &DaemonRun{ID: d.lastID, Group: group, Trigger: trigger, Start: time.Now()}
+	if d.running != nil {
+		run.Skipped = fmt.Sprintf("%s (运行 %d)", errRunning, d.running.ID)
+		d.record(*run)
+		return nil, nil, errRunning
+	}
+
+	var tables []TableConfig
+	for _, table := range d.synchronizer.config.Tables {
+		if d.paused[table.TableName] || (len(tableNames) > 0 && !containsString(tableNames, table.TableName)) {
+			continue
+		}
+		tables = append(tables, table)
+		run.Tables = append(run.Tables, table.TableName)
+	}
+	if len(tables) == 0 {
+		run.Skipped = "没有需要同步的表"
+		d.record(*run)
+		return nil, nil, errors.New(run.Skipped)
+	}
+
+	d.running = run
+	d.wg.Add(1)
+	return run, tables, nil
+}
+
+func (d *syncDaemon) execute(run *DaemonRun, tables []TableConfig) {
+	defer d.wg.Done()
+	_, report := d.synchronizer.syncTables(tables)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	run.End = time.Now()
+	run.Report = report
+	d.running = nil
+	d.record(*run)
+	if report != nil {
+		fmt.Printf("分组 %s: %s\n", run.Group, report.summary())
+	}
+}
+
+// 调用时需持有mu
+func (d *syncDaemon) record(run DaemonRun) {
+	d.history = append(d.history, run)
+	if len(d.history) > d.config.History {
+		d.history = d.history[len(d.history)-d.config.History:]
+	}
+}
+
+func (d *syncDaemon) setPaused(table string, paused bool) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, config := range d.synchronizer.config.Tables {
+		if config.TableName == table {
+			if paused {
+				d.paused[table] = true
+			} else {
+				delete(d.paused, table)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// HTTP接口
+//
+//	GET  /status                当前运行、各表进度、分组下次执行时间
+//	GET  /history?limit=20      最近的运行记录
+//	POST /groups/:name/run      立即同步一个分组
+//	POST /tables/:name/run      立即同步一张表
+//	POST /tables/:name/pause    暂停一张表, 计划和接口触发的同步都跳过它
+//	POST /tables/:name/resume   恢复
+func (d *syncDaemon) router() *gin.Engine {
+	r := gin.Default()
+	r.Use(d.authorize)
+	r.GET("/status", d.handleStatus)
+	r.GET("/history", d.handleHistory)
+	r.POST("/groups/:name/run", d.handleRunGroup)
+	r.POST("/tables/:name/run", d.handleRunTable)
+	r.POST("/tables/:name/pause", func(c *gin.Context) { d.handlePause(c, true) })
+	r.POST("/tables/:name/resume", func(c *gin.Context) { d.handlePause(c, false) })
+	return r
+}
+
+// 配置了token时校验 Authorization: Bearer <token>
+func (d *syncDaemon) authorize(c *gin.Context) {
+	if d.config.Token == "" {
+		return
+	}
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(d.config.Token)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+	}
+}
+
+// 监听地址是否只在本机
+func loopback(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (d *syncDaemon) handleStatus(c *gin.Context) {
+	tables := d.synchronizer.progress.snapshot()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range tables {
+		tables[i].Paused = d.paused[tables[i].TableName]
+	}
+	groups := make([]gin.H, 0, len(d.config.Groups))
+	for _, group := range d.config.Groups {
+		item := gin.H{"name": group.Name, "schedule": group.Schedule, "tables": group.Tables}
+		if next, ok := d.nextRun[group.Name]; ok && !next.IsZero() {
+			item["next_run"] = next
+		}
+		groups = append(groups, item)
+	}
+	paused := make([]string, 0, len(d.paused))
+	for _, table := range d.synchronizer.config.Tables {
+		if d.paused[table.TableName] {
+			paused = append(paused, table.TableName)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"running": d.running,
+		"tables":  tables,
+		"paused":  paused,
+		"groups":  groups,
+	})
+}
+
+func (d *syncDaemon) handleHistory(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit 无效"})
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	history := d.history
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	// 最新的在前
+	runs := make([]DaemonRun, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		runs = append(runs, history[i])
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+func (d *syncDaemon) handleRunGroup(c *gin.Context) {
+	name := c.Param("name")
+	for _, group := range d.config.Groups {
+		if group.Name == name {
+			d.trigger(c, group.Name, group.Tables)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "分组不存在: " + name})
+}
+
+func (d *syncDaemon) handleRunTable(c *gin.Context) {
+	name := c.Param("name")
+	for _, table := range d.synchronizer.config.Tables {
+		if table.TableName == name {
+			d.trigger(c, "", []string{name})
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "表不存在: " + name})
+}
+
+// 接口触发的同步在后台执行, 立即返回运行记录
+func (d *syncDaemon) trigger(c *gin.Context, group string, tables []string) {
+	run, configs, err := d.begin(group, "api", tables)
+	if errors.Is(err, errRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	response := *run
+	go d.execute(run, configs)
+	c.JSON(http.StatusAccepted, response)
+}
+
+func (d *syncDaemon) handlePause(c *gin.Context, paused bool) {
+	name := c.Param("name")
+	if !d.setPaused(name, paused) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "表不存在: " + name})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"table": name, "paused": paused})
+}
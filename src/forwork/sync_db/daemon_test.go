@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestDaemon(t *testing.T) *syncDaemon {
+	gin.SetMode(gin.TestMode)
+	config := &SyncConfig{
+		Tables:     []TableConfig{{TableName: "T1"}, {TableName: "T2"}},
+		ReportFile: filepath.Join(t.TempDir(), "report.jsonl"),
+	}
+	daemon := DaemonConfig{Groups: []SyncGroup{{Name: "base", Schedule: "*/5 * * * *", Tables: []string{"T1"}}}}
+	if err := validateDaemonConfig(&daemon, config.Tables); err != nil {
+		t.Fatal(err)
+	}
+	return newSyncDaemon(NewDBSynchronizer(config), daemon)
+}
+
+func request(t *testing.T, handler http.Handler, method, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+	return recorder
+}
+
+func TestDaemonRejectsOverlappingRuns(t *testing.T) {
+	d := newTestDaemon(t)
+	run, tables, err := d.begin("base", "schedule", []string{"T1"})
+	if err != nil || len(tables) != 1 {
+		t.Fatalf("begin: %v %v", tables, err)
+	}
+
+	router := d.router()
+	if code := request(t, router, http.MethodPost, "/groups/base/run").Code; code != http.StatusConflict {
+		t.Fatalf("overlapping run got %d, want 409", code)
+	}
+	d.execute(run, nil)
+
+	var history []DaemonRun
+	if err := json.Unmarshal(request(t, router, http.MethodGet, "/history").Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].ID != run.ID || history[1].Skipped == "" {
+		t.Fatalf("unexpected history %+v", history)
+	}
+}
+
+func TestDaemonPauseResume(t *testing.T) {
+	d := newTestDaemon(t)
+	router := d.router()
+
+	if code := request(t, router, http.MethodPost, "/tables/T1/pause").Code; code != http.StatusOK {
+		t.Fatalf("pause got %d", code)
+	}
+	if code := request(t, router, http.MethodPost, "/tables/NOPE/pause").Code; code != http.StatusNotFound {
+		t.Fatalf("pause unknown table got %d", code)
+	}
+	// 分组只有T1, 暂停后没有需要同步的表
+	if code := request(t, router, http.MethodPost, "/groups/base/run").Code; code != http.StatusBadRequest {
+		t.Fatalf("run paused group got %d", code)
+	}
+
+	var status struct {
+		Paused []string `json:"paused"`
+	}
+	if err := json.Unmarshal(request(t, router, http.MethodGet, "/status").Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Paused) != 1 || status.Paused[0] != "T1" {
+		t.Fatalf("unexpected paused %v", status.Paused)
+	}
+
+	request(t, router, http.MethodPost, "/tables/T1/resume")
+	if _, tables, err := d.begin("base", "api", []string{"T1"}); err != nil || len(tables) != 1 {
+		t.Fatalf("resumed table should be synced: %v %v", tables, err)
+	}
+}
+
+func TestValidateDaemonConfig(t *testing.T) {
+	tables := []TableConfig{{TableName: "T1"}}
+	config := DaemonConfig{}
+	if err := validateDaemonConfig(&config, tables); err != nil {
+		t.Fatal(err)
+	}
+	if config.Listen == "" || len(config.Groups) != 1 || config.Groups[0].Name != "all" {
+		t.Fatalf("unexpected defaults %+v", config)
+	}
+
+	for _, groups := range [][]SyncGroup{
+		{{Name: "a"}, {Name: "a"}},
+		{{Name: "a", Tables: []string{"T9"}}},
+		{{Name: "a", Schedule: "bad"}},
+	} {
+		config := DaemonConfig{Groups: groups}
+		if err := validateDaemonConfig(&config, tables); err == nil {
+			t.Errorf("%+v should be invalid", groups)
+		}
+	}
+}
+
+func TestDaemonToken(t *testing.T) {
+	d := newTestDaemon(t)
+	d.config.Token = "secret"
+	router := d.router()
+
+	if code := request(t, router, http.MethodPost, "/tables/T1/pause").Code; code != http.StatusUnauthorized {
+		t.Fatalf("no token got %d", code)
+	}
+	for token, want := range map[string]int{"Bearer wrong": http.StatusUnauthorized, "secret": http.StatusUnauthorized, "Bearer secret": http.StatusOK} {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/tables/T1/pause", nil)
+		req.Header.Set("Authorization", token)
+		router.ServeHTTP(recorder, req)
+		if recorder.Code != want {
+			t.Errorf("%q got %d, want %d", token, recorder.Code, want)
+		}
+	}
+	if !d.paused["T1"] {
+		t.Fatal("authorized pause not applied")
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	for listen, want := range map[string]bool{"127.0.0.1:18090": true, "localhost:1": true, "[::1]:1": true, ":18090": false, "0.0.0.0:1": false} {
+		if loopback(listen) != want {
+			t.Errorf("%s: want %v", listen, want)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 对比时每张表最多记录的差异主键数
+const maxDiffKeys = 1000
+
+// 主键范围 (Lower, Upper], nil表示不限
+type keyRange struct {
+	Lower []interface{}
+	Upper []interface{}
+}
+
+// 范围内的行数和校验值
+type rangeDigest struct {
+	Count int64
+	Hash  uint64
+}
+
+// 表对比结果
+type TableDiff struct {
+	TableName       string
+	MissingInTarget []string // 源端有, 目标端没有
+	ExtraInTarget   []string // 目标端有, 源端没有
+	Different       []string // 两端都有但内容不一致
+	MissingCount    int
+	ExtraCount      int
+	DifferentCount  int
+	RangesChecked   int
+	Errors          []error
+}
+
+// 是否一致
+func (d *TableDiff) Equal() bool {
+	return len(d.Errors) == 0 && d.MissingCount == 0 && d.ExtraCount == 0 && d.DifferentCount == 0
+}
+
+// 对比所有配置的表, 只读不写
+func (s *DBSynchronizer) DiffAll() []TableDiff {
+	stop := s.progress.start(5 * time.Second)
+	defer stop()
+
+	results := make([]TableDiff, 0, len(s.config.Tables))
+	for _, tableConfig := range s.config.Tables {
+		results = append(results, s.DiffTable(tableConfig))
+	}
+	return results
+}
+
+// 对比单个表
+//
+// 按源端主键把表分成若干范围, 两端分别在数据库中计算每个范围的行数和校验值,
+// 不一致的范围继续二分, 直到行数不超过 diff_leaf_size 时才读取记录逐条比较;
+// 不能在数据库中计算时按主键合并两端记录逐条比较一遍
+func (s *DBSynchronizer) DiffTable(config TableConfig) TableDiff {
+	diff := TableDiff{TableName: config.TableName}
+	if len(config.PrimaryKey) == 0 {
+		diff.Errors = append(diff.Errors, fmt.Errorf("表 %s 未配置主键", config.TableName))
+		return diff
+	}
+
+	start := time.Now()
+	mapping, err := newColumnMapping(config)
+	if err != nil {
+		diff.Errors = append(diff.Errors, err)
+		return diff
+	}
+	columns, err := s.diffColumns(config, mapping)
+	if err != nil {
+		diff.Errors = append(diff.Errors, err)
+		return diff
+	}
+	comparer := s.newRecordComparer(config)
+
+	// 不能在数据库中计算校验值时, 分段二分每层都要重新读取记录, 不如直接合并比较一遍
+	if !s.pushDigest(config) {
+		fmt.Printf("表 %s: 两端数据库类型不同、不支持计算校验值或配置了列转换, 逐条读取对比整张表\n", config.TableName)
+		diff.RangesChecked++
+		if err := s.diffRows(config, mapping, comparer, keyRange{}, &diff); err != nil {
+			diff.Errors = append(diff.Errors, err)
+			return diff
+		}
+		fmt.Printf("表 %s: 逐条对比完成, 耗时 %s\n", config.TableName, time.Since(start).Round(time.Millisecond))
+		return diff
+	}
+
+	var lower []interface{}
+	for {
+		upper, err := s.keyAt(s.config.SourceDB, s.sourceTableName(config), config, keyRange{Lower: lower}, s.config.DiffChunkSize-1)
+		if err != nil {
+			diff.Errors = append(diff.Errors, fmt.Errorf("划分主键范围失败: %w", err))
+			return diff
+		}
+		if err := s.diffRange(config, mapping, comparer, columns, keyRange{Lower: lower, Upper: upper}, &diff); err != nil {
+			diff.Errors = append(diff.Errors, err)
+			return diff
+		}
+		if upper == nil {
+			break
+		}
+		lower = upper
+	}
+
+	fmt.Printf("表 %s: 对比完成, 检查 %d 个范围, 耗时 %s\n", config.TableName, diff.RangesChecked, time.Since(start).Round(time.Millisecond))
+	return diff
+}
+
+func (s *DBSynchronizer) diffRange(config TableConfig, mapping *columnMapping, comparer *recordComparer, columns []string, r keyRange, diff *TableDiff) error {
+	diff.RangesChecked++
+	targetConfig := config.targetSide()
+	source, err := s.digest(s.config.SourceDB, s.sourceTableName(config), config, columns, r)
+	if err != nil {
+		return fmt.Errorf("计算源端校验值失败: %w", err)
+	}
+	target, err := s.digest(s.config.TargetDB, s.targetTableName(config), targetConfig, columns, r)
+	if err != nil {
+		return fmt.Errorf("计算目标端校验值失败: %w", err)
+	}
+	if source == target {
+		return nil
+	}
+
+	count := source.Count
+	db, tableName, sideConfig := s.config.SourceDB, s.sourceTableName(config), config
+	if target.Count > count {
+		count = target.Count
+		db, tableName, sideConfig = s.config.TargetDB, s.targetTableName(config), targetConfig
+	}
+	if count <= int64(s.config.DiffLeafSize) {
+		return s.diffRows(config, mapping, comparer, r, diff)
+	}
+
+	// 在行数多的一端取中间的主键二分
+	mid, err := s.keyAt(db, tableName, sideConfig, r, int(count/2)-1)
+	if err != nil {
+		return fmt.Errorf("划分主键范围失败: %w", err)
+	}
+	if mid == nil {
+		return s.diffRows(config, mapping, comparer, r, diff)
+	}
+	if err := s.diffRange(config, mapping, comparer, columns, keyRange{Lower: r.Lower, Upper: mid}, diff); err != nil {
+		return err
+	}
+	return s.diffRange(config, mapping, comparer, columns, keyRange{Lower: mid, Upper: r.Upper}, diff)
+}
+
+// 读取范围内两端的记录逐条比较
+//
+// 校验值不一致但逐条比较在容差内相等的范围不计为差异
+func (s *DBSynchronizer) diffRows(config TableConfig, mapping *columnMapping, comparer *recordComparer, r keyRange, diff *TableDiff) error {
+	targetConfig := config.targetSide()
+	record := func(keys *[]string, count *int, row map[string]interface{}) {
+		*count++
+		if len(*keys) < maxDiffKeys {
+			*keys = append(*keys, s.generatePrimaryKey(row, targetConfig.PrimaryKey))
+		}
+	}
+
+	return mergeStreams(
+		&mappedStream{s.newRangeStream(s.config.SourceDB, config, s.sourceTableName(config), r), mapping},
+		s.newRangeStream(s.config.TargetDB, targetConfig, s.targetTableName(config), r),
+		targetConfig.PrimaryKey,
+		func(source map[string]interface{}) error {
+			record(&diff.MissingInTarget, &diff.MissingCount, source)
+			return nil
+		},
+		func(source, target map[string]interface{}) error {
+			if !comparer.equal(source, target) {
+				record(&diff.Different, &diff.DifferentCount, source)
+			}
+			return nil
+		},
+		func(target map[string]interface{}) error {
+			record(&diff.ExtraInTarget, &diff.ExtraCount, target)
+			return nil
+		})
+}
+
+// 只读取范围内记录的流
+func (s *DBSynchronizer) newRangeStream(db *gorm.DB, config TableConfig, tableName string, r keyRange) *keysetStream {
+	stream := s.newKeysetStream(db, config, tableName)
+	stream.lastKey = r.Lower
+	conditions := stream.conditions
+	stream.conditions = func(query *gorm.DB) *gorm.DB {
+		query = conditions(query)
+		if r.Upper != nil {
+			condition, values := keyBoundCondition(config.PrimaryKey, r.Upper, "<", true)
+			query = query.Where(condition, values...)
+		}
+		return query
+	}
+	return stream
+}
+
+// 范围查询
+func (s *DBSynchronizer) rangeQuery(db *gorm.DB, tableName string, config TableConfig, r keyRange) *gorm.DB {
+	query := s.applyFilters(db.Table(tableName), config)
+	if r.Lower != nil {
+		condition, values := keyBoundCondition(config.PrimaryKey, r.Lower, ">", false)
+		query = query.Where(condition, values...)
+	}
+	if r.Upper != nil {
+		condition, values := keyBoundCondition(config.PrimaryKey, r.Upper, "<", true)
+		query = query.Where(condition, values...)
+	}
+	return query
+}
+
+// 范围内按主键排序第offset条记录的主键, 没有时返回nil
+func (s *DBSynchronizer) keyAt(db *gorm.DB, tableName string, config TableConfig, r keyRange, offset int) ([]interface{}, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	var rows []map[string]interface{}
+	err := s.rangeQuery(db, tableName, config, r).
+		Select(strings.Join(config.PrimaryKey, ", ")).
+		Order(strings.Join(config.PrimaryKey, ", ")).
+		Offset(offset).
+		Limit(1).
+		Find(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return primaryKeyValues(rows[0], config.PrimaryKey), nil
+}
+
+// 参与校验的列, 取源端转换后和目标端都有的列, 不含ignore_columns, 按名称排序
+func (s *DBSynchronizer) diffColumns(config TableConfig, mapping *columnMapping) ([]string, error) {
+	sourceColumns, err := tableColumns(s.config.SourceDB, s.sourceTableName(config))
+	if err != nil {
+		return nil, fmt.Errorf("读取源端列失败: %w", err)
+	}
+	targetColumns, err := tableColumns(s.config.TargetDB, s.targetTableName(config))
+	if err != nil {
+		return nil, fmt.Errorf("读取目标端列失败: %w", err)
+	}
+
+	// 用一条空记录经过转换得到源端会写入的列
+	empty := make(map[string]interface{}, len(sourceColumns))
+	for _, column := range sourceColumns {
+		empty[column] = nil
+	}
+	mapped := mapping.apply(empty)
+
+	columns := make([]string, 0, len(targetColumns))
+	for _, column := range targetColumns {
+		if _, ok := mapped[column]; ok {
+			columns = append(columns, column)
+		}
+	}
+	if len(columns) != len(mapped) || len(columns) != len(targetColumns) {
+		fmt.Printf("表 %s: 两端列不一致, 只校验共同的 %d 列\n", config.TableName, len(columns))
+	}
+	checked := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if !containsString(config.IgnoreColumns, column) {
+			checked = append(checked, column)
+		}
+	}
+	sort.Strings(checked)
+	return checked, nil
+}
+
+// 查询表的列名, 不读取数据
+func tableColumns(db *gorm.DB, tableName string) ([]string, error) {
+	rows, err := db.Table(tableName).Where("1 = 0").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+// 两端是同一种支持计算校验值的数据库且没有列转换时才能在数据库中计算校验值, 两端的结果才可比较
+func (s *DBSynchronizer) pushDigest(config TableConfig) bool {
+	dialect := s.config.SourceDB.Dialector.Name()
+	if dialect != s.config.TargetDB.Dialector.Name() || config.hasMapping() {
+		return false
+	}
+	_, ok := digestSelect(dialect, []string{"id"})
+	return ok
+}
+
+// 在数据库中计算范围的行数和校验值, 不读取记录
+func (s *DBSynchronizer) digest(db *gorm.DB, tableName string, config TableConfig, columns []string, r keyRange) (rangeDigest, error) {
+	var digest rangeDigest
+	query, ok := digestSelect(db.Dialector.Name(), columns)
+	if !ok {
+		return digest, fmt.Errorf("数据库 %s 不支持计算校验值", db.Dialector.Name())
+	}
+	row := s.rangeQuery(db, tableName, config, r).Select(query).Row()
+	err := row.Scan(&digest.Count, &digest.Hash)
+	return digest, err
+}
+
+// 各数据库计算行数和校验值的查询列, 不支持的数据库返回false
+func digestSelect(dialect string, columns []string) (string, bool) {
+	values := make([]string, 0, len(columns))
+	switch dialect {
+	case "mysql":
+		for _, column := range columns {
+			values = append(values, fmt.Sprintf("COALESCE(CAST(%s AS CHAR), '<null>')", column))
+		}
+		return fmt.Sprintf("COUNT(*), COALESCE(SUM(CRC32(CONCAT_WS('#', %s))), 0)", strings.Join(values, ", ")), true
+	case "dm":
+		// 达梦没有CRC32, 用ORA_HASH, 结果在0到2^32-1之间
+		for _, column := range columns {
+			values = append(values, fmt.Sprintf("COALESCE(CAST(%s AS VARCHAR), '<null>')", column))
+		}
+		return fmt.Sprintf("COUNT(*), CAST(COALESCE(SUM(ORA_HASH(%s)), 0) AS BIGINT)", strings.Join(values, " || '#' || ")), true
+	}
+	return "", false
+}
+
+// 单行的校验值, 各行相加与顺序无关
+func rowHash(record map[string]interface{}, columns []string) uint64 {
+	h := fnv.New64a()
+	for _, column := range columns {
+		value := record[column]
+		switch v := value.(type) {
+		case nil:
+			h.Write([]byte("<null>"))
+		case time.Time:
+			h.Write([]byte(v.UTC().Format(time.RFC3339Nano)))
+		default:
+			h.Write(valueBytes(v))
+		}
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeyBoundConditionInclusive(t *testing.T) {
+	condition, values := keyBoundCondition([]string{"A", "B"}, []interface{}{1, 2}, "<", true)
+	if condition != "((A < ?) OR (A = ? AND B < ?) OR (A = ? AND B = ?))" {
+		t.Fatalf("unexpected condition %s", condition)
+	}
+	if !reflect.DeepEqual(values, []interface{}{1, 1, 2, 1, 2}) {
+		t.Fatalf("unexpected values %v", values)
+	}
+}
+
+func TestRowHash(t *testing.T) {
+	columns := []string{"ID", "NAME", "TIME"}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	a := map[string]interface{}{"ID": int64(1), "NAME": []byte("x"), "TIME": at}
+	b := map[string]interface{}{"ID": int64(1), "NAME": "x", "TIME": at.In(time.FixedZone("CST", 8*3600))}
+	if rowHash(a, columns) != rowHash(b, columns) {
+		t.Fatal("same row should have same hash")
+	}
+
+	b["NAME"] = nil
+	if rowHash(a, columns) == rowHash(b, columns) {
+		t.Fatal("different row should have different hash")
+	}
+}
+
+func TestDigestSelect(t *testing.T) {
+	query, ok := digestSelect("dm", []string{"id", "name"})
+	if !ok {
+		t.Fatal("dm should support digest")
+	}
+	want := "COUNT(*), CAST(COALESCE(SUM(ORA_HASH(COALESCE(CAST(id AS VARCHAR), '<null>') || '#' || COALESCE(CAST(name AS VARCHAR), '<null>'))), 0) AS BIGINT)"
+	if query != want {
+		t.Fatalf("query = %s", query)
+	}
+	if _, ok := digestSelect("postgres", []string{"id"}); ok {
+		t.Fatal("postgres should not support digest")
+	}
+}
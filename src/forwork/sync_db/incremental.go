@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 增量同步水位, 保存在本地json文件
+type syncState struct {
+	mu       sync.Mutex
+	filePath string
+	Tables   map[string]*tableState `json:"tables"`
+}
+
+type tableState struct {
+	HighWater     string    `json:"high_water,omitempty"`
+	HighWaterType string    `json:"high_water_type,omitempty"` // time/number/string
+	LastFullSync  time.Time `json:"last_full_sync,omitempty"`
+	LastSync      time.Time `json:"last_sync,omitempty"`
+}
+
+func loadSyncState(filePath string) (*syncState, error) {
+	state := &syncState{filePath: filePath, Tables: make(map[string]*tableState)}
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取水位文件失败: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("解析水位文件失败: %w", err)
+	}
+	if state.Tables == nil {
+		state.Tables = make(map[string]*tableState)
+	}
+	return state, nil
+}
+
+func (state *syncState) get(key string) tableState {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if table, ok := state.Tables[key]; ok {
+		return *table
+	}
+	return tableState{}
+}
+
+// 更新并写回文件, 先写临时文件再改名, 中途退出不会损坏原文件
+func (state *syncState) set(key string, table tableState) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.Tables[key] = &table
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(state.filePath); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := state.filePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, state.filePath)
+}
+
+// 水位key, 同一张表同步到不同目标时分开记录
+func stateKey(config TableConfig) string {
+	return fmt.Sprintf("%s.%s->%s.%s", config.Schema1, config.TableName, config.Schema2, config.targetTable())
+}
+
+func encodeHighWater(value interface{}) (string, string) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano), "time"
+	case []byte:
+		return string(v), "string"
+	case string:
+		return v, "string"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v), "number"
+	}
+	return fmt.Sprint(value), "string"
+}
+
+func decodeHighWater(value, kind string) (interface{}, error) {
+	switch kind {
+	case "time":
+		return time.Parse(time.RFC3339Nano, value)
+	case "number":
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i, nil
+		}
+		return strconv.ParseFloat(value, 64)
+	}
+	return value, nil
+}
+
+// 根据水位决定增量还是全量同步, 新水位在删除阶段成功后保存, 见saveHighWater
+//
+// 新水位在读取数据之前查询, 同步过程中修改的记录下次还会被读到;
+// 增量查询用 >=, 与水位相同时间修改的记录也不会漏掉
+func (s *DBSynchronizer) syncWithHighWater(run *tableRun) error {
+	s.stateOnce.Do(func() {
+		s.state, s.stateErr = loadSyncState(s.config.StateFile)
+	})
+	if s.stateErr != nil {
+		return s.stateErr
+	}
+	config, result := run.config, &run.result
+
+	key := stateKey(config)
+	state := s.state.get(key)
+	highWater, err := s.queryHighWater(config)
+	if err != nil {
+		return fmt.Errorf("查询 %s 最大值失败: %w", config.IncrementalField, err)
+	}
+
+	now := time.Now()
+	fullDue := s.config.FullSyncInterval > 0 && now.Sub(state.LastFullSync) >= s.config.FullSyncInterval
+	if state.HighWater == "" || fullDue {
+		if err := s.syncFull(run); err != nil {
+			return err
+		}
+		state.LastFullSync = now
+	} else {
+		since, err := decodeHighWater(state.HighWater, state.HighWaterType)
+		if err != nil {
+			return fmt.Errorf("水位格式错误: %w", err)
+		}
+		result.Mode = "incremental"
+		if err := s.syncIncremental(run, since); err != nil {
+			return err
+		}
+	}
+
+	if highWater != nil {
+		state.HighWater, state.HighWaterType = encodeHighWater(highWater)
+	}
+	state.LastSync = now
+	run.highWater = &pendingState{key: key, state: state}
+	return nil
+}
+
+// 待保存的水位
+type pendingState struct {
+	key   string
+	state tableState
+}
+
+// 新增更新和删除都成功后才推进水位, 有失败时下次重新同步;
+// 全量同步的删除没执行成功时不记录LastFullSync, 避免删除要等到下个full_sync_interval
+func (s *DBSynchronizer) saveHighWater(run *tableRun) {
+	pending := run.highWater
+	run.highWater = nil
+	if pending == nil || len(run.result.Errors) > 0 {
+		return
+	}
+	if err := s.state.set(pending.key, pending.state); err != nil {
+		run.result.Errors = append(run.result.Errors, fmt.Errorf("保存水位失败: %w", err))
+	}
+}
+
+// 源表增量字段当前最大值
+func (s *DBSynchronizer) queryHighWater(config TableConfig) (interface{}, error) {
+	query := s.config.SourceDB.Table(s.sourceTableName(config)).Select("MAX(" + config.IncrementalField + ")")
+	query = s.applyFilters(query, config)
+
+	var highWater interface{}
+	if err := query.Row().Scan(&highWater); err != nil {
+		return nil, err
+	}
+	return highWater, nil
+}
+
+// 增量同步, 只读取源端增量字段不小于水位的记录, 按批到目标端查询后新增或更新
+//
+// 增量同步发现不了源端删除的记录, 需要靠定期全量同步处理
+func (s *DBSynchronizer) syncIncremental(run *tableRun, since interface{}) error {
+	config := run.config
+	tableName := s.targetTableName(config)
+	batchSize := s.batchSize(config)
+
+	source := s.newKeysetStream(s.config.SourceDB, config, s.sourceTableName(config))
+	conditions := source.conditions
+	source.conditions = func(query *gorm.DB) *gorm.DB {
+		return conditions(query).Where(config.IncrementalField+" >= ?", since)
+	}
+
+	mapped := &mappedStream{source, run.mapping}
+	batch := make([]map[string]interface{}, 0, batchSize)
+	for {
+		record, err := mapped.Next()
+		if err != nil {
+			return fmt.Errorf("读取源数据库失败: %w", err)
+		}
+		if record != nil {
+			batch = append(batch, record)
+		}
+		if len(batch) > 0 && (record == nil || len(batch) >= batchSize) {
+			if err := s.applyChanged(tableName, batch, run); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+		if record == nil {
+			break
+		}
+	}
+
+	fmt.Printf("表 %s: 增量读取 %d 条 (%s >= %v)\n", config.TableName, source.read, config.IncrementalField, since)
+	return nil
+}
+
+// 把一批源端记录写入目标端, 目标端不存在的新增, 不一致的更新
+func (s *DBSynchronizer) applyChanged(tableName string, records []map[string]interface{}, run *tableRun) error {
+	config := run.config.targetSide()
+	run.progress.compared.Add(int64(len(records)))
+	condition, values := keysCondition(config.PrimaryKey, records)
+	var targetRecords []map[string]interface{}
+	if err := s.config.TargetDB.Table(tableName).Where(condition, values...).Find(&targetRecords).Error; err != nil {
+		return fmt.Errorf("读取目标数据库失败: %w", err)
+	}
+	numeric := numericKeyColumns(config.PrimaryKey, records, targetRecords)
+	targetData := make(map[string]map[string]interface{}, len(targetRecords))
+	for _, record := range targetRecords {
+		targetData[normalizedKey(record, config.PrimaryKey, numeric)] = record
+	}
+
+	var inserts, updates []map[string]interface{}
+	for _, sourceRecord := range records {
+		targetRecord, exists := targetData[normalizedKey(sourceRecord, config.PrimaryKey, numeric)]
+		if !exists {
+			inserts = append(inserts, sourceRecord)
+		} else if !run.comparer.equal(sourceRecord, targetRecord) {
+			updates = append(updates, updateRecord(sourceRecord, targetRecord, config.PrimaryKey))
+		}
+	}
+
+	s.apply(opInsert, tableName, inserts, run)
+	s.apply(opUpdate, tableName, updates, run)
+	return nil
+}
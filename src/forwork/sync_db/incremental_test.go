@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHighWaterRoundTrip(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.Local)
+	for _, value := range []interface{}{now, int64(42), "20240506"} {
+		encoded, kind := encodeHighWater(value)
+		decoded, err := decodeHighWater(encoded, kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compareValue(decoded, value) != 0 {
+			t.Fatalf("%v decoded as %v", value, decoded)
+		}
+	}
+}
+
+func TestSyncStatePersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "sync.json")
+	state, err := loadSyncState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := TableConfig{TableName: "T", Schema1: "A", Schema2: "B"}
+	if err := state.set(stateKey(config), tableState{HighWater: "42", HighWaterType: "number"}); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadSyncState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.get(stateKey(config)); got.HighWater != "42" || got.HighWaterType != "number" {
+		t.Fatalf("unexpected state %+v", got)
+	}
+}
+
+func TestStateKeyTargetTable(t *testing.T) {
+	config := TableConfig{TableName: "T", TargetTable: "T2", Schema1: "A", Schema2: "B"}
+	if key := stateKey(config); key != "A.T->B.T2" {
+		t.Fatalf("unexpected key %s", key)
+	}
+	config.TargetTable = ""
+	if key := stateKey(config); key != "A.T->B.T" {
+		t.Fatalf("unexpected key %s", key)
+	}
+}
+
+func TestSaveHighWaterAfterDeletes(t *testing.T) {
+	state, err := loadSyncState(filepath.Join(t.TempDir(), "sync.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &DBSynchronizer{config: &SyncConfig{}, state: state, progress: newProgressBoard()}
+	run := s.newTableRun(TableConfig{TableName: "T"})
+	run.highWater = &pendingState{key: "K", state: tableState{HighWater: "42"}}
+	run.result.Errors = append(run.result.Errors, errors.New("删除失败"))
+	s.syncDeletes(run)
+	if got := state.get("K"); got.HighWater != "" || run.highWater != nil {
+		t.Fatalf("删除失败时不应保存水位: %+v", got)
+	}
+
+	run = s.newTableRun(TableConfig{TableName: "T"})
+	run.highWater = &pendingState{key: "K", state: tableState{HighWater: "42"}}
+	s.syncDeletes(run)
+	if got := state.get("K"); got.HighWater != "42" {
+		t.Fatalf("unexpected state %+v", got)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 列转换
+//
+//	trim / upper / lower
+//	date_format: 时间格式化为字符串, format为Go时间格式, 如 "2006-01-02"; 字符串值按from的格式解析
+//	replace_prefix: 把from开头替换成to, 如替换单位编码
+//	replace: 把from全部替换成to
+type ColumnTransform struct {
+	Column string `yaml:"column"` // 目标列名
+	Type   string `yaml:"type"`
+	Format string `yaml:"format"`
+	From   string `yaml:"from"`
+	To     string `yaml:"to"`
+}
+
+// 源端记录到目标端记录的转换
+type columnMapping struct {
+	include    map[string]bool // 为空时包含全部列
+	exclude    map[string]bool
+	rename     map[string]string
+	transforms []ColumnTransform
+	defaults   map[string]interface{}
+	constants  map[string]interface{}
+}
+
+// 是否配置了列转换
+func (config TableConfig) hasMapping() bool {
+	return len(config.Columns) > 0 || len(config.ExcludeColumns) > 0 || len(config.ColumnMap) > 0 ||
+		len(config.Transforms) > 0 || len(config.Defaults) > 0 || len(config.Constants) > 0
+}
+
+// 目标表名, 不带schema
+func (config TableConfig) targetTable() string {
+	if config.TargetTable != "" {
+		return config.TargetTable
+	}
+	return config.TableName
+}
+
+// 源端列名对应的目标端列名
+func (config TableConfig) targetColumn(column string) string {
+	if name, ok := config.ColumnMap[column]; ok {
+		return name
+	}
+	return column
+}
+
+// 目标端的主键
+func (config TableConfig) targetPrimaryKey() []string {
+	primaryKeys := make([]string, 0, len(config.PrimaryKey))
+	for _, pk := range config.PrimaryKey {
+		primaryKeys = append(primaryKeys, config.targetColumn(pk))
+	}
+	return primaryKeys
+}
+
+// 用于查询和写入目标端的配置: 表名、主键和查询条件换成目标端的
+func (config TableConfig) targetSide() TableConfig {
+	target := config
+	target.TableName = config.targetTable()
+	target.PrimaryKey = config.targetPrimaryKey()
+	if len(config.WhereCondition) > 0 {
+		target.WhereCondition = make(map[string]interface{}, len(config.WhereCondition))
+		for column, value := range config.WhereCondition {
+			target.WhereCondition[config.targetColumn(column)] = value
+		}
+	}
+	if config.TargetWhere != "" {
+		target.Where, target.WhereArgs = config.TargetWhere, config.TargetWhereArgs
+	}
+	return target
+}
+
+func newColumnMapping(config TableConfig) (*columnMapping, error) {
+	m := &columnMapping{
+		include:    make(map[string]bool),
+		exclude:    make(map[string]bool),
+		rename:     config.ColumnMap,
+		transforms: config.Transforms,
+		defaults:   config.Defaults,
+		constants:  config.Constants,
+	}
+	for _, column := range config.Columns {
+		m.include[column] = true
+	}
+	for _, column := range config.ExcludeColumns {
+		m.exclude[column] = true
+	}
+
+	for _, pk := range config.PrimaryKey {
+		if (len(m.include) > 0 && !m.include[pk]) || m.exclude[pk] {
+			return nil, fmt.Errorf("表 %s: 主键 %s 不能被排除", config.TableName, pk)
+		}
+	}
+	targetKeys := config.targetPrimaryKey()
+	for _, transform := range config.Transforms {
+		switch transform.Type {
+		case "trim", "upper", "lower", "date_format", "replace_prefix", "replace":
+		default:
+			return nil, fmt.Errorf("表 %s: 不支持的转换 %s", config.TableName, transform.Type)
+		}
+		if containsString(targetKeys, transform.Column) {
+			return nil, fmt.Errorf("表 %s: 主键 %s 不能转换, 会打乱主键顺序", config.TableName, transform.Column)
+		}
+	}
+	for column := range config.Constants {
+		if containsString(targetKeys, column) {
+			return nil, fmt.Errorf("表 %s: 主键 %s 不能设置常量", config.TableName, column)
+		}
+	}
+	return m, nil
+}
+
+// 源端的列是否同步
+func (m *columnMapping) includes(column string) bool {
+	return (len(m.include) == 0 || m.include[column]) && !m.exclude[column]
+}
+
+// 把源端记录转换成目标端记录
+func (m *columnMapping) apply(record map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(record)+len(m.constants))
+	for column, value := range record {
+		if !m.includes(column) {
+			continue
+		}
+		if name, ok := m.rename[column]; ok {
+			column = name
+		}
+		result[column] = value
+	}
+	for _, transform := range m.transforms {
+		if value, ok := result[transform.Column]; ok {
+			result[transform.Column] = transform.apply(value)
+		}
+	}
+	for column, value := range m.defaults {
+		if result[column] == nil {
+			result[column] = value
+		}
+	}
+	for column, value := range m.constants {
+		result[column] = value
+	}
+	return result
+}
+
+func (t ColumnTransform) apply(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if t.Type == "date_format" {
+		switch v := value.(type) {
+		case time.Time:
+			return v.Format(t.Format)
+		case string, []byte:
+			if parsed, err := time.ParseInLocation(t.From, stringValue(v), time.Local); err == nil {
+				return parsed.Format(t.Format)
+			}
+		}
+		return value
+	}
+
+	var text string
+	switch v := value.(type) {
+	case string, []byte:
+		text = stringValue(v)
+	default:
+		return value
+	}
+	switch t.Type {
+	case "trim":
+		return strings.TrimSpace(text)
+	case "upper":
+		return strings.ToUpper(text)
+	case "lower":
+		return strings.ToLower(text)
+	case "replace_prefix":
+		if strings.HasPrefix(text, t.From) {
+			return t.To + strings.TrimPrefix(text, t.From)
+		}
+		return text
+	case "replace":
+		return strings.ReplaceAll(text, t.From, t.To)
+	}
+	return value
+}
+
+func stringValue(value interface{}) string {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(value)
+}
+
+// 读取时做列转换的流
+type mappedStream struct {
+	recordStream
+	mapping *columnMapping
+}
+
+func (m *mappedStream) Next() (map[string]interface{}, error) {
+	record, err := m.recordStream.Next()
+	if record == nil || err != nil {
+		return record, err
+	}
+	return m.mapping.apply(record), nil
+}
+
+// 应用where_condition和where条件
+func (s *DBSynchronizer) applyFilters(query *gorm.DB, config TableConfig) *gorm.DB {
+	if len(config.WhereCondition) > 0 {
+		query = s.applyWhereCondition(query, config.WhereCondition)
+	}
+	if config.Where != "" {
+		query = query.Where(config.Where, config.WhereArgs...)
+	}
+	return query
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestColumnMapping(t *testing.T) {
+	config := TableConfig{
+		TableName:      "T",
+		PrimaryKey:     []string{"ID"},
+		ExcludeColumns: []string{"STAMP"},
+		ColumnMap:      map[string]string{"ID": "OBJ_ID", "NAME": "OBJ_NAME"},
+		Defaults:       map[string]interface{}{"MEMO": "-"},
+		Constants:      map[string]interface{}{"SOURCE": "prod"},
+		Transforms: []ColumnTransform{
+			{Column: "OBJ_NAME", Type: "trim"},
+			{Column: "OWNER", Type: "replace_prefix", From: "3503", To: "3501"},
+			{Column: "DAY", Type: "date_format", Format: "2006-01-02"},
+		},
+	}
+	mapping, err := newColumnMapping(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := mapping.apply(map[string]interface{}{
+		"ID":    int64(1),
+		"NAME":  []byte(" 开关 "),
+		"OWNER": "350300",
+		"DAY":   time.Date(2024, 3, 1, 8, 0, 0, 0, time.Local),
+		"STAMP": int64(99),
+		"MEMO":  nil,
+	})
+	want := map[string]interface{}{
+		"OBJ_ID":   int64(1),
+		"OBJ_NAME": "开关",
+		"OWNER":    "350100",
+		"DAY":      "2024-03-01",
+		"MEMO":     "-",
+		"SOURCE":   "prod",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if pk := config.targetPrimaryKey(); !reflect.DeepEqual(pk, []string{"OBJ_ID"}) {
+		t.Fatalf("target primary key %v", pk)
+	}
+}
+
+func TestColumnMappingInvalid(t *testing.T) {
+	cases := []TableConfig{
+		{TableName: "T", PrimaryKey: []string{"ID"}, Columns: []string{"NAME"}},
+		{TableName: "T", PrimaryKey: []string{"ID"}, Transforms: []ColumnTransform{{Column: "ID", Type: "trim"}}},
+		{TableName: "T", PrimaryKey: []string{"ID"}, Transforms: []ColumnTransform{{Column: "NAME", Type: "unknown"}}},
+	}
+	for _, config := range cases {
+		if _, err := newColumnMapping(config); err == nil {
+			t.Errorf("config %+v should fail", config)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 每张表在报告中最多记录的变更主键数
+const maxChangedKeys = 100
+
+// 一次同步的运行报告
+type RunReport struct {
+	ID     string        `json:"id"` // 开始时间, 同时用作导出目录名
+	Start  time.Time     `json:"start"`
+	End    time.Time     `json:"end"`
+	Tables []TableReport `json:"tables"`
+}
+
+type TableReport struct {
+	TableName   string    `json:"table_name"`
+	Mode        string    `json:"mode"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Added       int       `json:"added"`
+	Updated     int       `json:"updated"`
+	Deleted     int       `json:"deleted"`
+	SoftDeleted int       `json:"soft_deleted"`
+	Failed      int       `json:"failed"`
+	FailedKeys  []string  `json:"failed_keys,omitempty"`
+	ChangedKeys []string  `json:"changed_keys,omitempty"` // 变更记录的主键样例, 如 "update:1001"
+	Errors      []string  `json:"errors,omitempty"`
+
+	SourceAdded   int        `json:"source_added,omitempty"`
+	SourceUpdated int        `json:"source_updated,omitempty"`
+	SourceDeleted int        `json:"source_deleted,omitempty"`
+	ConflictCount int        `json:"conflict_count,omitempty"`
+	Conflicts     []Conflict `json:"conflicts,omitempty"` // 需要人工处理的冲突
+}
+
+// 运行ID, 也用作导出目录名; 同一秒内多次运行(守护进程触发)时用微秒和随机后缀区分
+func newRunID(start time.Time) string {
+	suffix := make([]byte, 2)
+	_, _ = rand.Read(suffix)
+	return start.Format("20060102_150405.000000") + "_" + hex.EncodeToString(suffix)
+}
+
+func newRunReport(id string, start, end time.Time, results []SyncResult) *RunReport {
+	report := &RunReport{
+		ID:     id,
+		Start:  start,
+		End:    end,
+		Tables: make([]TableReport, 0, len(results)),
+	}
+	for _, result := range results {
+		table := TableReport{
+			TableName:   result.TableName,
+			Mode:        result.Mode,
+			Start:       result.Start,
+			End:         result.End,
+			Added:       result.Added,
+			Updated:     result.Updated,
+			Deleted:     result.Deleted,
+			SoftDeleted: result.SoftDeleted,
+			Failed:      result.FailedCount,
+			FailedKeys:  result.FailedKeys,
+			ChangedKeys: result.ChangedKeys,
+
+			SourceAdded:   result.SourceAdded,
+			SourceUpdated: result.SourceUpdated,
+			SourceDeleted: result.SourceDeleted,
+			ConflictCount: result.ConflictCount,
+			Conflicts:     result.Conflicts,
+		}
+		for _, err := range result.Errors {
+			table.Errors = append(table.Errors, err.Error())
+		}
+		report.Tables = append(report.Tables, table)
+	}
+	return report
+}
+
+// 保存运行报告: 追加到本地日志文件, 配置了report_table时同时写入目标库
+func (s *DBSynchronizer) saveReport(report *RunReport) error {
+	var errs []string
+	if s.config.ReportFile != "" {
+		if err := appendReport(s.config.ReportFile, report); err != nil {
+			errs = append(errs, fmt.Sprintf("写入报告文件失败: %v", err))
+		}
+	}
+	if s.config.ReportTable != "" {
+		if err := s.insertReport(s.config.ReportTable, report); err != nil {
+			errs = append(errs, fmt.Sprintf("写入报告表失败: %v", err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// 报告文件每行一次运行的JSON
+func appendReport(filename string, report *RunReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// 读取报告文件, 按时间顺序返回
+func loadReports(filename string) ([]RunReport, error) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var reports []RunReport
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var report RunReport
+		if err := json.Unmarshal([]byte(line), &report); err != nil {
+			return nil, fmt.Errorf("第 %d 行解析失败: %w", i+1, err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// 报告表结构, 每次运行每张表一行
+var reportTableSchema = &tableSchema{
+	Columns: []columnInfo{
+		{Name: "RUN_ID", Type: "VARCHAR", Length: 32},
+		{Name: "TABLE_NAME", Type: "VARCHAR", Length: 128},
+		{Name: "SYNC_MODE", Type: "VARCHAR", Length: 16},
+		{Name: "START_TIME", Type: "TIMESTAMP"},
+		{Name: "END_TIME", Type: "TIMESTAMP"},
+		{Name: "ADDED", Type: "INT"},
+		{Name: "UPDATED", Type: "INT"},
+		{Name: "DELETED", Type: "INT"},
+		{Name: "SOFT_DELETED", Type: "INT"},
+		{Name: "FAILED", Type: "INT"},
+		{Name: "ERRORS", Type: "CLOB", Nullable: true},
+		{Name: "CHANGED_KEYS", Type: "CLOB", Nullable: true},
+	},
+	PrimaryKey: []string{"RUN_ID", "TABLE_NAME"},
+}
+
+// 写入目标库的报告表, 表不存在时先建表
+func (s *DBSynchronizer) insertReport(tableName string, report *RunReport) error {
+	db := s.config.TargetDB
+	if _, err := tableColumns(db, tableName); err != nil {
+		if err := db.Exec(createTableDDL(db.Dialector.Name(), tableName, reportTableSchema)).Error; err != nil {
+			return fmt.Errorf("创建报告表失败: %w", err)
+		}
+	}
+
+	rows := make([]map[string]interface{}, 0, len(report.Tables))
+	for _, table := range report.Tables {
+		rows = append(rows, map[string]interface{}{
+			"RUN_ID":       report.ID,
+			"TABLE_NAME":   table.TableName,
+			"SYNC_MODE":    table.Mode,
+			"START_TIME":   table.Start,
+			"END_TIME":     table.End,
+			"ADDED":        table.Added,
+			"UPDATED":      table.Updated,
+			"DELETED":      table.Deleted,
+			"SOFT_DELETED": table.SoftDeleted,
+			"FAILED":       table.Failed,
+			"ERRORS":       strings.Join(table.Errors, "\n"),
+			"CHANGED_KEYS": strings.Join(table.ChangedKeys, "\n"),
+		})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		return tx.Table(tableName).Create(rows).Error
+	})
+}
+
+// 把写入成功的记录导出到文件
+//
+//	csv: <export_dir>/<运行ID>/<表名>_<操作>.csv, 每种操作的列不同所以分文件;
+//	     同一操作后面的批次有新的列时另写 <表名>_<操作>_2.csv 等, 不丢列
+//	sql: <export_dir>/<运行ID>/<表名>.sql, 按执行顺序记录等价的SQL语句
+//	双向同步写回源端的记录在 <表名>_source_<操作>.csv 和 <表名>_source.sql
+type changeExporter struct {
+	mu     sync.Mutex
+	dir    string
+	format string
+	files  map[string]*exportFile
+}
+
+type exportFile struct {
+	file    *os.File
+	csv     *csv.Writer
+	columns []string
+}
+
+func newChangeExporter(dir, format string) (*changeExporter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &changeExporter{dir: dir, format: format, files: make(map[string]*exportFile)}, nil
+}
+
+// 记录一批写入成功的记录, name为操作名, 写回源端的带source_前缀
+func (e *changeExporter) write(op writeOp, name, tableName string, records []map[string]interface{}, config TableConfig) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.format == "sql" {
+		file := config.TableName + ".sql"
+		if strings.HasPrefix(name, "source_") {
+			file = config.TableName + "_source.sql"
+		}
+		f, err := e.open(file)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if _, err := fmt.Fprintln(f.file, changeSQL(op, tableName, record, config)+";"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	f, err := e.csvFile(config.TableName+"_"+name, recordColumns(records))
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		row := make([]string, len(f.columns))
+		for i, column := range f.columns {
+			if value := record[column]; value != nil {
+				row[i] = csvValue(value)
+			}
+		}
+		if err := f.csv.Write(row); err != nil {
+			return err
+		}
+	}
+	f.csv.Flush()
+	return f.csv.Error()
+}
+
+// 表头包含所有列的CSV文件, 都不包含时新建一个, 第一个文件不带序号
+func (e *changeExporter) csvFile(base string, columns []string) (*exportFile, error) {
+	for i := 1; ; i++ {
+		name := base + ".csv"
+		if i > 1 {
+			name = fmt.Sprintf("%s_%d.csv", base, i)
+		}
+		f, err := e.open(name)
+		if err != nil {
+			return nil, err
+		}
+		if f.columns == nil {
+			f.columns = columns
+			return f, f.csv.Write(columns)
+		}
+		if containsAll(f.columns, columns) {
+			return f, nil
+		}
+	}
+}
+
+func containsAll(set, values []string) bool {
+	exists := make(map[string]bool, len(set))
+	for _, v := range set {
+		exists[v] = true
+	}
+	for _, v := range values {
+		if !exists[v] {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *changeExporter) open(name string) (*exportFile, error) {
+	if f, ok := e.files[name]; ok {
+		return f, nil
+	}
+	file, err := os.Create(filepath.Join(e.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	f := &exportFile{file: file, csv: csv.NewWriter(file)}
+	e.files[name] = f
+	return f, nil
+}
+
+func (e *changeExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var firstErr error
+	for _, f := range e.files {
+		if err := f.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	e.files = make(map[string]*exportFile)
+	return firstErr
+}
+
+// 一条记录对应的SQL语句, 值直接写成字面量
+func changeSQL(op writeOp, tableName string, record map[string]interface{}, config TableConfig) string {
+	columns := recordColumns([]map[string]interface{}{record})
+	where := make([]string, 0, len(config.PrimaryKey))
+	for _, pk := range config.PrimaryKey {
+		where = append(where, pk+" = "+sqlLiteral(record[pk]))
+	}
+	condition := strings.Join(where, " AND ")
+
+	switch op {
+	case opInsert:
+		values := make([]string, 0, len(columns))
+		for _, column := range columns {
+			values = append(values, sqlLiteral(record[column]))
+		}
+		return "INSERT INTO " + tableName + " (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(values, ", ") + ")"
+	case opUpdate:
+		var sets []string
+		for _, column := range columns {
+			if !containsString(config.PrimaryKey, column) {
+				sets = append(sets, column+" = "+sqlLiteral(record[column]))
+			}
+		}
+		if len(sets) == 0 {
+			return "-- " + tableName + " " + condition + " 只有主键列"
+		}
+		return "UPDATE " + tableName + " SET " + strings.Join(sets, ", ") + " WHERE " + condition
+	case opSoftDelete:
+		return "UPDATE " + tableName + " SET " + config.SoftDeleteField + " = " + sqlLiteral(config.SoftDeleteValue) + " WHERE " + condition
+	default:
+		return "DELETE FROM " + tableName + " WHERE " + condition
+	}
+}
+
+// SQL字面量, 时间写成达梦和MySQL都能隐式转换的字符串
+func sqlLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case *big.Float:
+		return v.Text('f', -1)
+	case time.Time:
+		return "'" + v.Format("2006-01-02 15:04:05.999999") + "'"
+	default:
+		return "'" + strings.ReplaceAll(stringValue(v), "'", "''") + "'"
+	}
+}
+
+func csvValue(value interface{}) string {
+	if t, ok := value.(time.Time); ok {
+		return t.Format("2006-01-02 15:04:05.999999")
+	}
+	return stringValue(value)
+}
+
+// 一行的运行摘要, 用于输出历史
+func (r *RunReport) summary() string {
+	var added, updated, deleted, failed, errs int
+	for _, table := range r.Tables {
+		added += table.Added
+		updated += table.Updated
+		deleted += table.Deleted + table.SoftDeleted
+		failed += table.Failed
+		errs += len(table.Errors)
+	}
+	return fmt.Sprintf("%s 耗时 %s, %d 张表, 新增 %d 更新 %d 删除 %d 失败 %d 错误 %d",
+		r.ID, r.End.Sub(r.Start).Round(time.Millisecond), len(r.Tables), added, updated, deleted, failed, errs)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportFileRoundTrip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "report.jsonl")
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.Local)
+	results := []SyncResult{
+		{TableName: "T1", Mode: "full", Added: 2, ChangedKeys: []string{"insert:1", "insert:2"}},
+		{TableName: "T2", Mode: "incremental", FailedCount: 1, FailedKeys: []string{"9"}, Errors: []error{errors.New("boom")}},
+	}
+	for i := 0; i < 2; i++ {
+		runStart := start.Add(time.Duration(i) * time.Hour)
+		if err := appendReport(filename, newRunReport(newRunID(runStart), runStart, runStart.Add(time.Minute), results)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reports, err := loadReports(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 || !strings.HasPrefix(reports[1].ID, "20240301_090000.000000_") {
+		t.Fatalf("unexpected reports %+v", reports)
+	}
+	table := reports[0].Tables[1]
+	if table.Failed != 1 || len(table.Errors) != 1 || table.Errors[0] != "boom" {
+		t.Fatalf("unexpected table report %+v", table)
+	}
+	if summary := reports[0].summary(); !strings.Contains(summary, "新增 2") || !strings.Contains(summary, "错误 1") {
+		t.Fatalf("unexpected summary %s", summary)
+	}
+}
+
+func TestChangeSQL(t *testing.T) {
+	config := TableConfig{PrimaryKey: []string{"ID"}, SoftDeleteField: "DELETED", SoftDeleteValue: "1"}
+	record := map[string]interface{}{
+		"ID":      int64(7),
+		"NAME":    "O'Brien",
+		"AMOUNT":  1.5,
+		"CREATED": time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC),
+		"NOTE":    nil,
+	}
+
+	tests := []struct {
+		op   writeOp
+		want string
+	}{
+		{opInsert, "INSERT INTO WEB.T (AMOUNT, CREATED, ID, NAME, NOTE) VALUES (1.5, '2024-03-01 08:00:00', 7, 'O''Brien', NULL)"},
+		{opUpdate, "UPDATE WEB.T SET AMOUNT = 1.5, CREATED = '2024-03-01 08:00:00', NAME = 'O''Brien', NOTE = NULL WHERE ID = 7"},
+		{opSoftDelete, "UPDATE WEB.T SET DELETED = '1' WHERE ID = 7"},
+		{opHardDelete, "DELETE FROM WEB.T WHERE ID = 7"},
+	}
+	for _, tt := range tests {
+		if got := changeSQL(tt.op, "WEB.T", record, config); got != tt.want {
+			t.Errorf("%s:\n%s\nwant:\n%s", tt.op, got, tt.want)
+		}
+	}
+}
+
+func TestChangeExporterCSV(t *testing.T) {
+	dir := t.TempDir()
+	exporter, err := newChangeExporter(dir, "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := TableConfig{TableName: "T", PrimaryKey: []string{"ID"}}
+	exporter.write(opInsert, "insert", "WEB.T", []map[string]interface{}{{"ID": 1, "NAME": "a,b"}}, config)
+	exporter.write(opInsert, "insert", "WEB.T", []map[string]interface{}{{"ID": 2, "NAME": nil}}, config)
+	exporter.write(opHardDelete, "delete", "WEB.T", []map[string]interface{}{{"ID": 3}}, config)
+	// 按列分组写入的批次列不同, 新的列另写文件, 列更少的写入已有文件
+	exporter.write(opUpdate, "update", "WEB.T", []map[string]interface{}{{"ID": 4, "NAME": "x"}}, config)
+	exporter.write(opUpdate, "update", "WEB.T", []map[string]interface{}{{"ID": 5, "AGE": 30}}, config)
+	exporter.write(opUpdate, "update", "WEB.T", []map[string]interface{}{{"ID": 6}}, config)
+	if err := exporter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "T_insert.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ID,NAME\n1,\"a,b\"\n2,\n"; string(data) != want {
+		t.Fatalf("insert csv %q, want %q", data, want)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "T_delete.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ID\n3\n"; string(data) != want {
+		t.Fatalf("delete csv %q, want %q", data, want)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "T_update.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ID,NAME\n4,x\n6,\n"; string(data) != want {
+		t.Fatalf("update csv %q, want %q", data, want)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "T_update_2.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "AGE,ID\n30,5\n"; string(data) != want {
+		t.Fatalf("update csv %q, want %q", data, want)
+	}
+}
+
+func TestNewRunIDUnique(t *testing.T) {
+	start := time.Now()
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := newRunID(start)
+		if len(id) > 32 {
+			t.Fatalf("%s 超过RUN_ID列长度", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) < 90 {
+		t.Fatalf("同一时间的运行ID重复过多: %d", len(seen))
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"raselper/src/secondary/utils"
+)
+
+// 表之间的依赖, deps[i]为第i张表依赖的表序号
+//
+// depends_on 按表名引用; process_order 较小的表视为被较大的表依赖
+func tableDependencies(tables []TableConfig) ([][]int, error) {
+	byName := make(map[string][]int)
+	for i, table := range tables {
+		byName[table.TableName] = append(byName[table.TableName], i)
+	}
+
+	deps := make([][]int, len(tables))
+	for i, table := range tables {
+		seen := make(map[int]bool)
+		add := func(j int) {
+			if j != i && !seen[j] {
+				seen[j] = true
+				deps[i] = append(deps[i], j)
+			}
+		}
+		for _, name := range table.DependsOn {
+			indexes, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("表 %s 依赖的表 %s 不在配置中", table.TableName, name)
+			}
+			for _, j := range indexes {
+				add(j)
+			}
+		}
+		if table.ProcessOrder > 0 {
+			for j, other := range tables {
+				if other.ProcessOrder > 0 && other.ProcessOrder < table.ProcessOrder {
+					add(j)
+				}
+			}
+		}
+		sort.Ints(deps[i])
+	}
+
+	if cycle := findCycle(deps); cycle != nil {
+		names := make([]string, 0, len(cycle))
+		for _, i := range cycle {
+			names = append(names, tables[i].TableName)
+		}
+		return nil, fmt.Errorf("表依赖存在循环: %s", strings.Join(names, " -> "))
+	}
+	return deps, nil
+}
+
+// 查找依赖环, 没有时返回nil
+func findCycle(deps [][]int) []int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make([]int, len(deps))
+	var stack []int
+	var visit func(i int) []int
+	visit = func(i int) []int {
+		states[i] = visiting
+		stack = append(stack, i)
+		for _, j := range deps[i] {
+			switch states[j] {
+			case visiting:
+				for k, v := range stack {
+					if v == j {
+						return append(append([]int{}, stack[k:]...), j)
+					}
+				}
+			case unvisited:
+				if cycle := visit(j); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		states[i] = visited
+		return nil
+	}
+	for i := range deps {
+		if states[i] == unvisited {
+			if cycle := visit(i); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// 按依赖顺序在线程池中执行任务, 一张表的依赖全部完成后才开始
+// reverse时反过来, 依赖它的表全部完成后才开始(删除时子表先删)
+func runOrdered(deps [][]int, parallelism int, reverse bool, task func(i int)) {
+	n := len(deps)
+	if n == 0 {
+		return
+	}
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+
+	// waits[i]: i需要等待的任务; next[j]: j完成后可能就绪的任务
+	waits := make([]int, n)
+	next := make([][]int, n)
+	for i, list := range deps {
+		for _, j := range list {
+			if reverse {
+				waits[j]++
+				next[i] = append(next[i], j)
+			} else {
+				waits[i]++
+				next[j] = append(next[j], i)
+			}
+		}
+	}
+
+	pool := utils.NewWorkerPool(parallelism, n)
+	defer pool.Close()
+	done := make(chan int, n)
+	submit := func(i int) {
+		pool.Submit(func() {
+			task(i)
+			done <- i
+		})
+	}
+
+	for i := 0; i < n; i++ {
+		if waits[i] == 0 {
+			submit(i)
+		}
+	}
+	for finished := 0; finished < n; finished++ {
+		i := <-done
+		for _, j := range next[i] {
+			waits[j]--
+			if waits[j] == 0 {
+				submit(j)
+			}
+		}
+	}
+	pool.Wait()
+}
+
+// 每张表的进度
+type tableProgress struct {
+	name     string
+	status   atomic.Value // string
+	read     atomic.Int64 // 两端读取的行数
+	compared atomic.Int64
+	written  atomic.Int64
+}
+
+func (p *tableProgress) setStatus(status string) {
+	p.status.Store(status)
+}
+
+// 重新开始一次同步, 守护进程中同一张表会同步多次
+func (p *tableProgress) reset() {
+	p.setStatus("等待")
+	p.read.Store(0)
+	p.compared.Store(0)
+	p.written.Store(0)
+}
+
+func (p *tableProgress) String() string {
+	status, _ := p.status.Load().(string)
+	return fmt.Sprintf("%s [%s] 读取 %d 比较 %d 写入 %d", p.name, status, p.read.Load(), p.compared.Load(), p.written.Load())
+}
+
+// 输出给HTTP接口的表进度
+type TableStatus struct {
+	TableName string `json:"table_name"`
+	Status    string `json:"status"`
+	Read      int64  `json:"read"`
+	Compared  int64  `json:"compared"`
+	Written   int64  `json:"written"`
+	Paused    bool   `json:"paused"`
+}
+
+// 全部表的进度
+type progressBoard struct {
+	mu     sync.Mutex
+	tables map[string]*tableProgress
+	order  []string
+}
+
+func newProgressBoard() *progressBoard {
+	return &progressBoard{tables: make(map[string]*tableProgress)}
+}
+
+func (b *progressBoard) table(name string) *tableProgress {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p, ok := b.tables[name]; ok {
+		return p
+	}
+	p := &tableProgress{name: name}
+	p.setStatus("等待")
+	b.tables[name] = p
+	b.order = append(b.order, name)
+	return p
+}
+
+// 定期输出未完成表的进度, 调用返回的函数停止
+func (b *progressBoard) start(interval time.Duration) func() {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				b.print()
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+// 所有表的当前进度
+func (b *progressBoard) snapshot() []TableStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	statuses := make([]TableStatus, 0, len(b.order))
+	for _, name := range b.order {
+		p := b.tables[name]
+		status, _ := p.status.Load().(string)
+		statuses = append(statuses, TableStatus{
+			TableName: name,
+			Status:    status,
+			Read:      p.read.Load(),
+			Compared:  p.compared.Load(),
+			Written:   p.written.Load(),
+		})
+	}
+	return statuses
+}
+
+func (b *progressBoard) print() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var lines []string
+	for _, name := range b.order {
+		p := b.tables[name]
+		if status, _ := p.status.Load().(string); status != "完成" && status != "等待" {
+			lines = append(lines, "  "+p.String())
+		}
+	}
+	if len(lines) > 0 {
+		fmt.Printf("进度 %s:\n%s\n", time.Now().Format("15:04:05"), strings.Join(lines, "\n"))
+	}
+}
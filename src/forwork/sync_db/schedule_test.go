@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestTableDependencies(t *testing.T) {
+	tables := []TableConfig{
+		{TableName: "REL", ProcessOrder: 3},
+		{TableName: "B", ProcessOrder: 2},
+		{TableName: "C", ProcessOrder: 1},
+		{TableName: "CHILD", DependsOn: []string{"B"}},
+	}
+	deps, err := tableDependencies(tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]int{{1, 2}, {2}, nil, {1}}
+	if !reflect.DeepEqual(deps, want) {
+		t.Fatalf("deps = %v, want %v", deps, want)
+	}
+
+	tables[2].DependsOn = []string{"CHILD"}
+	if _, err := tableDependencies(tables); err == nil {
+		t.Fatal("cycle should fail")
+	}
+	tables[2].DependsOn = []string{"MISSING"}
+	if _, err := tableDependencies(tables); err == nil {
+		t.Fatal("unknown table should fail")
+	}
+}
+
+func TestRunOrdered(t *testing.T) {
+	// 0 <- 1 <- 2, 3独立
+	deps := [][]int{nil, {0}, {1}, nil}
+	for _, reverse := range []bool{false, true} {
+		var mu sync.Mutex
+		position := make(map[int]int)
+		runOrdered(deps, 3, reverse, func(i int) {
+			mu.Lock()
+			defer mu.Unlock()
+			position[i] = len(position)
+		})
+		if len(position) != 4 {
+			t.Fatalf("ran %d tasks", len(position))
+		}
+		if !reverse && !(position[0] < position[1] && position[1] < position[2]) {
+			t.Fatalf("parents should run first: %v", position)
+		}
+		if reverse && !(position[2] < position[1] && position[1] < position[0]) {
+			t.Fatalf("children should run first: %v", position)
+		}
+	}
+}
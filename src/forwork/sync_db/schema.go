@@ -0,0 +1,504 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 列信息, Type为统一后的类型名
+type columnInfo struct {
+	Name      string
+	Type      string
+	Length    int64 // CHAR/VARCHAR长度
+	Precision int64 // DECIMAL精度
+	Scale     int64
+	Nullable  bool
+}
+
+type indexInfo struct {
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+// 表结构
+type tableSchema struct {
+	Exists     bool
+	Columns    []columnInfo
+	PrimaryKey []string
+	Indexes    []indexInfo
+}
+
+// 表结构对比结果
+type SchemaDiff struct {
+	TableName      string
+	TargetMissing  bool     // 目标表不存在
+	MissingColumns []string // 目标端缺少的列
+	ExtraColumns   []string // 目标端多出的列, 只报告不删除
+	ChangedColumns []string // 类型、长度或可空不一致的列
+	PrimaryKey     string   // 主键不一致的说明
+	MissingIndexes []string
+	ExtraIndexes   []string
+	DDL            []string // 使目标端与源端一致的语句, 不包含删除
+	Errors         []error
+}
+
+// 是否一致
+func (d *SchemaDiff) Equal() bool {
+	return len(d.Errors) == 0 && !d.TargetMissing && len(d.MissingColumns) == 0 && len(d.ExtraColumns) == 0 &&
+		len(d.ChangedColumns) == 0 && d.PrimaryKey == "" && len(d.MissingIndexes) == 0 && len(d.ExtraIndexes) == 0
+}
+
+// 是否会导致同步失败: 目标表或列不存在
+func (d *SchemaDiff) Blocking() bool {
+	return len(d.Errors) > 0 || d.TargetMissing || len(d.MissingColumns) > 0
+}
+
+// 对比所有配置的表结构
+func (s *DBSynchronizer) SchemaAll() []SchemaDiff {
+	results := make([]SchemaDiff, 0, len(s.config.Tables))
+	for _, tableConfig := range s.config.Tables {
+		results = append(results, s.CompareSchema(tableConfig))
+	}
+	return results
+}
+
+// 对比单个表的结构, 源端列按列配置换成目标端列名后再比较
+func (s *DBSynchronizer) CompareSchema(config TableConfig) SchemaDiff {
+	diff := SchemaDiff{TableName: config.TableName}
+	mapping, err := newColumnMapping(config)
+	if err != nil {
+		diff.Errors = append(diff.Errors, err)
+		return diff
+	}
+
+	source, err := loadTableSchema(s.config.SourceDB, config.Schema1, config.TableName)
+	if err != nil {
+		diff.Errors = append(diff.Errors, fmt.Errorf("读取源端表结构失败: %w", err))
+		return diff
+	}
+	if !source.Exists {
+		diff.Errors = append(diff.Errors, fmt.Errorf("源表 %s 不存在", s.sourceTableName(config)))
+		return diff
+	}
+	target, err := loadTableSchema(s.config.TargetDB, config.Schema2, config.targetTable())
+	if err != nil {
+		diff.Errors = append(diff.Errors, fmt.Errorf("读取目标端表结构失败: %w", err))
+		return diff
+	}
+
+	compareSchemas(&diff, mapSchema(source, config, mapping), target, s.targetTableName(config), s.config.TargetDB.Dialector.Name())
+	return diff
+}
+
+// 执行DDL
+func (s *DBSynchronizer) ApplyDDL(diff *SchemaDiff) error {
+	for _, ddl := range diff.DDL {
+		fmt.Printf("执行: %s\n", ddl)
+		if err := s.config.TargetDB.Exec(ddl).Error; err != nil {
+			return fmt.Errorf("执行DDL失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// 把源端表结构换成目标端列名, 去掉不同步的列
+func mapSchema(source *tableSchema, config TableConfig, mapping *columnMapping) *tableSchema {
+	mapped := &tableSchema{Exists: source.Exists}
+	for _, column := range source.Columns {
+		if !mapping.includes(column.Name) {
+			continue
+		}
+		column.Name = config.targetColumn(column.Name)
+		mapped.Columns = append(mapped.Columns, column)
+	}
+	for _, pk := range source.PrimaryKey {
+		mapped.PrimaryKey = append(mapped.PrimaryKey, config.targetColumn(pk))
+	}
+next:
+	for _, index := range source.Indexes {
+		columns := make([]string, 0, len(index.Columns))
+		for _, column := range index.Columns {
+			if !mapping.includes(column) {
+				continue next
+			}
+			columns = append(columns, config.targetColumn(column))
+		}
+		index.Columns = columns
+		mapped.Indexes = append(mapped.Indexes, index)
+	}
+	return mapped
+}
+
+// 对比结构并生成DDL
+func compareSchemas(diff *SchemaDiff, source, target *tableSchema, tableName, dialect string) {
+	if !target.Exists {
+		diff.TargetMissing = true
+		diff.DDL = append(diff.DDL, createTableDDL(dialect, tableName, source))
+		for _, index := range source.Indexes {
+			diff.DDL = append(diff.DDL, createIndexDDL(tableName, index))
+		}
+		return
+	}
+
+	modify := "MODIFY"
+	add := "ADD"
+	if dialect == "mysql" {
+		modify, add = "MODIFY COLUMN", "ADD COLUMN"
+	}
+
+	targetColumns := make(map[string]columnInfo, len(target.Columns))
+	for _, column := range target.Columns {
+		targetColumns[strings.ToUpper(column.Name)] = column
+	}
+	sourceColumns := make(map[string]bool, len(source.Columns))
+	for _, column := range source.Columns {
+		sourceColumns[strings.ToUpper(column.Name)] = true
+		targetColumn, ok := targetColumns[strings.ToUpper(column.Name)]
+		if !ok {
+			diff.MissingColumns = append(diff.MissingColumns, column.Name+" "+columnType(dialect, column))
+			// 新增列允许为空, 已有数据的表加非空列会失败
+			diff.DDL = append(diff.DDL, fmt.Sprintf("ALTER TABLE %s %s %s %s", tableName, add, column.Name, columnType(dialect, column)))
+			continue
+		}
+		if !sameColumn(column, targetColumn) {
+			diff.ChangedColumns = append(diff.ChangedColumns, fmt.Sprintf("%s: %s -> %s", column.Name,
+				columnDefinition(dialect, targetColumn), columnDefinition(dialect, column)))
+			diff.DDL = append(diff.DDL, fmt.Sprintf("ALTER TABLE %s %s %s %s", tableName, modify, targetColumn.Name, columnDefinition(dialect, column)))
+		}
+	}
+	for _, column := range target.Columns {
+		if !sourceColumns[strings.ToUpper(column.Name)] {
+			diff.ExtraColumns = append(diff.ExtraColumns, column.Name)
+		}
+	}
+
+	if !sameColumns(source.PrimaryKey, target.PrimaryKey) {
+		diff.PrimaryKey = fmt.Sprintf("(%s) -> (%s)", strings.Join(target.PrimaryKey, ", "), strings.Join(source.PrimaryKey, ", "))
+	}
+
+	for _, index := range source.Indexes {
+		if findIndex(target.Indexes, index) < 0 {
+			diff.MissingIndexes = append(diff.MissingIndexes, indexDescription(index))
+			diff.DDL = append(diff.DDL, createIndexDDL(tableName, index))
+		}
+	}
+	for _, index := range target.Indexes {
+		if findIndex(source.Indexes, index) < 0 {
+			diff.ExtraIndexes = append(diff.ExtraIndexes, indexDescription(index))
+		}
+	}
+}
+
+func sameColumn(a, b columnInfo) bool {
+	if a.Type != b.Type || a.Nullable != b.Nullable {
+		return false
+	}
+	switch a.Type {
+	case "CHAR", "VARCHAR":
+		return a.Length == b.Length
+	case "DECIMAL":
+		return a.Precision == b.Precision && a.Scale == b.Scale
+	}
+	return true
+}
+
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// 按列和唯一性查找索引, 不比较索引名
+func findIndex(indexes []indexInfo, index indexInfo) int {
+	for i, other := range indexes {
+		if other.Unique == index.Unique && sameColumns(other.Columns, index.Columns) {
+			return i
+		}
+	}
+	return -1
+}
+
+func indexDescription(index indexInfo) string {
+	unique := ""
+	if index.Unique {
+		unique = "UNIQUE "
+	}
+	return fmt.Sprintf("%s%s (%s)", unique, index.Name, strings.Join(index.Columns, ", "))
+}
+
+func createTableDDL(dialect, tableName string, schema *tableSchema) string {
+	lines := make([]string, 0, len(schema.Columns)+1)
+	for _, column := range schema.Columns {
+		lines = append(lines, "  "+column.Name+" "+columnDefinition(dialect, column))
+	}
+	if len(schema.PrimaryKey) > 0 {
+		lines = append(lines, "  PRIMARY KEY ("+strings.Join(schema.PrimaryKey, ", ")+")")
+	}
+	return "CREATE TABLE " + tableName + " (\n" + strings.Join(lines, ",\n") + "\n)"
+}
+
+func createIndexDDL(tableName string, index indexInfo) string {
+	unique := ""
+	if index.Unique {
+		unique = "UNIQUE "
+	}
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", unique, index.Name, tableName, strings.Join(index.Columns, ", "))
+}
+
+func columnDefinition(dialect string, column columnInfo) string {
+	if column.Nullable {
+		return columnType(dialect, column) + " NULL"
+	}
+	return columnType(dialect, column) + " NOT NULL"
+}
+
+// 目标数据库的类型写法
+func columnType(dialect string, column columnInfo) string {
+	switch column.Type {
+	case "CHAR", "VARCHAR":
+		return fmt.Sprintf("%s(%d)", column.Type, column.Length)
+	case "DECIMAL":
+		if column.Precision > 0 {
+			return fmt.Sprintf("DECIMAL(%d,%d)", column.Precision, column.Scale)
+		}
+		if dialect == "mysql" {
+			return "DECIMAL(38,10)"
+		}
+		return "DECIMAL"
+	case "TIMESTAMP":
+		if dialect == "mysql" {
+			return "DATETIME"
+		}
+	case "CLOB":
+		if dialect == "mysql" {
+			return "LONGTEXT"
+		}
+	case "BLOB":
+		if dialect == "mysql" {
+			return "LONGBLOB"
+		}
+	}
+	return column.Type
+}
+
+// 统一达梦和MySQL的类型名
+func normalizeType(dataType string) string {
+	dataType = strings.ToUpper(strings.TrimSpace(dataType))
+	if i := strings.Index(dataType, "("); i >= 0 {
+		dataType = strings.TrimSpace(dataType[:i])
+	}
+	switch dataType {
+	case "VARCHAR2", "NVARCHAR", "NVARCHAR2", "CHARACTER VARYING":
+		return "VARCHAR"
+	case "NCHAR", "CHARACTER":
+		return "CHAR"
+	case "NUMBER", "NUMERIC", "DEC":
+		return "DECIMAL"
+	case "INTEGER", "PLS_INTEGER", "MEDIUMINT":
+		return "INT"
+	case "BYTE":
+		return "TINYINT"
+	case "REAL":
+		return "FLOAT"
+	case "DOUBLE PRECISION":
+		return "DOUBLE"
+	case "DATETIME":
+		return "TIMESTAMP"
+	case "TEXT", "LONGVARCHAR", "LONGTEXT", "MEDIUMTEXT", "TINYTEXT":
+		return "CLOB"
+	case "IMAGE", "LONGVARBINARY", "LONGBLOB", "MEDIUMBLOB", "TINYBLOB":
+		return "BLOB"
+	}
+	return dataType
+}
+
+// 读取表结构, schema为空时使用当前schema
+func loadTableSchema(db *gorm.DB, schema, table string) (*tableSchema, error) {
+	switch db.Dialector.Name() {
+	case "mysql":
+		if schema == "" {
+			if err := db.Raw("SELECT DATABASE()").Row().Scan(&schema); err != nil {
+				return nil, err
+			}
+		}
+		return loadSchema(db, schema, table, mysqlSchemaSQL)
+	case "dm":
+		if schema == "" {
+			if err := db.Raw("SELECT SYS_CONTEXT('USERENV', 'CURRENT_SCHEMA') FROM DUAL").Row().Scan(&schema); err != nil {
+				return nil, err
+			}
+		}
+		return loadSchema(db, schema, table, dmSchemaSQL)
+	}
+	return nil, fmt.Errorf("不支持读取 %s 的表结构", db.Dialector.Name())
+}
+
+// 查询表结构的语句, 参数都是 schema, table
+type schemaSQL struct {
+	columns    string // 列名, 类型, 长度, 精度, 小数位, 是否可空(Y/N)
+	primaryKey string // 列名, 按顺序
+	indexes    string // 索引名, 列名, 是否唯一(Y/N), 按索引名和顺序
+}
+
+var dmSchemaSQL = schemaSQL{
+	columns: `SELECT COLUMN_NAME, DATA_TYPE, DATA_LENGTH, DATA_PRECISION, DATA_SCALE, NULLABLE
+FROM ALL_TAB_COLUMNS WHERE OWNER = ? AND TABLE_NAME = ? ORDER BY COLUMN_ID`,
+	primaryKey: `SELECT COLS.COLUMN_NAME FROM ALL_CONSTRAINTS CONS, ALL_CONS_COLUMNS COLS
+WHERE CONS.OWNER = ? AND CONS.TABLE_NAME = ? AND CONS.CONSTRAINT_TYPE = 'P'
+AND COLS.OWNER = CONS.OWNER AND COLS.CONSTRAINT_NAME = CONS.CONSTRAINT_NAME ORDER BY COLS.POSITION`,
+	indexes: `SELECT IDX.INDEX_NAME, COLS.COLUMN_NAME, CASE WHEN IDX.UNIQUENESS = 'UNIQUE' THEN 'Y' ELSE 'N' END
+FROM ALL_INDEXES IDX, ALL_IND_COLUMNS COLS
+WHERE IDX.TABLE_OWNER = ? AND IDX.TABLE_NAME = ?
+AND COLS.INDEX_OWNER = IDX.OWNER AND COLS.INDEX_NAME = IDX.INDEX_NAME ORDER BY IDX.INDEX_NAME, COLS.COLUMN_POSITION`,
+}
+
+var mysqlSchemaSQL = schemaSQL{
+	columns: `SELECT COLUMN_NAME, DATA_TYPE, CHARACTER_MAXIMUM_LENGTH, NUMERIC_PRECISION, NUMERIC_SCALE,
+CASE WHEN IS_NULLABLE = 'YES' THEN 'Y' ELSE 'N' END
+FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION`,
+	primaryKey: `SELECT COLUMN_NAME FROM information_schema.STATISTICS
+WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND INDEX_NAME = 'PRIMARY' ORDER BY SEQ_IN_INDEX`,
+	indexes: `SELECT INDEX_NAME, COLUMN_NAME, CASE WHEN NON_UNIQUE = 0 THEN 'Y' ELSE 'N' END
+FROM information_schema.STATISTICS
+WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND INDEX_NAME <> 'PRIMARY' ORDER BY INDEX_NAME, SEQ_IN_INDEX`,
+}
+
+func loadSchema(db *gorm.DB, schema, table string, queries schemaSQL) (*tableSchema, error) {
+	result := &tableSchema{}
+
+	rows, err := db.Raw(queries.columns, schema, table).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var column columnInfo
+		var dataType, nullable string
+		var length, precision, scale sql.NullInt64
+		if err := rows.Scan(&column.Name, &dataType, &length, &precision, &scale, &nullable); err != nil {
+			return nil, err
+		}
+		column.Type = normalizeType(dataType)
+		column.Length, column.Precision, column.Scale = length.Int64, precision.Int64, scale.Int64
+		column.Nullable = nullable == "Y"
+		result.Columns = append(result.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(result.Columns) == 0 {
+		return result, nil
+	}
+	result.Exists = true
+
+	if err := db.Raw(queries.primaryKey, schema, table).Scan(&result.PrimaryKey).Error; err != nil {
+		return nil, err
+	}
+
+	indexRows, err := db.Raw(queries.indexes, schema, table).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer indexRows.Close()
+	byName := make(map[string]*indexInfo)
+	var names []string
+	for indexRows.Next() {
+		var name, column, unique string
+		if err := indexRows.Scan(&name, &column, &unique); err != nil {
+			return nil, err
+		}
+		index, ok := byName[name]
+		if !ok {
+			index = &indexInfo{Name: name, Unique: unique == "Y"}
+			byName[name] = index
+			names = append(names, name)
+		}
+		index.Columns = append(index.Columns, column)
+	}
+	if err := indexRows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		index := byName[name]
+		// 达梦的主键约束也会建一个唯一索引, 不单独比较
+		if index.Unique && sameColumns(index.Columns, result.PrimaryKey) {
+			continue
+		}
+		result.Indexes = append(result.Indexes, *index)
+	}
+	return result, nil
+}
+
+// 同步前检查表结构, 有差异时输出报告, 配置了apply_ddl时执行DDL
+func (s *DBSynchronizer) checkSchema(config TableConfig) error {
+	diff := s.CompareSchema(config)
+	if diff.Equal() {
+		return nil
+	}
+	printSchemaDiffs([]SchemaDiff{diff})
+	if len(diff.Errors) > 0 {
+		return diff.Errors[0]
+	}
+	if s.config.ApplyDDL {
+		return s.ApplyDDL(&diff)
+	}
+	if diff.Blocking() {
+		return fmt.Errorf("表 %s 结构不一致, 跳过同步", config.TableName)
+	}
+	return nil
+}
+
+// 输出表结构对比结果和DDL
+func printSchemaDiffs(diffs []SchemaDiff) {
+	printList := func(name string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Printf("  %s:\n", name)
+		for _, item := range items {
+			fmt.Printf("    %s\n", item)
+		}
+	}
+
+	for _, diff := range diffs {
+		if diff.Equal() {
+			fmt.Printf("表 %s 结构一致\n\n", diff.TableName)
+			continue
+		}
+		fmt.Printf("表 %s 结构对比结果:\n", diff.TableName)
+		if diff.TargetMissing {
+			fmt.Printf("  目标表不存在\n")
+		}
+		printList("目标端缺少的列", diff.MissingColumns)
+		printList("目标端多出的列", diff.ExtraColumns)
+		printList("不一致的列", diff.ChangedColumns)
+		if diff.PrimaryKey != "" {
+			fmt.Printf("  主键不一致: %s\n", diff.PrimaryKey)
+		}
+		printList("目标端缺少的索引", diff.MissingIndexes)
+		printList("目标端多出的索引", diff.ExtraIndexes)
+		if len(diff.DDL) > 0 {
+			fmt.Printf("  DDL:\n")
+			for _, ddl := range diff.DDL {
+				fmt.Printf("%s;\n", ddl)
+			}
+		}
+		if len(diff.Errors) > 0 {
+			fmt.Printf("  错误: %v\n", diff.Errors)
+		}
+		fmt.Println()
+	}
+}
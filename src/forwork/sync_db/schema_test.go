@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareSchemas(t *testing.T) {
+	source := &tableSchema{
+		Exists: true,
+		Columns: []columnInfo{
+			{Name: "ID", Type: normalizeType("NUMBER"), Precision: 20, Nullable: false},
+			{Name: "NAME", Type: normalizeType("VARCHAR2"), Length: 100, Nullable: true},
+			{Name: "MEMO", Type: normalizeType("TEXT"), Nullable: true},
+		},
+		PrimaryKey: []string{"ID"},
+		Indexes:    []indexInfo{{Name: "IDX_NAME", Columns: []string{"NAME"}}},
+	}
+	target := &tableSchema{
+		Exists: true,
+		Columns: []columnInfo{
+			{Name: "ID", Type: normalizeType("DECIMAL"), Precision: 20, Nullable: false},
+			{Name: "NAME", Type: normalizeType("varchar"), Length: 50, Nullable: true},
+			{Name: "OLD", Type: "INT", Nullable: true},
+		},
+		PrimaryKey: []string{"ID"},
+	}
+
+	var diff SchemaDiff
+	compareSchemas(&diff, source, target, "WEB.T", "dm")
+	if !reflect.DeepEqual(diff.MissingColumns, []string{"MEMO CLOB"}) ||
+		!reflect.DeepEqual(diff.ExtraColumns, []string{"OLD"}) ||
+		!reflect.DeepEqual(diff.MissingIndexes, []string{"IDX_NAME (NAME)"}) {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+	want := []string{
+		"ALTER TABLE WEB.T MODIFY NAME VARCHAR(100) NULL",
+		"ALTER TABLE WEB.T ADD MEMO CLOB",
+		"CREATE INDEX IDX_NAME ON WEB.T (NAME)",
+	}
+	if !reflect.DeepEqual(diff.DDL, want) {
+		t.Fatalf("ddl = %q", diff.DDL)
+	}
+	if !diff.Blocking() {
+		t.Fatal("missing column should block sync")
+	}
+}
+
+func TestCreateTableDDL(t *testing.T) {
+	var diff SchemaDiff
+	source := &tableSchema{
+		Exists: true,
+		Columns: []columnInfo{
+			{Name: "ID", Type: "BIGINT"},
+			{Name: "TIME", Type: normalizeType("DATETIME"), Nullable: true},
+		},
+		PrimaryKey: []string{"ID"},
+	}
+	compareSchemas(&diff, source, &tableSchema{}, "T", "mysql")
+	want := "CREATE TABLE T (\n  ID BIGINT NOT NULL,\n  TIME DATETIME NULL,\n  PRIMARY KEY (ID)\n)"
+	if !diff.TargetMissing || len(diff.DDL) != 1 || diff.DDL[0] != want {
+		t.Fatalf("ddl = %q", diff.DDL)
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 按主键有序读取的记录流
+type recordStream interface {
+	Next() (map[string]interface{}, error) // 读完返回 nil, nil
+}
+
+// 基于主键的分页读取(keyset), 每批按主键排序, 下一批从上一批最后一个主键之后开始
+type keysetStream struct {
+	db         *gorm.DB
+	tableName  string
+	config     TableConfig
+	batchSize  int
+	conditions func(query *gorm.DB) *gorm.DB // 额外的查询条件
+
+	buffer   []map[string]interface{}
+	lastKey  []interface{}
+	done     bool
+	read     int
+	progress *tableProgress
+}
+
+// tableName为带schema的表名, config提供主键和查询条件
+func (s *DBSynchronizer) newKeysetStream(db *gorm.DB, config TableConfig, tableName string) *keysetStream {
+	return &keysetStream{
+		db:        db,
+		tableName: tableName,
+		config:    config,
+		batchSize: s.batchSize(config),
+		progress:  s.progress.table(config.TableName),
+		conditions: func(query *gorm.DB) *gorm.DB {
+			return s.applyFilters(query, config)
+		},
+	}
+}
+
+func (r *keysetStream) Next() (map[string]interface{}, error) {
+	if len(r.buffer) == 0 && !r.done {
+		if err := r.fetch(); err != nil {
+			return nil, err
+		}
+	}
+	if len(r.buffer) == 0 {
+		return nil, nil
+	}
+
+	record := r.buffer[0]
+	r.buffer = r.buffer[1:]
+	return record, nil
+}
+
+// 读取下一批
+func (r *keysetStream) fetch() error {
+	var batchResults []map[string]interface{}
+
+	// 构建查询 - 使用原始SQL避免自动引号
+	query := r.conditions(r.db.Table(r.tableName))
+	if r.lastKey != nil {
+		condition, values := keysetCondition(r.config.PrimaryKey, r.lastKey)
+		query = query.Where(condition, values...)
+	}
+	err := query.Order(strings.Join(r.config.PrimaryKey, ", ")).
+		Limit(r.batchSize).
+		Find(&batchResults).Error
+	if err != nil {
+		return err
+	}
+
+	if len(batchResults) < r.batchSize {
+		r.done = true
+	}
+	if len(batchResults) == 0 {
+		return nil
+	}
+
+	// 数据库排序和程序比较规则不一致时合并比较会出错, 这里提前检查
+	for _, record := range batchResults {
+		key := primaryKeyValues(record, r.config.PrimaryKey)
+		if r.lastKey != nil && compareKeys(key, r.lastKey) <= 0 {
+			return fmt.Errorf("表 %s 主键排序不一致 (%v 在 %v 之后), 请检查主键字段的排序规则", r.tableName, key, r.lastKey)
+		}
+		r.lastKey = key
+	}
+
+	r.read += len(batchResults)
+	r.progress.read.Add(int64(len(batchResults)))
+	r.buffer = batchResults
+	return nil
+}
+
+// 生成 (a > ?) OR (a = ? AND b > ?) ... 形式的条件, 兼容不支持行值比较的数据库
+func keysetCondition(primaryKeys []string, lastKey []interface{}) (string, []interface{}) {
+	return keyBoundCondition(primaryKeys, lastKey, ">", false)
+}
+
+// 主键与key比较的条件, op为 > 或 <, inclusive时包含等于
+func keyBoundCondition(primaryKeys []string, key []interface{}, op string, inclusive bool) (string, []interface{}) {
+	clauses := make([]string, 0, len(primaryKeys)+1)
+	values := make([]interface{}, 0)
+	for i := range primaryKeys {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, primaryKeys[j]+" = ?")
+			values = append(values, key[j])
+		}
+		parts = append(parts, primaryKeys[i]+" "+op+" ?")
+		values = append(values, key[i])
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+	if inclusive {
+		parts := make([]string, 0, len(primaryKeys))
+		for i, pk := range primaryKeys {
+			parts = append(parts, pk+" = ?")
+			values = append(values, key[i])
+		}
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", values
+}
+
+// 按主键批量查询的条件, 单主键用 IN, 复合主键用 (a = ? AND b = ?) OR ...
+func keysCondition(primaryKeys []string, records []map[string]interface{}) (string, []interface{}) {
+	if len(primaryKeys) == 1 {
+		values := make([]interface{}, 0, len(records))
+		for _, record := range records {
+			values = append(values, record[primaryKeys[0]])
+		}
+		return primaryKeys[0] + " IN ?", []interface{}{values}
+	}
+
+	clauses := make([]string, 0, len(records))
+	values := make([]interface{}, 0, len(records)*len(primaryKeys))
+	for _, record := range records {
+		parts := make([]string, 0, len(primaryKeys))
+		for _, pk := range primaryKeys {
+			parts = append(parts, pk+" = ?")
+			values = append(values, record[pk])
+		}
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", values
+}
+
+func primaryKeyValues(record map[string]interface{}, primaryKeys []string) []interface{} {
+	values := make([]interface{}, len(primaryKeys))
+	for i, pk := range primaryKeys {
+		values[i] = record[pk]
+	}
+	return values
+}
+
+// 比较两条记录的主键, 返回 -1 0 1
+func compareKeys(a, b []interface{}) int {
+	for i := range a {
+		if i >= len(b) {
+			return 1
+		}
+		if c := compareValue(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	if len(a) < len(b) {
+		return -1
+	}
+	return 0
+}
+
+// 比较两个数据库值, nil最小; 有一边是数字时按数字比较, 否则按字符串比较
+func compareValue(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Compare(tb)
+		}
+	}
+
+	_, aNumber := a.(string)
+	_, bNumber := b.(string)
+	if !aNumber || !bNumber {
+		na, okA := toBigFloat(a)
+		nb, okB := toBigFloat(b)
+		if okA && okB {
+			return na.Cmp(nb)
+		}
+	}
+
+	return bytes.Compare(valueBytes(a), valueBytes(b))
+}
+
+func toBigFloat(value interface{}) (*big.Float, bool) {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		f, _, err := big.ParseFloat(fmt.Sprint(v), 10, 128, big.ToNearestEven)
+		return f, err == nil
+	case string:
+		f, _, err := big.ParseFloat(strings.TrimSpace(v), 10, 128, big.ToNearestEven)
+		return f, err == nil
+	case []byte:
+		f, _, err := big.ParseFloat(strings.TrimSpace(string(v)), 10, 128, big.ToNearestEven)
+		return f, err == nil
+	case fmt.Stringer: // decimal类型
+		f, _, err := big.ParseFloat(v.String(), 10, 128, big.ToNearestEven)
+		return f, err == nil
+	}
+	return nil, false
+}
+
+// 主键各列是否按数值比较, 与compareValue一致: 任一记录的值不是字符串且是数字时按数值
+func numericKeyColumns(primaryKeys []string, records ...[]map[string]interface{}) []bool {
+	numeric := make([]bool, len(primaryKeys))
+	for _, list := range records {
+		for _, record := range list {
+			for i, pk := range primaryKeys {
+				if numeric[i] || record == nil {
+					continue
+				}
+				if _, ok := record[pk].(string); ok {
+					continue
+				}
+				_, numeric[i] = toBigFloat(record[pk])
+			}
+		}
+	}
+	return numeric
+}
+
+// 按主键匹配记录时使用的key, 数值列格式化为统一的数值文本,
+// 两端类型不同(如int64和DECIMAL字符串)时也能匹配
+func normalizedKey(record map[string]interface{}, primaryKeys []string, numeric []bool) string {
+	keyParts := make([]string, 0, len(primaryKeys))
+	for i, pk := range primaryKeys {
+		value, exists := record[pk]
+		if !exists {
+			continue
+		}
+		switch v := value.(type) {
+		case time.Time:
+			keyParts = append(keyParts, v.Format(time.RFC3339Nano))
+			continue
+		case []byte:
+			value = string(v)
+		}
+		if numeric[i] {
+			if f, ok := toBigFloat(value); ok {
+				keyParts = append(keyParts, f.Text('g', -1))
+				continue
+			}
+		}
+		keyParts = append(keyParts, fmt.Sprintf("%v", value))
+	}
+	return strings.Join(keyParts, "::")
+}
+
+func valueBytes(value interface{}) []byte {
+	switch v := value.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	}
+	return []byte(fmt.Sprint(value))
+}
+
+// 合并比较两个按主键有序的流, 内存中只保留当前两条记录
+func mergeStreams(source, target recordStream, primaryKeys []string,
+	onAdd func(source map[string]interface{}) error,
+	onBoth func(source, target map[string]interface{}) error,
+	onDelete func(target map[string]interface{}) error) error {
+
+	sourceRecord, err := source.Next()
+	if err != nil {
+		return fmt.Errorf("读取源数据库失败: %w", err)
+	}
+	targetRecord, err := target.Next()
+	if err != nil {
+		return fmt.Errorf("读取目标数据库失败: %w", err)
+	}
+
+	for sourceRecord != nil || targetRecord != nil {
+		c := 0
+		switch {
+		case sourceRecord == nil:
+			c = 1
+		case targetRecord == nil:
+			c = -1
+		default:
+			c = compareKeys(primaryKeyValues(sourceRecord, primaryKeys), primaryKeyValues(targetRecord, primaryKeys))
+		}
+
+		switch {
+		case c < 0: // 只在源端
+			if err := onAdd(sourceRecord); err != nil {
+				return err
+			}
+		case c > 0: // 只在目标端
+			if err := onDelete(targetRecord); err != nil {
+				return err
+			}
+		default:
+			if err := onBoth(sourceRecord, targetRecord); err != nil {
+				return err
+			}
+		}
+
+		if c <= 0 {
+			if sourceRecord, err = source.Next(); err != nil {
+				return fmt.Errorf("读取源数据库失败: %w", err)
+			}
+		}
+		if c >= 0 {
+			if targetRecord, err = target.Next(); err != nil {
+				return fmt.Errorf("读取目标数据库失败: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
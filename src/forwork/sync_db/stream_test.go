@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+type sliceStream []map[string]interface{}
+
+func (s *sliceStream) Next() (map[string]interface{}, error) {
+	if len(*s) == 0 {
+		return nil, nil
+	}
+	record := (*s)[0]
+	*s = (*s)[1:]
+	return record, nil
+}
+
+func TestKeysetCondition(t *testing.T) {
+	condition, values := keysetCondition([]string{"A", "B"}, []interface{}{1, "x"})
+	if condition != "((A > ?) OR (A = ? AND B > ?))" {
+		t.Fatalf("unexpected condition %s", condition)
+	}
+	if !reflect.DeepEqual(values, []interface{}{1, 1, "x"}) {
+		t.Fatalf("unexpected values %v", values)
+	}
+}
+
+func TestCompareValue(t *testing.T) {
+	cases := []struct {
+		a, b interface{}
+		want int
+	}{
+		{int64(2), int32(10), -1},
+		{int64(10), "10", 0},
+		{"10", "9", -1}, // 两边都是字符串时按字符串比较
+		{[]byte("abc"), "abc", 0},
+		{nil, int64(1), -1},
+	}
+	for _, c := range cases {
+		if got := compareValue(c.a, c.b); got != c.want {
+			t.Errorf("compareValue(%v, %v) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestMergeStreams(t *testing.T) {
+	source := &sliceStream{{"ID": 1, "V": "a"}, {"ID": 2, "V": "b"}, {"ID": 4, "V": "d"}}
+	target := &sliceStream{{"ID": 2, "V": "x"}, {"ID": 3, "V": "c"}, {"ID": 4, "V": "d"}, {"ID": 5}}
+
+	var added, both, deleted []interface{}
+	err := mergeStreams(source, target, []string{"ID"},
+		func(s map[string]interface{}) error { added = append(added, s["ID"]); return nil },
+		func(s, t map[string]interface{}) error { both = append(both, s["ID"]); return nil },
+		func(t map[string]interface{}) error { deleted = append(deleted, t["ID"]); return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(added, []interface{}{1}) ||
+		!reflect.DeepEqual(both, []interface{}{2, 4}) ||
+		!reflect.DeepEqual(deleted, []interface{}{3, 5}) {
+		t.Fatalf("added %v both %v deleted %v", added, both, deleted)
+	}
+}
+
+func TestNormalizedKey(t *testing.T) {
+	pk := []string{"ID", "CODE"}
+	source := []map[string]interface{}{{"ID": int64(10), "CODE": "a"}}
+	target := []map[string]interface{}{{"ID": []byte("10.00"), "CODE": "a"}, {"ID": "10", "CODE": []byte("a")}}
+	numeric := numericKeyColumns(pk, source, target)
+	if !numeric[0] || numeric[1] {
+		t.Fatalf("unexpected numeric columns %v", numeric)
+	}
+	want := normalizedKey(source[0], pk, numeric)
+	for _, record := range target {
+		if got := normalizedKey(record, pk, numeric); got != want {
+			t.Errorf("%v: got %s, want %s", record, got, want)
+		}
+	}
+	// 两端都是字符串时按原文本
+	strs := []map[string]interface{}{{"ID": "01", "CODE": "a"}, {"ID": "1", "CODE": "a"}}
+	numeric = numericKeyColumns(pk, strs)
+	if normalizedKey(strs[0], pk, numeric) == normalizedKey(strs[1], pk, numeric) {
+		t.Error("string keys should not be converted")
+	}
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
-	"reflect"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	dameng "github.com/godoes/gorm-dameng"
 	"gopkg.in/yaml.v3"
@@ -29,10 +33,27 @@ type ConfigFile struct {
 		} `yaml:"target"`
 	} `yaml:"database"`
 	Sync struct {
-		EnableSoftDelete bool `yaml:"enable_soft_delete"`
-		HardDelete       bool `yaml:"hard_delete"`
-		BatchSize        int  `yaml:"batch_size"`
+		EnableSoftDelete bool          `yaml:"enable_soft_delete"`
+		HardDelete       bool          `yaml:"hard_delete"`
+		BatchSize        int           `yaml:"batch_size"`
+		Incremental      bool          `yaml:"incremental"`        // 配置了incremental_field的表只同步变化的记录
+		StateFile        string        `yaml:"state_file"`         // 增量同步水位文件
+		FullSyncInterval string        `yaml:"full_sync_interval"` // 距上次全量同步超过该时间时做一次全量同步, 如 24h
+		DiffChunkSize    int           `yaml:"diff_chunk_size"`    // diff模式初始划分的每个主键范围行数, 仅两端同为MySQL或同为达梦且无列转换时分段, 否则逐条对比整张表
+		DiffLeafSize     int           `yaml:"diff_leaf_size"`     // diff模式范围行数不超过该值时逐条比较
+		Retry            int           `yaml:"retry"`              // 写入遇到连接断开、死锁等临时错误时的重试次数
+		Parallelism      int           `yaml:"parallelism"`        // 同时同步的表数量
+		CheckSchema      bool          `yaml:"check_schema"`       // 同步前对比表结构, 目标表或列缺失时跳过该表
+		ApplyDDL         bool          `yaml:"apply_ddl"`          // 对比表结构后执行生成的DDL
+		RetryInterval    string        `yaml:"retry_interval"`     // 重试间隔, 默认1s
+		ReportFile       string        `yaml:"report_file"`        // 运行报告文件, 每行一次运行的JSON
+		ReportTable      string        `yaml:"report_table"`       // 同时写入目标库的报告表, 不存在时自动创建
+		ExportDir        string        `yaml:"export_dir"`         // 导出写入成功的变更记录, 每次运行一个子目录
+		ExportFormat     string        `yaml:"export_format"`      // csv 或 sql, 默认csv
+		SnapshotDir      string        `yaml:"snapshot_dir"`       // 双向同步的行快照目录, 默认.sync_db_snapshot
+		Compare          CompareConfig `yaml:"compare"`            // 比较记录时的容差
 	} `yaml:"sync"`
+	Daemon DaemonConfig  `yaml:"daemon"`
 	Tables []TableConfig `yaml:"tables"`
 }
 
@@ -49,6 +70,27 @@ func LoadConfigFromFile(filename string) (*SyncConfig, error) {
 		return nil, fmt.Errorf("解析配置文件失败: %w", err)
 	}
 
+	fullSyncInterval := time.Duration(0)
+	if fileConfig.Sync.FullSyncInterval != "" {
+		fullSyncInterval, err = time.ParseDuration(fileConfig.Sync.FullSyncInterval)
+		if err != nil {
+			return nil, fmt.Errorf("full_sync_interval 格式错误: %w", err)
+		}
+	}
+
+	retryInterval := time.Second
+	if fileConfig.Sync.RetryInterval != "" {
+		retryInterval, err = time.ParseDuration(fileConfig.Sync.RetryInterval)
+		if err != nil {
+			return nil, fmt.Errorf("retry_interval 格式错误: %w", err)
+		}
+	}
+
+	compare, err := newCompareOptions(fileConfig.Sync.Compare)
+	if err != nil {
+		return nil, err
+	}
+
 	// 初始化数据库连接
 	sourceDB, err := initDB(fileConfig.Database.Source)
 	if err != nil {
@@ -68,12 +110,71 @@ func LoadConfigFromFile(filename string) (*SyncConfig, error) {
 		EnableSoftDelete: fileConfig.Sync.EnableSoftDelete,
 		HardDelete:       fileConfig.Sync.HardDelete,
 		BatchSize:        fileConfig.Sync.BatchSize,
+		Incremental:      fileConfig.Sync.Incremental,
+		StateFile:        fileConfig.Sync.StateFile,
+		FullSyncInterval: fullSyncInterval,
+		DiffChunkSize:    fileConfig.Sync.DiffChunkSize,
+		DiffLeafSize:     fileConfig.Sync.DiffLeafSize,
+		Retry:            fileConfig.Sync.Retry,
+		Parallelism:      fileConfig.Sync.Parallelism,
+		CheckSchema:      fileConfig.Sync.CheckSchema,
+		ApplyDDL:         fileConfig.Sync.ApplyDDL,
+		RetryInterval:    retryInterval,
+		ReportFile:       fileConfig.Sync.ReportFile,
+		ReportTable:      fileConfig.Sync.ReportTable,
+		ExportDir:        fileConfig.Sync.ExportDir,
+		ExportFormat:     fileConfig.Sync.ExportFormat,
+		Compare:          compare,
+		SnapshotDir:      fileConfig.Sync.SnapshotDir,
+		Daemon:           fileConfig.Daemon,
 	}
 
 	// 设置默认值
 	if config.BatchSize <= 0 {
 		config.BatchSize = 1000
 	}
+	if config.Parallelism <= 0 {
+		config.Parallelism = 1
+	}
+	if _, err := tableDependencies(config.Tables); err != nil {
+		return nil, err
+	}
+	for _, table := range config.Tables {
+		if _, err := newColumnMapping(table); err != nil {
+			return nil, err
+		}
+		if table.TwoWay {
+			deletes := config.HardDelete || (config.EnableSoftDelete && table.SoftDeleteField != "")
+			if err := validateTwoWay(table, deletes); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if config.StateFile == "" {
+		config.StateFile = ".sync_db_state.json"
+	}
+	if config.DiffChunkSize <= 0 {
+		config.DiffChunkSize = 100000
+	}
+	if config.DiffLeafSize <= 0 {
+		config.DiffLeafSize = 1000
+	}
+	if err := validateDaemonConfig(&config.Daemon, config.Tables); err != nil {
+		return nil, err
+	}
+	if config.SnapshotDir == "" {
+		config.SnapshotDir = ".sync_db_snapshot"
+	}
+	if config.ReportFile == "" {
+		config.ReportFile = "sync_db_report.jsonl"
+	}
+	switch config.ExportFormat {
+	case "":
+		config.ExportFormat = "csv"
+	case "csv", "sql":
+	default:
+		return nil, fmt.Errorf("不支持的export_format: %s", config.ExportFormat)
+	}
 
 	return config, nil
 }
@@ -147,33 +248,88 @@ type SyncConfig struct {
 	EnableSoftDelete bool // 是否启用软删除
 	HardDelete       bool // 是否硬删除（如果启用软删除，则此配置无效）
 	BatchSize        int  // 批量操作大小
+	Incremental      bool
+	StateFile        string
+	FullSyncInterval time.Duration // 0表示只在没有水位时全量同步
+	DiffChunkSize    int
+	DiffLeafSize     int
+	Retry            int
+	RetryInterval    time.Duration
+	Parallelism      int
+	CheckSchema      bool
+	ApplyDDL         bool
+	ReportFile       string
+	ReportTable      string
+	ExportDir        string
+	ExportFormat     string
+	Compare          compareOptions
+	SnapshotDir      string
+	Daemon           DaemonConfig
 }
 
 // 表配置
 type TableConfig struct {
-	TableName       string                 `yaml:"table_name"`        // 表名
-	PrimaryKey      []string               `yaml:"primary_key"`       // 主键字段（支持复合主键）
-	Schema1         string                 `yaml:"schema1"`           // 数据库1的schema
-	Schema2         string                 `yaml:"schema2"`           // 数据库2的schema
-	SoftDeleteField string                 `yaml:"soft_delete_field"` // 软删除字段，如 "deleted_at"
-	SoftDeleteValue string                 `yaml:"soft_delete_value"` // 软删除值，如 "now()"
-	BatchSize       int                    `yaml:"batch_size"`        // 批量操作大小
-	WhereCondition  map[string]interface{} `yaml:"where_condition"`   // 查询条件
+	TableName         string                 `yaml:"table_name"`          // 表名
+	PrimaryKey        []string               `yaml:"primary_key"`         // 主键字段（支持复合主键）
+	Schema1           string                 `yaml:"schema1"`             // 数据库1的schema
+	Schema2           string                 `yaml:"schema2"`             // 数据库2的schema
+	SoftDeleteField   string                 `yaml:"soft_delete_field"`   // 软删除字段，如 "deleted_at"
+	SoftDeleteValue   string                 `yaml:"soft_delete_value"`   // 软删除值，如 "now()"
+	BatchSize         int                    `yaml:"batch_size"`          // 批量操作大小
+	WhereCondition    map[string]interface{} `yaml:"where_condition"`     // 查询条件
+	Where             string                 `yaml:"where"`               // 源端SQL条件，如 "OWNER IN ? AND STATUS <> ?"
+	WhereArgs         []interface{}          `yaml:"where_args"`          // where的参数
+	TargetWhere       string                 `yaml:"target_where"`        // 目标端SQL条件，不配置时与where相同
+	TargetWhereArgs   []interface{}          `yaml:"target_where_args"`   // target_where的参数
+	TargetTable       string                 `yaml:"target_table"`        // 目标表名，不配置时与table_name相同
+	Columns           []string               `yaml:"columns"`             // 只同步这些列（源端列名）
+	ExcludeColumns    []string               `yaml:"exclude_columns"`     // 不同步的列（源端列名）
+	ColumnMap         map[string]string      `yaml:"column_map"`          // 源端列名 -> 目标端列名
+	Defaults          map[string]interface{} `yaml:"defaults"`            // 值为空时使用的默认值（目标端列名）
+	Constants         map[string]interface{} `yaml:"constants"`           // 固定写入的值（目标端列名）
+	Transforms        []ColumnTransform      `yaml:"transforms"`          // 值转换
+	IncrementalField  string                 `yaml:"incremental_field"`   // 更新时间字段，如 "UPDATE_TIME"
+	DependsOn         []string               `yaml:"depends_on"`          // 依赖的表（父表），新增时父表先处理，删除时子表先处理
+	ProcessOrder      int                    `yaml:"process_order"`       // 处理顺序，较小的先处理，相当于依赖所有顺序更小的表
+	IgnoreColumns     []string               `yaml:"ignore_columns"`      // 比较时忽略的列（目标端列名），如 "STAMP"，其他列变化时仍会写入
+	IgnoreCaseColumns []string               `yaml:"ignore_case_columns"` // 比较时忽略大小写的列（目标端列名）
+	TwoWay            bool                   `yaml:"two_way"`             // 双向同步，两端的修改都同步到另一端，不支持列转换
+	ConflictPolicy    string                 `yaml:"conflict_policy"`     // 两端都修改时: source（默认）、target、newest、manual
+	TimestampField    string                 `yaml:"timestamp_field"`     // newest比较的时间字段，不配置时使用incremental_field
 }
 
 // 同步结果
 type SyncResult struct {
 	TableName   string
+	Mode        string // full 或 incremental
 	Added       int
 	Updated     int
 	Deleted     int
 	SoftDeleted int
+	FailedCount int      // 写入失败已回滚的记录数
+	FailedKeys  []string // 写入失败的主键
+	ChangedKeys []string // 写入成功的主键样例, 带操作名前缀
 	Errors      []error
+
+	// 双向同步写回源端的记录数和未处理的冲突
+	SourceAdded   int
+	SourceUpdated int
+	SourceDeleted int
+	ConflictCount int
+	Conflicts     []Conflict
+
+	Start time.Time
+	End   time.Time
 }
 
 // 主程序
 type DBSynchronizer struct {
-	config *SyncConfig
+	config    *SyncConfig
+	progress  *progressBoard
+	stateOnce sync.Once
+	state     *syncState
+	stateErr  error
+	exporter  *changeExporter // 本次运行的变更导出, 未配置export_dir时为nil
 }
 
 func NewDBSynchronizer(config *SyncConfig) *DBSynchronizer {
@@ -182,276 +338,278 @@ func NewDBSynchronizer(config *SyncConfig) *DBSynchronizer {
 		config.BatchSize = 1000 // 默认1000条
 	}
 	return &DBSynchronizer{
-		config: config,
+		config:   config,
+		progress: newProgressBoard(),
 	}
 }
 
-// 同步所有配置的表
-func (s *DBSynchronizer) SyncAll() []SyncResult {
-	results := make([]SyncResult, 0, len(s.config.Tables))
+// 一张表的一次同步, 新增更新和删除分两个阶段执行
+type tableRun struct {
+	config        TableConfig
+	result        SyncResult
+	deletes       []map[string]interface{} // 待删除记录的主键, 在删除阶段执行
+	sourceDeletes []map[string]interface{} // 双向同步时源端待删除记录的主键
+	mapping       *columnMapping
+	comparer      *recordComparer
+	progress      *tableProgress
+	highWater     *pendingState // 增量同步的新水位, 删除阶段成功后保存
+}
 
-	for _, tableConfig := range s.config.Tables {
-		result := s.SyncTable(tableConfig)
-		results = append(results, result)
+func (s *DBSynchronizer) newTableRun(config TableConfig) *tableRun {
+	progress := s.progress.table(config.TableName)
+	progress.reset()
+	return &tableRun{
+		config:   config,
+		result:   SyncResult{TableName: config.TableName, Mode: "full"},
+		progress: progress,
 	}
+}
 
+// 同步所有配置的表
+//
+// 没有依赖关系的表在线程池中并发同步; 新增和更新按依赖顺序父表先执行,
+// 全部完成后再按相反顺序执行删除, 子表先删
+func (s *DBSynchronizer) SyncAll() []SyncResult {
+	results, _ := s.syncTables(s.config.Tables)
 	return results
 }
 
-// 同步单个表
-func (s *DBSynchronizer) SyncTable(config TableConfig) SyncResult {
-	result := SyncResult{
-		TableName: config.TableName,
+// 同步指定的表并保存运行报告, 不在tables中的依赖表忽略
+func (s *DBSynchronizer) syncTables(tables []TableConfig) ([]SyncResult, *RunReport) {
+	start := time.Now()
+	runID := newRunID(start)
+	if s.config.ExportDir != "" {
+		exporter, err := newChangeExporter(filepath.Join(s.config.ExportDir, runID), s.config.ExportFormat)
+		if err != nil {
+			fmt.Printf("创建导出目录失败, 本次不导出变更记录: %v\n", err)
+		} else {
+			s.exporter = exporter
+			defer func() {
+				exporter.Close()
+				s.exporter = nil
+			}()
+		}
 	}
 
-	// 1. 从两个数据库读取数据
-	fmt.Printf("源端表读取开始 %s\n", config.TableName)
-	sourceData, err := s.fetchData(s.config.SourceDB, config, config.Schema1)
-	if err != nil {
-		result.Errors = append(result.Errors, fmt.Errorf("读取源数据库失败: %w", err))
-		return result
+	names := make(map[string]bool, len(tables))
+	for _, tableConfig := range tables {
+		names[tableConfig.TableName] = true
 	}
-
-	fmt.Printf("目的表读取开始 %s\n", config.TableName)
-	targetData, err := s.fetchData(s.config.TargetDB, config, config.Schema2)
-	if err != nil {
-		result.Errors = append(result.Errors, fmt.Errorf("读取目标数据库失败: %w", err))
-		return result
+	runs := make([]*tableRun, 0, len(tables))
+	for _, tableConfig := range tables {
+		var dependsOn []string
+		for _, name := range tableConfig.DependsOn {
+			if names[name] {
+				dependsOn = append(dependsOn, name)
+			}
+		}
+		tableConfig.DependsOn = dependsOn
+		runs = append(runs, s.newTableRun(tableConfig))
 	}
+	results := make([]SyncResult, 0, len(runs))
 
-	// 2. 比较数据并执行操作
-	fmt.Printf("开始比较数据 %s\n", config.TableName)
-	added, updated, err := s.syncData(sourceData, targetData, config)
-	if err != nil {
-		result.Errors = append(result.Errors, fmt.Errorf("同步数据失败: %w", err))
-		return result
+	configs := make([]TableConfig, 0, len(runs))
+	for _, run := range runs {
+		configs = append(configs, run.config)
 	}
-	result.Added = added
-	result.Updated = updated
-
-	// 3. 处理删除
-	if s.config.EnableSoftDelete && config.SoftDeleteField != "" {
-		softDeleted, err := s.handleSoftDelete(sourceData, targetData, config)
-		if err != nil {
-			result.Errors = append(result.Errors, fmt.Errorf("软删除失败: %w", err))
-		}
-		result.SoftDeleted = softDeleted
-	} else if s.config.HardDelete {
-		deleted, err := s.handleHardDelete(sourceData, targetData, config)
-		if err != nil {
-			result.Errors = append(result.Errors, fmt.Errorf("硬删除失败: %w", err))
+	deps, err := tableDependencies(configs)
+	if err != nil {
+		for _, run := range runs {
+			run.result.Errors = append(run.result.Errors, err)
+			results = append(results, run.result)
 		}
-		result.Deleted = deleted
+		return results, nil
 	}
 
-	return result
-}
-
-// 获取数据并转换为Map（分批次查询）
-func (s *DBSynchronizer) fetchData(db *gorm.DB, config TableConfig, schema string) (map[string]map[string]interface{}, error) {
-	dataMap := make(map[string]map[string]interface{})
+	stop := s.progress.start(5 * time.Second)
+	runOrdered(deps, s.config.Parallelism, false, func(i int) {
+		s.syncRows(runs[i])
+	})
+	runOrdered(deps, s.config.Parallelism, true, func(i int) {
+		s.syncDeletes(runs[i])
+	})
+	stop()
 
-	tableName := config.TableName
-	if schema != "" {
-		tableName = schema + "." + config.TableName
-	}
-
-	// 优先使用表级别的batch_size，如果没有则使用全局的
-	batchSize := config.BatchSize
-	if batchSize <= 0 {
-		batchSize = s.config.BatchSize
+	for _, run := range runs {
+		results = append(results, run.result)
 	}
-	if batchSize <= 0 {
-		batchSize = 1000 // 最终默认值
+	report := newRunReport(runID, start, time.Now(), results)
+	if err := s.saveReport(report); err != nil {
+		fmt.Printf("保存运行报告失败: %v\n", err)
 	}
+	return results, report
+}
 
-	offset := 0
-	for {
-		var batchResults []map[string]interface{}
-
-		// 构建查询 - 使用原始SQL避免自动引号
-		query := db.Table(tableName)
-
-		// 添加WHERE条件 - 使用自定义方法避免自动引号
-		if config.WhereCondition != nil && len(config.WhereCondition) > 0 {
-			query = s.applyWhereCondition(query, config.WhereCondition)
-		}
-
-		// 使用Limit和Offset进行分批次查询
-		err := query.Limit(batchSize).
-			Offset(offset).
-			Find(&batchResults).Error
-
-		if err != nil {
-			return nil, err
-		}
-
-		// 如果本批次没有数据，说明已经查询完毕
-		if len(batchResults) == 0 {
-			break
-		}
+// 同步单个表
+func (s *DBSynchronizer) SyncTable(config TableConfig) SyncResult {
+	run := s.newTableRun(config)
+	s.syncRows(run)
+	s.syncDeletes(run)
+	return run.result
+}
 
-		// 处理本批次数据
-		for _, record := range batchResults {
-			key := s.generatePrimaryKey(record, config.PrimaryKey)
-			dataMap[key] = record
-		}
+// 新增和更新阶段
+func (s *DBSynchronizer) syncRows(run *tableRun) {
+	config := run.config
+	run.result.Start = time.Now()
+	if len(config.PrimaryKey) == 0 {
+		run.result.Errors = append(run.result.Errors, fmt.Errorf("表 %s 未配置主键", config.TableName))
+		return
+	}
 
-		fmt.Printf("表 %s: 已读取 %d 条记录 (批次大小: %d)\n", tableName, offset+len(batchResults), batchSize)
+	run.progress.setStatus("同步中")
+	mapping, err := newColumnMapping(config)
+	if err != nil {
+		run.result.Errors = append(run.result.Errors, err)
+		return
+	}
+	run.mapping = mapping
+	run.comparer = s.newRecordComparer(config)
 
-		// 如果本批次数据量小于batchSize，说明已经是最后一批
-		if len(batchResults) < batchSize {
-			break
+	if s.config.CheckSchema {
+		if err := s.checkSchema(config); err != nil {
+			run.result.Errors = append(run.result.Errors, err)
+			return
 		}
-
-		// 更新offset，准备查询下一批
-		offset += batchSize
 	}
 
-	fmt.Printf("表 %s: 总共读取 %d 条记录\n", tableName, len(dataMap))
-	return dataMap, nil
-}
-
-// 批量插入（分批次处理）
-func (s *DBSynchronizer) batchInsert(tableName string, records []map[string]interface{}, config TableConfig) error {
-	// 优先使用表级别的batch_size，如果没有则使用全局的
-	batchSize := config.BatchSize
-	if batchSize <= 0 {
-		batchSize = s.config.BatchSize
+	if config.TwoWay {
+		err = s.syncTwoWay(run)
+	} else if s.config.Incremental && config.IncrementalField != "" {
+		err = s.syncWithHighWater(run)
+	} else {
+		err = s.syncFull(run)
 	}
-	if batchSize <= 0 {
-		batchSize = 1000 // 最终默认值
+	if err != nil {
+		run.result.Errors = append(run.result.Errors, err)
 	}
+}
 
-	for i := 0; i < len(records); i += batchSize {
-		end := i + batchSize
-		if end > len(records) {
-			end = len(records)
-		}
-
-		batch := records[i:end]
-		err := s.config.TargetDB.Table(tableName).Create(batch).Error
-		if err != nil {
-			return fmt.Errorf("插入批次 %d-%d 失败: %w", i, end, err)
-		}
+// 删除阶段
+func (s *DBSynchronizer) syncDeletes(run *tableRun) {
+	defer func() {
+		run.result.End = time.Now()
+		run.progress.setStatus("完成")
+	}()
+	defer s.saveHighWater(run)
+	if len(run.deletes) == 0 && len(run.sourceDeletes) == 0 {
+		return
 	}
 
-	return nil
-}
-
-// 批量更新（分批次处理）
-func (s *DBSynchronizer) batchUpdate(tableName string, records []map[string]interface{}, primaryKeys []string, config TableConfig) error {
-	// 优先使用表级别的batch_size，如果没有则使用全局的
-	batchSize := config.BatchSize
-	if batchSize <= 0 {
-		batchSize = s.config.BatchSize
+	run.progress.setStatus("删除中")
+	op := opHardDelete
+	if s.config.EnableSoftDelete && run.config.SoftDeleteField != "" {
+		op = opSoftDelete
 	}
-	if batchSize <= 0 {
-		batchSize = 1000 // 最终默认值
+	if len(run.deletes) > 0 {
+		s.apply(op, s.targetTableName(run.config), run.deletes, run)
 	}
+	if len(run.sourceDeletes) > 0 {
+		s.applyTo(true, op, s.sourceTableName(run.config), run.sourceDeletes, run)
+	}
+	run.deletes, run.sourceDeletes = nil, nil
+}
 
-	for i := 0; i < len(records); i += batchSize {
-		end := i + batchSize
-		if end > len(records) {
-			end = len(records)
+// 全量同步
+//
+// 两端都按主键排序分批读取, 合并比较后按批写入, 内存中只保留一个批次的待写入记录;
+// 需要删除的记录只保留主键, 在删除阶段处理
+func (s *DBSynchronizer) syncFull(run *tableRun) error {
+	config := run.config
+	targetConfig := config.targetSide()
+	tableName := s.targetTableName(config)
+	batchSize := s.batchSize(config)
+	softDelete := s.config.EnableSoftDelete && config.SoftDeleteField != ""
+	hardDelete := !softDelete && s.config.HardDelete
+
+	var inserts, updates []map[string]interface{}
+	flush := func(all bool) {
+		if len(inserts) > 0 && (all || len(inserts) >= batchSize) {
+			s.apply(opInsert, tableName, inserts, run)
+			inserts = inserts[:0]
 		}
+		if len(updates) > 0 && (all || len(updates) >= batchSize) {
+			s.apply(opUpdate, tableName, updates, run)
+			updates = updates[:0]
+		}
+	}
 
-		batch := records[i:end]
-		for _, record := range batch {
-			// 构建WHERE条件 - 使用原始SQL避免自动引号
-			whereClause := make([]string, 0)
-			whereValues := make([]interface{}, 0)
-			for _, pk := range primaryKeys {
-				if value, exists := record[pk]; exists {
-					whereClause = append(whereClause, pk+" = ?")
-					whereValues = append(whereValues, value)
-				}
-			}
-
-			if len(whereClause) == 0 {
-				continue
-			}
-
-			// 构建更新数据（排除主键）
-			updateData := make(map[string]interface{})
-			for k, v := range record {
-				isPrimaryKey := false
-				for _, pk := range primaryKeys {
-					if k == pk {
-						isPrimaryKey = true
-						break
-					}
-				}
-				if !isPrimaryKey {
-					updateData[k] = v
-				}
+	source := s.newKeysetStream(s.config.SourceDB, config, s.sourceTableName(config))
+	target := s.newKeysetStream(s.config.TargetDB, targetConfig, tableName)
+	err := mergeStreams(&mappedStream{source, run.mapping}, target, targetConfig.PrimaryKey,
+		func(sourceRecord map[string]interface{}) error {
+			// 新增记录
+			run.progress.compared.Add(1)
+			inserts = append(inserts, sourceRecord)
+			flush(false)
+			return nil
+		},
+		func(sourceRecord, targetRecord map[string]interface{}) error {
+			run.progress.compared.Add(1)
+			if run.comparer.equal(sourceRecord, targetRecord) {
+				return nil
 			}
-
-			// 使用原始SQL条件
-			err := s.config.TargetDB.Table(tableName).
-				Where(strings.Join(whereClause, " AND "), whereValues...).
-				Updates(updateData).Error
-			if err != nil {
-				return fmt.Errorf("更新记录失败 (主键: %v): %w", whereValues, err)
+			updates = append(updates, updateRecord(sourceRecord, targetRecord, targetConfig.PrimaryKey))
+			flush(false)
+			return nil
+		},
+		func(targetRecord map[string]interface{}) error {
+			// 源数据库不存在，目标数据库存在
+			run.progress.compared.Add(1)
+			if softDelete || hardDelete {
+				run.deletes = append(run.deletes, primaryKeyRecord(targetRecord, targetConfig.PrimaryKey))
 			}
-		}
+			return nil
+		})
+	if err == nil {
+		flush(true)
 	}
 
-	return nil
+	fmt.Printf("表 %s: 源端读取 %d 条, 目标端读取 %d 条\n", config.TableName, source.read, target.read)
+	return err
 }
 
-// 批量软删除（分批次处理）
-func (s *DBSynchronizer) batchSoftDelete(tableName string, records []map[string]interface{}, config TableConfig) error {
-	// 优先使用表级别的batch_size，如果没有则使用全局的
-	batchSize := config.BatchSize
-	if batchSize <= 0 {
-		batchSize = s.config.BatchSize
-	}
-	if batchSize <= 0 {
-		batchSize = 1000 // 最终默认值
+// 只包含主键的记录
+func primaryKeyRecord(record map[string]interface{}, primaryKeys []string) map[string]interface{} {
+	keyRecord := make(map[string]interface{}, len(primaryKeys))
+	for _, pk := range primaryKeys {
+		keyRecord[pk] = record[pk]
 	}
+	return keyRecord
+}
 
-	for i := 0; i < len(records); i += batchSize {
-		end := i + batchSize
-		if end > len(records) {
-			end = len(records)
+// 更新记录（确保包含目标端的主键）
+func updateRecord(sourceRecord, targetRecord map[string]interface{}, primaryKeys []string) map[string]interface{} {
+	record := make(map[string]interface{}, len(sourceRecord))
+	for k, v := range sourceRecord {
+		record[k] = v
+	}
+	for _, pk := range primaryKeys {
+		if pkValue, exists := targetRecord[pk]; exists {
+			record[pk] = pkValue
 		}
+	}
+	return record
+}
 
-		batch := records[i:end]
-		for _, record := range batch {
-			// 构建WHERE条件 - 使用原始SQL避免自动引号
-			whereClause := make([]string, 0)
-			whereValues := make([]interface{}, 0)
-			for _, pk := range config.PrimaryKey {
-				if value, exists := record[pk]; exists {
-					whereClause = append(whereClause, pk+" = ?")
-					whereValues = append(whereValues, value)
-				}
-			}
-
-			if len(whereClause) == 0 {
-				continue
-			}
-
-			updateData := map[string]interface{}{
-				config.SoftDeleteField: config.SoftDeleteValue,
-			}
-
-			err := s.config.TargetDB.Table(tableName).
-				Where(strings.Join(whereClause, " AND "), whereValues...).
-				Updates(updateData).Error
-			if err != nil {
-				return fmt.Errorf("软删除失败 (主键: %v): %w", whereValues, err)
-			}
-		}
+// 源表名, 带schema
+func (s *DBSynchronizer) sourceTableName(config TableConfig) string {
+	if config.Schema1 != "" {
+		return config.Schema1 + "." + config.TableName
 	}
+	return config.TableName
+}
 
-	return nil
+// 目标表名, 带schema
+func (s *DBSynchronizer) targetTableName(config TableConfig) string {
+	if config.Schema2 != "" {
+		return config.Schema2 + "." + config.targetTable()
+	}
+	return config.targetTable()
 }
 
-// 批量硬删除（分批次处理）
-func (s *DBSynchronizer) batchHardDelete(tableName string, records []map[string]interface{}, config TableConfig) error {
-	// 优先使用表级别的batch_size，如果没有则使用全局的
+// 批量大小, 优先使用表级别的batch_size，如果没有则使用全局的
+func (s *DBSynchronizer) batchSize(config TableConfig) int {
 	batchSize := config.BatchSize
 	if batchSize <= 0 {
 		batchSize = s.config.BatchSize
@@ -459,39 +617,7 @@ func (s *DBSynchronizer) batchHardDelete(tableName string, records []map[string]
 	if batchSize <= 0 {
 		batchSize = 1000 // 最终默认值
 	}
-
-	for i := 0; i < len(records); i += batchSize {
-		end := i + batchSize
-		if end > len(records) {
-			end = len(records)
-		}
-
-		batch := records[i:end]
-		for _, record := range batch {
-			// 构建WHERE条件 - 使用原始SQL避免自动引号
-			whereClause := make([]string, 0)
-			whereValues := make([]interface{}, 0)
-			for _, pk := range config.PrimaryKey {
-				if value, exists := record[pk]; exists {
-					whereClause = append(whereClause, pk+" = ?")
-					whereValues = append(whereValues, value)
-				}
-			}
-
-			if len(whereClause) == 0 {
-				continue
-			}
-
-			err := s.config.TargetDB.Table(tableName).
-				Where(strings.Join(whereClause, " AND "), whereValues...).
-				Delete(nil).Error
-			if err != nil {
-				return fmt.Errorf("硬删除失败 (主键: %v): %w", whereValues, err)
-			}
-		}
-	}
-
-	return nil
+	return batchSize
 }
 
 // 应用WHERE条件，避免自动引号
@@ -514,173 +640,129 @@ func (s *DBSynchronizer) generatePrimaryKey(record map[string]interface{}, prima
 	return strings.Join(keyParts, "::")
 }
 
-// 同步数据：新增和更新
-func (s *DBSynchronizer) syncData(sourceData, targetData map[string]map[string]interface{}, config TableConfig) (int, int, error) {
-	added := 0
-	updated := 0
-
-	tableName := config.TableName
-	if config.Schema2 != "" {
-		tableName = config.Schema2 + "." + config.TableName
+// 用法: sync_db [sync|diff|schema|report|daemon] [-apply] [config.yaml]
+//
+//	diff    对比数据, 不写入
+//	schema  对比表结构并输出DDL, -apply时在目标端执行
+//	report  输出最近的运行报告
+//	daemon  按daemon.groups的计划定时同步, 并提供HTTP接口
+func main() {
+	mode, configFile, apply := "sync", "config.yaml", false
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == "sync" || args[0] == "diff" || args[0] == "schema" || args[0] == "report" || args[0] == "daemon") {
+		mode, args = args[0], args[1:]
+	}
+	for _, arg := range args {
+		if arg == "-apply" {
+			apply = true
+		} else {
+			configFile = arg
+		}
 	}
 
-	// 批量操作
-	var batchInsert []map[string]interface{}
-	var batchUpdate []map[string]interface{}
-
-	for key, sourceRecord := range sourceData {
-		targetRecord, exists := targetData[key]
+	// 从配置文件读取配置
+	config, err := LoadConfigFromFile(configFile)
+	if err != nil {
+		log.Fatal("加载配置文件失败:", err)
+	}
+	synchronizer := NewDBSynchronizer(config)
 
-		if !exists {
-			// 新增记录
-			batchInsert = append(batchInsert, sourceRecord)
-			added++
-		} else if !s.recordsEqual(sourceRecord, targetRecord, config.PrimaryKey) {
-			// 更新记录（确保包含主键）
-			updateRecord := make(map[string]interface{})
-			for k, v := range sourceRecord {
-				updateRecord[k] = v
-			}
-			// 确保主键字段存在
-			for _, pk := range config.PrimaryKey {
-				if pkValue, exists := targetRecord[pk]; exists {
-					updateRecord[pk] = pkValue
+	switch mode {
+	case "diff":
+		printDiffs(synchronizer.DiffAll())
+		return
+	case "schema":
+		diffs := synchronizer.SchemaAll()
+		printSchemaDiffs(diffs)
+		if apply {
+			for i := range diffs {
+				if err := synchronizer.ApplyDDL(&diffs[i]); err != nil {
+					log.Fatalf("表 %s: %v", diffs[i].TableName, err)
 				}
 			}
-			batchUpdate = append(batchUpdate, updateRecord)
-			updated++
-		}
-	}
-
-	// 在 syncData 方法中更新调用：
-	// 执行批量插入（分批次）
-	if len(batchInsert) > 0 {
-		err := s.batchInsert(tableName, batchInsert, config)
-		if err != nil {
-			return added, updated, fmt.Errorf("批量插入失败: %w", err)
 		}
-	}
-
-	// 执行批量更新（分批次）
-	if len(batchUpdate) > 0 {
-		err := s.batchUpdate(tableName, batchUpdate, config.PrimaryKey, config)
-		if err != nil {
-			return added, updated, fmt.Errorf("批量更新失败: %w", err)
+		return
+	case "report":
+		printReports(config.ReportFile, 20)
+		return
+	case "daemon":
+		if err := newSyncDaemon(synchronizer, config.Daemon).Run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("守护进程退出:", err)
 		}
+		return
 	}
 
-	return added, updated, nil
-}
-
-// 处理软删除
-func (s *DBSynchronizer) handleSoftDelete(sourceData, targetData map[string]map[string]interface{}, config TableConfig) (int, error) {
-	softDeleted := 0
-	tableName := config.TableName
-	if config.Schema2 != "" {
-		tableName = config.Schema2 + "." + config.TableName
-	}
-
-	var recordsToDelete []map[string]interface{}
+	// 执行同步
+	results := synchronizer.SyncAll()
 
-	for key, targetRecord := range targetData {
-		if _, exists := sourceData[key]; !exists {
-			// 源数据库不存在，目标数据库存在，执行软删除
-			recordsToDelete = append(recordsToDelete, targetRecord)
-			softDeleted++
+	// 输出结果
+	for _, result := range results {
+		fmt.Printf("表 %s 同步结果 (%s):\n", result.TableName, result.Mode)
+		fmt.Printf("  新增: %d\n", result.Added)
+		fmt.Printf("  更新: %d\n", result.Updated)
+		fmt.Printf("  删除: %d\n", result.Deleted)
+		fmt.Printf("  软删除: %d\n", result.SoftDeleted)
+		if result.Mode == "two_way" {
+			fmt.Printf("  写回源端: 新增 %d 更新 %d 删除 %d\n", result.SourceAdded, result.SourceUpdated, result.SourceDeleted)
+			fmt.Printf("  冲突(未处理): %d\n", result.ConflictCount)
 		}
-	}
-
-	if len(recordsToDelete) > 0 {
-		err := s.batchSoftDelete(tableName, recordsToDelete, config)
-		if err != nil {
-			return softDeleted, err
+		if result.FailedCount > 0 {
+			fmt.Printf("  失败(已回滚): %d\n", result.FailedCount)
+			for i, key := range result.FailedKeys {
+				if i >= 20 {
+					fmt.Printf("    ...\n")
+					break
+				}
+				fmt.Printf("    %s\n", key)
+			}
+		}
+		if len(result.Errors) > 0 {
+			fmt.Printf("  错误: %v\n", result.Errors)
 		}
+		fmt.Println()
 	}
-
-	return softDeleted, nil
 }
 
-// 处理硬删除
-func (s *DBSynchronizer) handleHardDelete(sourceData, targetData map[string]map[string]interface{}, config TableConfig) (int, error) {
-	deleted := 0
-	tableName := config.TableName
-	if config.Schema2 != "" {
-		tableName = config.Schema2 + "." + config.TableName
+// 输出最近limit次运行的摘要
+func printReports(filename string, limit int) {
+	reports, err := loadReports(filename)
+	if err != nil {
+		log.Fatalf("读取报告文件 %s 失败: %v", filename, err)
 	}
-
-	var recordsToDelete []map[string]interface{}
-
-	for key, targetRecord := range targetData {
-		if _, exists := sourceData[key]; !exists {
-			// 源数据库不存在，目标数据库存在，执行硬删除
-			recordsToDelete = append(recordsToDelete, targetRecord)
-			deleted++
-		}
+	if len(reports) > limit {
+		reports = reports[len(reports)-limit:]
 	}
-
-	if len(recordsToDelete) > 0 {
-		err := s.batchHardDelete(tableName, recordsToDelete, config)
-		if err != nil {
-			return deleted, err
-		}
+	for _, report := range reports {
+		fmt.Println(report.summary())
 	}
-
-	return deleted, nil
 }
 
-// 比较两个记录是否相等（忽略主键）
-func (s *DBSynchronizer) recordsEqual(record1, record2 map[string]interface{}, primaryKeys []string) bool {
-	if len(record1) != len(record2) {
-		return false
-	}
-
-	for k, v1 := range record1 {
-		// 跳过主键比较
-		isPrimaryKey := false
-		for _, pk := range primaryKeys {
-			if k == pk {
-				isPrimaryKey = true
-				break
-			}
+// 输出对比结果, 每类最多列出20个主键
+func printDiffs(diffs []TableDiff) {
+	printKeys := func(name string, count int, keys []string) {
+		if count == 0 {
+			return
 		}
-		if isPrimaryKey {
-			continue
-		}
-
-		v2, exists := record2[k]
-		if !exists {
-			return false
+		fmt.Printf("  %s: %d\n", name, count)
+		if len(keys) > 20 {
+			keys = keys[:20]
 		}
-
-		if !reflect.DeepEqual(v1, v2) {
-			return false
+		for _, key := range keys {
+			fmt.Printf("    %s\n", key)
 		}
 	}
 
-	return true
-}
-
-// 使用示例
-func main() {
-	// 从配置文件读取配置
-	config, err := LoadConfigFromFile("config.yaml")
-	if err != nil {
-		log.Print("加载配置文件失败:", err)
-	}
-
-	// 创建同步器并执行同步
-	synchronizer := NewDBSynchronizer(config)
-	results := synchronizer.SyncAll()
-
-	// 输出结果
-	for _, result := range results {
-		fmt.Printf("表 %s 同步结果:\n", result.TableName)
-		fmt.Printf("  新增: %d\n", result.Added)
-		fmt.Printf("  更新: %d\n", result.Updated)
-		fmt.Printf("  删除: %d\n", result.Deleted)
-		fmt.Printf("  软删除: %d\n", result.SoftDeleted)
-		if len(result.Errors) > 0 {
-			fmt.Printf("  错误: %v\n", result.Errors)
+	for _, diff := range diffs {
+		if diff.Equal() {
+			fmt.Printf("表 %s 两端一致\n\n", diff.TableName)
+			continue
+		}
+		fmt.Printf("表 %s 对比结果:\n", diff.TableName)
+		printKeys("目标端缺少", diff.MissingCount, diff.MissingInTarget)
+		printKeys("目标端多余", diff.ExtraCount, diff.ExtraInTarget)
+		printKeys("内容不一致", diff.DifferentCount, diff.Different)
+		if len(diff.Errors) > 0 {
+			fmt.Printf("  错误: %v\n", diff.Errors)
 		}
 		fmt.Println()
 	}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 每张表最多记录的冲突数
+const maxConflicts = 1000
+
+// 冲突处理策略
+const (
+	conflictSource = "source" // 源端优先
+	conflictTarget = "target" // 目标端优先
+	conflictNewest = "newest" // timestamp_field较新的一端优先
+	conflictManual = "manual" // 不处理, 记录到运行报告
+)
+
+// 两端都修改过的记录, Source或Target为nil表示该端已删除
+type Conflict struct {
+	Key    string                 `json:"key"`
+	Source map[string]interface{} `json:"source"`
+	Target map[string]interface{} `json:"target"`
+}
+
+// 上次同步后每行在两端的校验值, 0表示未知
+type rowSnapshot [2]uint64
+
+// 双向同步的行快照, 每张表一个json文件
+type tableSnapshot struct {
+	filePath string
+	Rows     map[string]rowSnapshot `json:"rows"`
+}
+
+func snapshotFile(dir string, config TableConfig) string {
+	name := strings.NewReplacer("->", "_", "/", "_", "\\", "_").Replace(stateKey(config))
+	return filepath.Join(dir, name+".json")
+}
+
+func loadTableSnapshot(filePath string) (*tableSnapshot, error) {
+	snapshot := &tableSnapshot{filePath: filePath, Rows: make(map[string]rowSnapshot)}
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return snapshot, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取快照文件失败: %w", err)
+	}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("解析快照文件失败: %w", err)
+	}
+	if snapshot.Rows == nil {
+		snapshot.Rows = make(map[string]rowSnapshot)
+	}
+	return snapshot, nil
+}
+
+// 写回文件, 先写临时文件再改名
+func (snapshot *tableSnapshot) save() error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(snapshot.filePath), 0755); err != nil {
+		return err
+	}
+	tmp := snapshot.filePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, snapshot.filePath)
+}
+
+// 一行的处理结果
+type twoWayAction int
+
+const (
+	actionNone         twoWayAction = iota
+	actionToTarget                  // 用源端记录新增或更新目标端
+	actionToSource                  // 用目标端记录新增或更新源端
+	actionDeleteTarget              // 源端已删除, 删除目标端
+	actionDeleteSource              // 目标端已删除, 删除源端
+	actionConflict                  // 记录冲突, 不处理
+)
+
+// 双向同步的决策
+//
+// 与快照比较判断每一端是否修改过: 只有一端修改时同步到另一端; 两端都修改(或没有快照且不一致)时按策略处理。
+// 一端没有记录时, 有快照说明是删除, 没有快照说明是新增; deletes为false时不同步删除, 把记录补回删除的一端
+type twoWayResolver struct {
+	comparer       *recordComparer
+	policy         string
+	timestampField string
+	deletes        bool
+}
+
+// source/target为nil表示该端没有这条记录, hashes为两端当前的校验值
+func (r *twoWayResolver) resolve(source, target map[string]interface{}, hashes rowSnapshot, previous rowSnapshot, hasPrevious bool) twoWayAction {
+	switch {
+	case source != nil && target != nil:
+		if r.comparer.equal(source, target) {
+			return actionNone
+		}
+		sourceChanged := !hasPrevious || previous[0] != hashes[0]
+		targetChanged := !hasPrevious || previous[1] != hashes[1]
+		switch {
+		case sourceChanged && !targetChanged:
+			return actionToTarget
+		case targetChanged && !sourceChanged:
+			return actionToSource
+		}
+		return r.conflict(source, target)
+
+	case source != nil:
+		if !hasPrevious {
+			return actionToTarget
+		}
+		if previous[0] == hashes[0] {
+			// 目标端删除, 源端未修改
+			if r.deletes {
+				return actionDeleteSource
+			}
+			return actionToTarget
+		}
+		switch r.conflict(source, nil) {
+		case actionToSource:
+			if r.deletes {
+				return actionDeleteSource
+			}
+			return actionToTarget
+		case actionConflict:
+			return actionConflict
+		}
+		return actionToTarget
+
+	case target != nil:
+		if !hasPrevious {
+			return actionToSource
+		}
+		if previous[1] == hashes[1] {
+			if r.deletes {
+				return actionDeleteTarget
+			}
+			return actionToSource
+		}
+		switch r.conflict(nil, target) {
+		case actionToTarget:
+			if r.deletes {
+				return actionDeleteTarget
+			}
+			return actionToSource
+		case actionConflict:
+			return actionConflict
+		}
+		return actionToSource
+	}
+	return actionNone
+}
+
+// 按策略决定冲突时哪一端优先, 一端已删除时newest保留未删除的一端
+func (r *twoWayResolver) conflict(source, target map[string]interface{}) twoWayAction {
+	switch r.policy {
+	case conflictTarget:
+		return actionToSource
+	case conflictNewest:
+		if source == nil {
+			return actionToSource
+		}
+		if target == nil {
+			return actionToTarget
+		}
+		if compareValue(target[r.timestampField], source[r.timestampField]) > 0 {
+			return actionToSource
+		}
+		return actionToTarget
+	case conflictManual:
+		return actionConflict
+	}
+	return actionToTarget
+}
+
+// 双向同步
+//
+// 两端按主键合并比较, 与本地快照对比判断哪一端修改过。快照只记录两端一致的行,
+// 本次写入的行保留原快照, 下次同步时两端一致才更新, 写入失败的行下次会重新处理
+func (s *DBSynchronizer) syncTwoWay(run *tableRun) error {
+	config := run.config
+	targetConfig := config.targetSide()
+	sourceTable, targetTable := s.sourceTableName(config), s.targetTableName(config)
+	batchSize := s.batchSize(config)
+	run.result.Mode = "two_way"
+
+	snapshot, err := loadTableSnapshot(snapshotFile(s.config.SnapshotDir, config))
+	if err != nil {
+		return err
+	}
+	timestampField := config.TimestampField
+	if timestampField == "" {
+		timestampField = config.IncrementalField
+	}
+	resolver := &twoWayResolver{
+		comparer:       run.comparer,
+		policy:         config.ConflictPolicy,
+		timestampField: timestampField,
+		deletes:        s.config.HardDelete || (s.config.EnableSoftDelete && config.SoftDeleteField != ""),
+	}
+	next := make(map[string]rowSnapshot, len(snapshot.Rows))
+
+	var toTarget, toSource [2][]map[string]interface{} // [0]新增 [1]更新
+	flush := func(all bool) {
+		for i, op := range []writeOp{opInsert, opUpdate} {
+			if len(toTarget[i]) > 0 && (all || len(toTarget[i]) >= batchSize) {
+				s.applyTo(false, op, targetTable, toTarget[i], run)
+				toTarget[i] = toTarget[i][:0]
+			}
+			if len(toSource[i]) > 0 && (all || len(toSource[i]) >= batchSize) {
+				s.applyTo(true, op, sourceTable, toSource[i], run)
+				toSource[i] = toSource[i][:0]
+			}
+		}
+	}
+
+	handle := func(source, target map[string]interface{}) error {
+		run.progress.compared.Add(1)
+		record := source
+		if record == nil {
+			record = target
+		}
+		key := normalizedKey(record, config.PrimaryKey, numericKeyColumns(config.PrimaryKey, []map[string]interface{}{source, target}))
+		var hashes rowSnapshot
+		if source != nil {
+			hashes[0] = snapshotHash(source, config.IgnoreColumns)
+		}
+		if target != nil {
+			hashes[1] = snapshotHash(target, config.IgnoreColumns)
+		}
+		previous, hasPrevious := snapshot.Rows[key]
+		if hasPrevious {
+			next[key] = previous
+		}
+
+		switch resolver.resolve(source, target, hashes, previous, hasPrevious) {
+		case actionNone:
+			next[key] = hashes
+		case actionToTarget:
+			if target == nil {
+				toTarget[0] = append(toTarget[0], source)
+			} else {
+				toTarget[1] = append(toTarget[1], updateRecord(source, target, config.PrimaryKey))
+			}
+		case actionToSource:
+			if source == nil {
+				toSource[0] = append(toSource[0], target)
+			} else {
+				toSource[1] = append(toSource[1], updateRecord(target, source, config.PrimaryKey))
+			}
+		case actionDeleteTarget:
+			run.deletes = append(run.deletes, primaryKeyRecord(target, config.PrimaryKey))
+		case actionDeleteSource:
+			run.sourceDeletes = append(run.sourceDeletes, primaryKeyRecord(source, config.PrimaryKey))
+		case actionConflict:
+			run.result.ConflictCount++
+			if len(run.result.Conflicts) < maxConflicts {
+				run.result.Conflicts = append(run.result.Conflicts, Conflict{Key: key, Source: jsonRecord(source), Target: jsonRecord(target)})
+			}
+		}
+		flush(false)
+		return nil
+	}
+
+	source := s.newKeysetStream(s.config.SourceDB, config, sourceTable)
+	target := s.newKeysetStream(s.config.TargetDB, targetConfig, targetTable)
+	err = mergeStreams(source, target, config.PrimaryKey,
+		func(sourceRecord map[string]interface{}) error { return handle(sourceRecord, nil) },
+		func(sourceRecord, targetRecord map[string]interface{}) error {
+			return handle(sourceRecord, targetRecord)
+		},
+		func(targetRecord map[string]interface{}) error { return handle(nil, targetRecord) })
+	if err != nil {
+		// 读取中途出错时快照不完整, 不保存
+		return err
+	}
+	flush(true)
+
+	fmt.Printf("表 %s: 源端读取 %d 条, 目标端读取 %d 条, 冲突 %d 条\n", config.TableName, source.read, target.read, run.result.ConflictCount)
+	snapshot.Rows = next
+	if err := snapshot.save(); err != nil {
+		return fmt.Errorf("保存快照失败: %w", err)
+	}
+	return nil
+}
+
+// 检查双向同步的配置
+// deletes为是否会同步删除; 有查询条件时不再满足条件的行看起来像被删除, 会误删另一端, 不允许同时配置
+func validateTwoWay(config TableConfig, deletes bool) error {
+	switch config.ConflictPolicy {
+	case "", conflictSource, conflictTarget, conflictManual:
+	case conflictNewest:
+		if config.TimestampField == "" && config.IncrementalField == "" {
+			return fmt.Errorf("表 %s: conflict_policy 为 newest 时需要配置 timestamp_field", config.TableName)
+		}
+	default:
+		return fmt.Errorf("表 %s: 不支持的 conflict_policy %s", config.TableName, config.ConflictPolicy)
+	}
+	if config.hasMapping() {
+		return fmt.Errorf("表 %s: 双向同步不支持列转换", config.TableName)
+	}
+	if deletes && (len(config.WhereCondition) > 0 || config.Where != "" || config.TargetWhere != "") {
+		return fmt.Errorf("表 %s: 双向同步同步删除时不能配置查询条件, 不再满足条件的行会被当作删除", config.TableName)
+	}
+	return nil
+}
+
+// 一行的校验值, 不含ignore_columns
+func snapshotHash(record map[string]interface{}, ignoreColumns []string) uint64 {
+	columns := make([]string, 0, len(record))
+	for _, column := range recordColumns([]map[string]interface{}{record}) {
+		if !containsString(ignoreColumns, column) {
+			columns = append(columns, column)
+		}
+	}
+	return rowHash(record, columns)
+}
+
+// 转成可以输出为json的记录, []byte按字符串输出
+func jsonRecord(record map[string]interface{}) map[string]interface{} {
+	if record == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(record))
+	for column, value := range record {
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		result[column] = value
+	}
+	return result
+}
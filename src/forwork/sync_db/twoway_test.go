@@ -0,0 +1,149 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTwoWayResolver(t *testing.T) {
+	config := TableConfig{PrimaryKey: []string{"ID"}}
+	resolver := &twoWayResolver{comparer: newRecordComparer(config, nil, compareOptions{}), deletes: true}
+	row := func(name string) map[string]interface{} {
+		return map[string]interface{}{"ID": int64(1), "NAME": name}
+	}
+	hash := func(record map[string]interface{}) uint64 {
+		return snapshotHash(record, nil)
+	}
+	a, b, c := row("a"), row("b"), row("c")
+
+	tests := []struct {
+		name        string
+		source      map[string]interface{}
+		target      map[string]interface{}
+		previous    rowSnapshot
+		hasPrevious bool
+		want        twoWayAction
+	}{
+		{"equal", a, row("a"), rowSnapshot{}, false, actionNone},
+		{"new in source", a, nil, rowSnapshot{}, false, actionToTarget},
+		{"new in target", nil, a, rowSnapshot{}, false, actionToSource},
+		{"source changed", b, a, rowSnapshot{hash(a), hash(a)}, true, actionToTarget},
+		{"target changed", a, b, rowSnapshot{hash(a), hash(a)}, true, actionToSource},
+		{"both changed", b, c, rowSnapshot{hash(a), hash(a)}, true, actionToTarget},
+		{"no snapshot", b, c, rowSnapshot{}, false, actionToTarget},
+		{"deleted in target", a, nil, rowSnapshot{hash(a), hash(a)}, true, actionDeleteSource},
+		{"deleted in source", nil, a, rowSnapshot{hash(a), hash(a)}, true, actionDeleteTarget},
+		{"changed in source, deleted in target", b, nil, rowSnapshot{hash(a), hash(a)}, true, actionToTarget},
+	}
+	for _, tt := range tests {
+		var hashes rowSnapshot
+		if tt.source != nil {
+			hashes[0] = hash(tt.source)
+		}
+		if tt.target != nil {
+			hashes[1] = hash(tt.target)
+		}
+		if got := resolver.resolve(tt.source, tt.target, hashes, tt.previous, tt.hasPrevious); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	resolver.deletes = false
+	if got := resolver.resolve(a, nil, rowSnapshot{hash(a), 0}, rowSnapshot{hash(a), hash(a)}, true); got != actionToTarget {
+		t.Errorf("without deletes the row should be restored, got %d", got)
+	}
+}
+
+func TestTwoWayResolverPolicies(t *testing.T) {
+	config := TableConfig{PrimaryKey: []string{"ID"}}
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	source := map[string]interface{}{"ID": 1, "NAME": "s", "UPDATE_TIME": older}
+	target := map[string]interface{}{"ID": 1, "NAME": "t", "UPDATE_TIME": newer}
+	previous := rowSnapshot{1, 2} // 两端都已修改
+
+	tests := []struct {
+		policy string
+		want   twoWayAction
+	}{
+		{conflictSource, actionToTarget},
+		{conflictTarget, actionToSource},
+		{conflictNewest, actionToSource},
+		{conflictManual, actionConflict},
+	}
+	for _, tt := range tests {
+		resolver := &twoWayResolver{
+			comparer:       newRecordComparer(config, nil, compareOptions{}),
+			policy:         tt.policy,
+			timestampField: "UPDATE_TIME",
+		}
+		hashes := rowSnapshot{snapshotHash(source, nil), snapshotHash(target, nil)}
+		if got := resolver.resolve(source, target, hashes, previous, true); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestTableSnapshotSaveLoad(t *testing.T) {
+	config := TableConfig{TableName: "T", Schema1: "A", Schema2: "B"}
+	file := snapshotFile(t.TempDir(), config)
+	if filepath.Base(file) != "A.T_B.T.json" {
+		t.Fatalf("unexpected snapshot file %s", file)
+	}
+
+	snapshot, err := loadTableSnapshot(file)
+	if err != nil || len(snapshot.Rows) != 0 {
+		t.Fatalf("unexpected snapshot %v %v", snapshot, err)
+	}
+	snapshot.Rows["1"] = rowSnapshot{10, 20}
+	if err := snapshot.save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadTableSnapshot(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Rows["1"] != (rowSnapshot{10, 20}) {
+		t.Fatalf("unexpected rows %v", loaded.Rows)
+	}
+}
+
+func TestValidateTwoWay(t *testing.T) {
+	if err := validateTwoWay(TableConfig{TableName: "T", ConflictPolicy: conflictNewest}, false); err == nil {
+		t.Fatal("newest without timestamp_field should fail")
+	}
+	if err := validateTwoWay(TableConfig{TableName: "T", ColumnMap: map[string]string{"A": "B"}}, false); err == nil {
+		t.Fatal("column mapping should not be allowed")
+	}
+	if err := validateTwoWay(TableConfig{TableName: "T", ConflictPolicy: conflictManual}, true); err != nil {
+		t.Fatal(err)
+	}
+	filtered := TableConfig{TableName: "T", Where: "OWNER = ?", WhereArgs: []interface{}{"350900"}}
+	if err := validateTwoWay(filtered, true); err == nil {
+		t.Fatal("where with deletes should fail")
+	}
+	if err := validateTwoWay(filtered, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 目标表不同时快照分开保存, 不能沿用源表名的快照
+func TestSnapshotFileTargetTable(t *testing.T) {
+	dir := t.TempDir()
+	config := TableConfig{TableName: "T", TargetTable: "T2", Schema1: "A", Schema2: "B"}
+	if file := snapshotFile(dir, config); filepath.Base(file) != "A.T_B.T2.json" {
+		t.Fatalf("unexpected snapshot file %s", file)
+	}
+	config.TargetTable = ""
+	old, _ := loadTableSnapshot(snapshotFile(dir, config))
+	old.Rows["1"] = rowSnapshot{1, 1}
+	if err := old.save(); err != nil {
+		t.Fatal(err)
+	}
+	config.TargetTable = "T2"
+	snapshot, err := loadTableSnapshot(snapshotFile(dir, config))
+	if err != nil || len(snapshot.Rows) != 0 {
+		t.Fatalf("unexpected snapshot %+v %v", snapshot, err)
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 每张表最多记录的失败主键数
+const maxFailedKeys = 1000
+
+// 一条语句最多的参数个数, MySQL预处理语句的上限, 达梦也按此拆分
+const maxPlaceholders = 65535
+
+type writeOp int
+
+const (
+	opInsert writeOp = iota
+	opUpdate
+	opSoftDelete
+	opHardDelete
+)
+
+func (op writeOp) String() string {
+	switch op {
+	case opInsert:
+		return "批量插入"
+	case opUpdate:
+		return "批量更新"
+	case opSoftDelete:
+		return "软删除"
+	default:
+		return "硬删除"
+	}
+}
+
+// 用于文件名和报告的操作名
+func (op writeOp) name() string {
+	switch op {
+	case opInsert:
+		return "insert"
+	case opUpdate:
+		return "update"
+	case opSoftDelete:
+		return "soft_delete"
+	default:
+		return "delete"
+	}
+}
+
+// 按批写入目标端并记录结果
+//
+// 每批在一个事务中执行, 失败时整批回滚, 记录失败的主键后继续下一批
+func (s *DBSynchronizer) apply(op writeOp, tableName string, records []map[string]interface{}, run *tableRun) {
+	s.applyTo(false, op, tableName, records, run)
+}
+
+// reverse为true时写回源端, 用于双向同步
+func (s *DBSynchronizer) applyTo(reverse bool, op writeOp, tableName string, records []map[string]interface{}, run *tableRun) {
+	db, config, result := s.config.TargetDB, run.config.targetSide(), &run.result
+	name := op.name()
+	if reverse {
+		db, config, name = s.config.SourceDB, run.config, "source_"+name
+	}
+	batchSize := s.batchSize(config)
+	for i := 0; i < len(records); i += batchSize {
+		end := i + batchSize
+		if end > len(records) {
+			end = len(records)
+		}
+
+		batch := records[i:end]
+		if err := s.writeBatch(db, op, tableName, batch, config); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("%s失败 (%d 条): %w", op, len(batch), err))
+			for _, record := range batch {
+				result.FailedCount++
+				if len(result.FailedKeys) < maxFailedKeys {
+					result.FailedKeys = append(result.FailedKeys, s.generatePrimaryKey(record, config.PrimaryKey))
+				}
+			}
+			continue
+		}
+
+		run.progress.written.Add(int64(len(batch)))
+		for _, record := range batch {
+			if len(result.ChangedKeys) >= maxChangedKeys {
+				break
+			}
+			result.ChangedKeys = append(result.ChangedKeys, name+":"+s.generatePrimaryKey(record, config.PrimaryKey))
+		}
+		if s.exporter != nil {
+			if err := s.exporter.write(op, name, tableName, batch, config); err != nil {
+				result.Errors = append(result.Errors, fmt.Errorf("导出变更记录失败: %w", err))
+			}
+		}
+		if reverse {
+			switch op {
+			case opInsert:
+				result.SourceAdded += len(batch)
+			case opUpdate:
+				result.SourceUpdated += len(batch)
+			default:
+				result.SourceDeleted += len(batch)
+			}
+			continue
+		}
+		switch op {
+		case opInsert:
+			result.Added += len(batch)
+		case opUpdate:
+			result.Updated += len(batch)
+		case opSoftDelete:
+			result.SoftDeleted += len(batch)
+		case opHardDelete:
+			result.Deleted += len(batch)
+		}
+	}
+}
+
+// 在一个事务中写入一批记录, 临时错误时按配置重试
+func (s *DBSynchronizer) writeBatch(db *gorm.DB, op writeOp, tableName string, records []map[string]interface{}, config TableConfig) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			switch op {
+			case opInsert:
+				return eachStatement(records, func(columns []string, rows []map[string]interface{}) error {
+					return tx.Table(tableName).Create(rows).Error
+				})
+			case opUpdate:
+				return s.update(tx, tableName, records, config.PrimaryKey)
+			case opSoftDelete:
+				condition, values := keysCondition(config.PrimaryKey, records)
+				return tx.Table(tableName).
+					Where(condition, values...).
+					Updates(map[string]interface{}{config.SoftDeleteField: config.SoftDeleteValue}).Error
+			default:
+				condition, values := keysCondition(config.PrimaryKey, records)
+				return tx.Table(tableName).Where(condition, values...).Delete(nil).Error
+			}
+		})
+		if err == nil || attempt >= s.config.Retry || !isTransient(err) {
+			return err
+		}
+		fmt.Printf("表 %s: %s失败, %s 后重试 (%d/%d): %v\n", tableName, op, s.config.RetryInterval, attempt+1, s.config.Retry, err)
+		time.Sleep(s.config.RetryInterval)
+	}
+}
+
+// 更新, 达梦和MySQL用一条MERGE/upsert语句, 其他数据库逐条更新
+func (s *DBSynchronizer) update(tx *gorm.DB, tableName string, records []map[string]interface{}, primaryKeys []string) error {
+	dialect := tx.Dialector.Name()
+	if dialect == "dm" || dialect == "mysql" {
+		return eachStatement(records, func(columns []string, rows []map[string]interface{}) error {
+			values := make([]interface{}, 0, len(rows)*len(columns))
+			for _, record := range rows {
+				for _, column := range columns {
+					values = append(values, record[column])
+				}
+			}
+			return tx.Exec(upsertSQL(dialect, tableName, columns, primaryKeys, len(rows)), values...).Error
+		})
+	}
+
+	for _, record := range records {
+		whereClause := make([]string, 0, len(primaryKeys))
+		whereValues := make([]interface{}, 0, len(primaryKeys))
+		updateData := make(map[string]interface{})
+		for k, v := range record {
+			if containsString(primaryKeys, k) {
+				whereClause = append(whereClause, k+" = ?")
+				whereValues = append(whereValues, v)
+			} else {
+				updateData[k] = v
+			}
+		}
+		if len(whereClause) == 0 {
+			continue
+		}
+		err := tx.Table(tableName).Where(strings.Join(whereClause, " AND "), whereValues...).Updates(updateData).Error
+		if err != nil {
+			return fmt.Errorf("更新记录失败 (主键: %v): %w", whereValues, err)
+		}
+	}
+	return nil
+}
+
+// 生成批量upsert语句, 参数按行依次排列
+//
+//	dm:    MERGE INTO t dst USING (SELECT ? AS A, ? AS B FROM DUAL UNION ALL ...) src ON (...) WHEN MATCHED ... WHEN NOT MATCHED ...
+//	mysql: INSERT INTO t (A, B) VALUES (?, ?), ... ON DUPLICATE KEY UPDATE B = VALUES(B)
+func upsertSQL(dialect, tableName string, columns, primaryKeys []string, rows int) string {
+	var updates []string
+	var sb strings.Builder
+
+	if dialect == "mysql" {
+		placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+		sb.WriteString("INSERT INTO " + tableName + " (" + strings.Join(columns, ", ") + ") VALUES ")
+		for i := 0; i < rows; i++ {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(placeholders)
+		}
+		for _, column := range columns {
+			if !containsString(primaryKeys, column) {
+				updates = append(updates, column+" = VALUES("+column+")")
+			}
+		}
+		if len(updates) == 0 {
+			// 只有主键列时没有需要更新的内容, 重复时不做任何修改
+			updates = append(updates, primaryKeys[0]+" = "+primaryKeys[0])
+		}
+		sb.WriteString(" ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", "))
+		return sb.String()
+	}
+
+	sb.WriteString("MERGE INTO " + tableName + " dst USING (")
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			sb.WriteString(" UNION ALL ")
+		}
+		sb.WriteString("SELECT ")
+		for j, column := range columns {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString("?")
+			if i == 0 {
+				sb.WriteString(" AS " + column)
+			}
+		}
+		sb.WriteString(" FROM DUAL")
+	}
+	sb.WriteString(") src ON (")
+	for i, pk := range primaryKeys {
+		if i > 0 {
+			sb.WriteString(" AND ")
+		}
+		sb.WriteString("dst." + pk + " = src." + pk)
+	}
+	sb.WriteString(")")
+
+	for _, column := range columns {
+		if !containsString(primaryKeys, column) {
+			updates = append(updates, "dst."+column+" = src."+column)
+		}
+	}
+	if len(updates) > 0 {
+		sb.WriteString(" WHEN MATCHED THEN UPDATE SET " + strings.Join(updates, ", "))
+	}
+	inserts := make([]string, 0, len(columns))
+	for _, column := range columns {
+		inserts = append(inserts, "src."+column)
+	}
+	sb.WriteString(" WHEN NOT MATCHED THEN INSERT (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(inserts, ", ") + ")")
+	return sb.String()
+}
+
+// 把一批记录拆成多条语句执行
+//
+// 列不同的记录分开写, 记录中没有的列不会被写成NULL;
+// 每条语句的参数个数不超过maxPlaceholders
+func eachStatement(records []map[string]interface{}, fn func(columns []string, rows []map[string]interface{}) error) error {
+	for _, group := range groupByColumns(records) {
+		columns := recordColumns(group)
+		size := max(1, maxPlaceholders/max(1, len(columns)))
+		for i := 0; i < len(group); i += size {
+			if err := fn(columns, group[i:min(i+size, len(group))]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 按列集合分组, 组的顺序按第一次出现的顺序
+func groupByColumns(records []map[string]interface{}) [][]map[string]interface{} {
+	index := make(map[string]int)
+	var groups [][]map[string]interface{}
+	for _, record := range records {
+		key := strings.Join(recordColumns([]map[string]interface{}{record}), "\x00")
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], record)
+	}
+	return groups
+}
+
+// 一批记录的所有列, 按名称排序
+func recordColumns(records []map[string]interface{}) []string {
+	exists := make(map[string]bool)
+	columns := make([]string, 0)
+	for _, record := range records {
+		for column := range record {
+			if !exists[column] {
+				exists[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// 是否是可以重试的临时错误: 连接断开、超时、死锁、锁等待
+func isTransient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	for _, keyword := range []string{
+		"connection reset", "broken pipe", "connection refused", "timeout", "timed out",
+		"deadlock", "lock wait", "invalid connection", "bad connection",
+	} {
+		if strings.Contains(message, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
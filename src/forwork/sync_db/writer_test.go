@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestUpsertSQL(t *testing.T) {
+	columns := []string{"ID", "NAME", "OWNER"}
+	primaryKeys := []string{"ID"}
+
+	mysql := upsertSQL("mysql", "WEB.T", columns, primaryKeys, 2)
+	want := "INSERT INTO WEB.T (ID, NAME, OWNER) VALUES (?, ?, ?), (?, ?, ?) ON DUPLICATE KEY UPDATE NAME = VALUES(NAME), OWNER = VALUES(OWNER)"
+	if mysql != want {
+		t.Fatalf("mysql:\n%s\nwant:\n%s", mysql, want)
+	}
+
+	dm := upsertSQL("dm", "WEB.T", columns, primaryKeys, 2)
+	want = "MERGE INTO WEB.T dst USING (SELECT ? AS ID, ? AS NAME, ? AS OWNER FROM DUAL UNION ALL SELECT ?, ?, ? FROM DUAL) src ON (dst.ID = src.ID)" +
+		" WHEN MATCHED THEN UPDATE SET dst.NAME = src.NAME, dst.OWNER = src.OWNER" +
+		" WHEN NOT MATCHED THEN INSERT (ID, NAME, OWNER) VALUES (src.ID, src.NAME, src.OWNER)"
+	if dm != want {
+		t.Fatalf("dm:\n%s\nwant:\n%s", dm, want)
+	}
+}
+
+func TestKeysConditionComposite(t *testing.T) {
+	records := []map[string]interface{}{{"A": 1, "B": 2}, {"A": 3, "B": 4}}
+	condition, values := keysCondition([]string{"A", "B"}, records)
+	if condition != "((A = ? AND B = ?) OR (A = ? AND B = ?))" || len(values) != 4 {
+		t.Fatalf("unexpected condition %s %v", condition, values)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	if !isTransient(errors.New("Error 1213: Deadlock found when trying to get lock")) {
+		t.Fatal("deadlock should be transient")
+	}
+	if isTransient(errors.New("Error 1062: Duplicate entry '1' for key 'PRIMARY'")) {
+		t.Fatal("duplicate key should not be transient")
+	}
+}
+
+func TestEachStatement(t *testing.T) {
+	// 20列的表, 5000条记录需要拆成两条语句
+	wide := make(map[string]interface{}, 20)
+	for i := 0; i < 20; i++ {
+		wide[fmt.Sprintf("C%02d", i)] = i
+	}
+	records := make([]map[string]interface{}, 0, 5002)
+	for i := 0; i < 5000; i++ {
+		records = append(records, wide)
+	}
+	records = append(records, map[string]interface{}{"C00": 1}, map[string]interface{}{"C00": 2})
+
+	var statements []int
+	err := eachStatement(records, func(columns []string, rows []map[string]interface{}) error {
+		if len(columns)*len(rows) > maxPlaceholders {
+			t.Errorf("%d 个参数超过上限", len(columns)*len(rows))
+		}
+		for _, row := range rows {
+			if len(row) != len(columns) {
+				t.Errorf("记录列 %v 与语句列 %v 不一致", row, columns)
+			}
+		}
+		statements = append(statements, len(rows))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(statements, []int{3276, 1724, 2}) {
+		t.Fatalf("unexpected statements %v", statements)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"raselper/src/forwork/read_model/util"
+	"raselper/src/secondary/topology"
+	"sort"
+	"strings"
+)
+
+// 悬空端子
+type DanglingTerminal struct {
+	Terminal string `json:"terminal"`
+	Device   string `json:"device"`
+	Node     string `json:"node,omitempty"`
+	Reason   string `json:"reason"` // no_node: 没有连接节点; undeclared_node: 连接节点未定义
+}
+
+// 一条馈线的统计
+type FeederStats struct {
+	Circuit       string   `json:"circuit"`
+	Name          string   `json:"name"`
+	Current       bool     `json:"current"` // iscurrentfeeder
+	Devices       int      `json:"devices"` // 不含杆塔和故障指示器
+	Terminals     int      `json:"terminals"`
+	Components    int      `json:"components"`     // 只看本馈线设备的连通分量
+	IslandDevices int      `json:"island_devices"` // 不在文件主网中的设备
+	NoTerminal    []string `json:"no_terminal,omitempty"`
+	MultiTerminal []string `json:"multi_terminal,omitempty"`
+}
+
+// 一个单线图文件的分析结果
+type FileAnalysis struct {
+	File           string             `json:"file"`
+	Error          string             `json:"error,omitempty"`
+	Devices        int                `json:"devices"`
+	Terminals      int                `json:"terminals"`
+	Nodes          int                `json:"nodes"`
+	Components     int                `json:"components"`
+	Islands        [][]string         `json:"islands,omitempty"` // 除最大连通分量外的其他分量
+	Dangling       []DanglingTerminal `json:"dangling,omitempty"`
+	UnknownDevices int                `json:"unknown_devices"` // 端子连接的设备在文件中没有定义
+	UnknownClasses map[string]int     `json:"unknown_classes,omitempty"`
+	MultiTerminal  []string           `json:"multi_terminal,omitempty"`
+	Feeders        []FeederStats      `json:"feeders"`
+	Rejected       bool               `json:"rejected"`
+	Reasons        []string           `json:"reasons,omitempty"`
+}
+
+// 要分析的文件, path为目录时包含其下所有xml
+func analyzeFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(file, ".xml") {
+			files = append(files, file)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+func analyzeFile(file string) FileAnalysis {
+	model, err := util.ParseCIMFile(file)
+	if err != nil {
+		return FileAnalysis{File: file, Error: err.Error(), Rejected: true, Reasons: []string{"解析失败"}}
+	}
+	return analyzeModel(file, model)
+}
+
+// 连通性、孤立岛、悬空端子和多端子设备检查, 不连接数据库
+func analyzeModel(file string, model *util.CIMFile) FileAnalysis {
+	rdf := model.RDF
+	analysis := FileAnalysis{
+		File:           file,
+		Terminals:      len(rdf.Terminals),
+		UnknownClasses: model.UnknownClasses(),
+		Feeders:        make([]FeederStats, 0),
+	}
+
+	// 设备 - 馈线, 包括RDF结构体中没有的负荷开关、用户等
+	deviceFeederMap := make(map[string]string)
+	var devices []string
+	for _, equipment := range model.Equipments() {
+		if _, ok := deviceFeederMap[equipment.ID]; !ok {
+			devices = append(devices, equipment.ID)
+		}
+		deviceFeederMap[equipment.ID] = equipment.Circuit
+	}
+
+	declared := make(map[string]bool, len(rdf.ConnectivityNodes))
+	for _, node := range rdf.ConnectivityNodes {
+		declared[node.ID] = true
+	}
+
+	// 杆塔和故障指示器是附属设备, 不参与连通性; 部分文件把它们的端子都连到节点"0"
+	attached := make(map[string]bool, len(rdf.Poles)+len(rdf.FaultIndicators))
+	for _, pole := range rdf.Poles {
+		attached[pole.ID] = true
+	}
+	for _, indicator := range rdf.FaultIndicators {
+		attached[indicator.ID] = true
+	}
+
+	// 按端子顺序建图, 保证结果稳定; 未定义的设备也参与连通性
+	graph := topology.New()
+	terminals := make(map[string]int)
+	for _, terminal := range rdf.Terminals {
+		device := strings.TrimPrefix(terminal.ConductingEquipment.Resource, "#")
+		node := strings.TrimPrefix(terminal.ConnectivityNode.Resource, "#")
+		if attached[device] {
+			continue
+		}
+		if _, ok := deviceFeederMap[device]; !ok {
+			analysis.UnknownDevices++
+		} else {
+			terminals[device]++
+		}
+		switch {
+		case node == "":
+			analysis.Dangling = append(analysis.Dangling, DanglingTerminal{Terminal: terminal.ID, Device: device, Reason: "no_node"})
+		case len(declared) > 0 && !declared[node]:
+			analysis.Dangling = append(analysis.Dangling, DanglingTerminal{Terminal: terminal.ID, Device: device, Node: node, Reason: "undeclared_node"})
+		}
+		graph.AddDevice(device, node)
+	}
+	for _, device := range devices {
+		graph.AddDevice(device)
+		if terminals[device] > 2 {
+			analysis.MultiTerminal = append(analysis.MultiTerminal, device)
+		}
+	}
+
+	components := graph.Components()
+	analysis.Devices = len(graph.Devices())
+	analysis.Nodes = len(graph.Nodes())
+	analysis.Components = len(components)
+	analysis.Islands = graph.Islands()
+	island := make(map[string]bool)
+	for _, devices := range analysis.Islands {
+		for _, device := range devices {
+			island[device] = true
+		}
+	}
+
+	brokenFeeders := 0
+	for _, circuit := range rdf.Circuits {
+		stats := FeederStats{Circuit: circuit.ID, Name: circuit.Name, Current: circuit.IsCurrentFeeder == "1"}
+		feederGraph := topology.New()
+		for _, device := range graph.Devices() {
+			if deviceFeederMap[device] != "#"+circuit.ID {
+				continue
+			}
+			stats.Devices++
+			stats.Terminals += terminals[device]
+			feederGraph.AddDevice(device, graph.DeviceNodes(device)...)
+			if terminals[device] == 0 {
+				stats.NoTerminal = append(stats.NoTerminal, device)
+			}
+			if terminals[device] > 2 {
+				stats.MultiTerminal = append(stats.MultiTerminal, device)
+			}
+			if island[device] {
+				stats.IslandDevices++
+			}
+		}
+		stats.Components = len(feederGraph.Components())
+		if stats.Current && stats.IslandDevices > 0 {
+			brokenFeeders++
+		}
+		analysis.Feeders = append(analysis.Feeders, stats)
+	}
+
+	// 联络图中常带有相邻馈线或变电站的孤立母线, 只有本馈线(iscurrentfeeder=1)的设备落在孤立岛中才拒绝
+	if brokenFeeders > 0 {
+		analysis.Reasons = append(analysis.Reasons, fmt.Sprintf("%d 条本馈线存在孤立岛", brokenFeeders))
+	}
+	if len(analysis.Dangling) > 0 {
+		analysis.Reasons = append(analysis.Reasons, fmt.Sprintf("%d 个悬空端子", len(analysis.Dangling)))
+	}
+	if len(components) == 0 {
+		analysis.Reasons = append(analysis.Reasons, "没有可导入的拓扑")
+	}
+	analysis.Rejected = len(analysis.Reasons) > 0
+	return analysis
+}
+
+func printAnalysis(analyses []FileAnalysis) {
+	rejected := 0
+	for _, a := range analyses {
+		status := "✅ 通过"
+		if a.Rejected {
+			status = "❌ 拒绝: " + strings.Join(a.Reasons, ", ")
+			rejected++
+		}
+		fmt.Printf("%s\n  %s\n", a.File, status)
+		if a.Error != "" {
+			fmt.Printf("  错误: %s\n", a.Error)
+			continue
+		}
+		fmt.Printf("  设备 %d, 端子 %d, 节点 %d, 连通分量 %d, 未定义设备端子 %d, 多端子设备 %d\n",
+			a.Devices, a.Terminals, a.Nodes, a.Components, a.UnknownDevices, len(a.MultiTerminal))
+		if len(a.UnknownClasses) > 0 {
+			classes := make([]string, 0, len(a.UnknownClasses))
+			for class, count := range a.UnknownClasses {
+				classes = append(classes, fmt.Sprintf("%s %d", class, count))
+			}
+			sort.Strings(classes)
+			fmt.Printf("  未解析类型: %s\n", strings.Join(classes, ", "))
+		}
+		for _, f := range a.Feeders {
+			current := ""
+			if f.Current {
+				current = " (本馈线)"
+			}
+			fmt.Printf("  馈线 %s %s%s: 设备 %d, 端子 %d, 连通分量 %d, 孤立设备 %d, 无端子 %d, 多端子 %d\n",
+				f.Circuit, f.Name, current, f.Devices, f.Terminals, f.Components, f.IslandDevices, len(f.NoTerminal), len(f.MultiTerminal))
+		}
+		for i, island := range a.Islands {
+			if i >= 10 {
+				fmt.Printf("  ...\n")
+				break
+			}
+			fmt.Printf("  孤立岛 %d: %d 台设备, 如 %s\n", i+1, len(island), island[0])
+		}
+		for i, d := range a.Dangling {
+			if i >= 10 {
+				fmt.Printf("  ...\n")
+				break
+			}
+			fmt.Printf("  悬空端子 %s: 设备 %s %s %s\n", d.Terminal, d.Device, d.Reason, d.Node)
+		}
+	}
+	fmt.Printf("\n共 %d 个文件, 拒绝 %d 个\n", len(analyses), rejected)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCIM = `<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:cim="http://www.sgcc.com.cn/SG-CIM/2010MAY#">
+<cim:Circuit rdf:ID="F1"><cim:IdentifiedObject.name>本馈线</cim:IdentifiedObject.name><cim:iscurrentfeeder>1</cim:iscurrentfeeder></cim:Circuit>
+<cim:Circuit rdf:ID="F2"><cim:IdentifiedObject.name>相邻馈线</cim:IdentifiedObject.name><cim:iscurrentfeeder>0</cim:iscurrentfeeder></cim:Circuit>
+<cim:Breaker rdf:ID="breaker"><cim:PowerSystemResource.Circuit rdf:resource="#F1"/></cim:Breaker>
+<cim:ACLineSegment rdf:ID="line"><cim:PowerSystemResource.Circuit rdf:resource="#F1"/></cim:ACLineSegment>
+<cim:PowerTransformer rdf:ID="transformer"><cim:PowerSystemResource.Circuit rdf:resource="#F1"/></cim:PowerTransformer>
+<cim:BusbarSection rdf:ID="bus"><cim:PowerSystemResource.Circuit rdf:resource="#F2"/></cim:BusbarSection>
+<cim:Pole rdf:ID="pole"><cim:PowerSystemResource.Circuit rdf:resource="#F1"/></cim:Pole>
+<cim:ConnectivityNode rdf:ID="n1"/>
+<cim:ConnectivityNode rdf:ID="n2"/>
+<cim:ConnectivityNode rdf:ID="n3"/>
+<cim:ConnectivityNode rdf:ID="n9"/>
+<cim:ConnectivityNode rdf:ID="0"/>
+<cim:Terminal rdf:ID="t1"><cim:Terminal.ConductingEquipment rdf:resource="#breaker"/><cim:Terminal.ConnectivityNode rdf:resource="#n1"/></cim:Terminal>
+<cim:Terminal rdf:ID="t2"><cim:Terminal.ConductingEquipment rdf:resource="#breaker"/><cim:Terminal.ConnectivityNode rdf:resource="#n2"/></cim:Terminal>
+<cim:Terminal rdf:ID="t3"><cim:Terminal.ConductingEquipment rdf:resource="#line"/><cim:Terminal.ConnectivityNode rdf:resource="#n2"/></cim:Terminal>
+<cim:Terminal rdf:ID="t4"><cim:Terminal.ConductingEquipment rdf:resource="#line"/><cim:Terminal.ConnectivityNode rdf:resource="#n3"/></cim:Terminal>
+<cim:Terminal rdf:ID="t5"><cim:Terminal.ConductingEquipment rdf:resource="#transformer"/><cim:Terminal.ConnectivityNode rdf:resource="#n3"/></cim:Terminal>
+<cim:Terminal rdf:ID="t6"><cim:Terminal.ConductingEquipment rdf:resource="#transformer"/><cim:Terminal.ConnectivityNode rdf:resource="#n4"/></cim:Terminal>
+<cim:Terminal rdf:ID="t7"><cim:Terminal.ConductingEquipment rdf:resource="#transformer"/><cim:Terminal.ConnectivityNode rdf:resource="#n5"/></cim:Terminal>
+<cim:Terminal rdf:ID="t8"><cim:Terminal.ConductingEquipment rdf:resource="#bus"/><cim:Terminal.ConnectivityNode rdf:resource="#n9"/></cim:Terminal>
+<cim:Terminal rdf:ID="t9"><cim:Terminal.ConductingEquipment rdf:resource="#consumer"/><cim:Terminal.ConnectivityNode rdf:resource="#n1"/></cim:Terminal>
+<cim:Terminal rdf:ID="t10"><cim:Terminal.ConductingEquipment rdf:resource="#pole"/><cim:Terminal.ConnectivityNode rdf:resource="#0"/></cim:Terminal>
+</rdf:RDF>
+`
+
+func writeCIM(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "test_单线图.xml")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestAnalyzeFile(t *testing.T) {
+	a := analyzeFile(writeCIM(t, testCIM))
+	if a.Error != "" {
+		t.Fatal(a.Error)
+	}
+	// 相邻馈线的孤立母线不拒绝; transformer的n4/n5未定义
+	if !a.Rejected || len(a.Reasons) != 1 || !strings.Contains(a.Reasons[0], "悬空端子") {
+		t.Fatalf("unexpected reasons %v", a.Reasons)
+	}
+	if len(a.Dangling) != 2 || a.Dangling[0].Reason != "undeclared_node" || a.Dangling[0].Node != "n4" {
+		t.Fatalf("unexpected dangling %+v", a.Dangling)
+	}
+	if a.Devices != 5 || a.Components != 2 || a.UnknownDevices != 1 {
+		t.Fatalf("unexpected counts %+v", a)
+	}
+	if len(a.Islands) != 1 || a.Islands[0][0] != "bus" {
+		t.Fatalf("unexpected islands %v", a.Islands)
+	}
+	if len(a.MultiTerminal) != 1 || a.MultiTerminal[0] != "transformer" {
+		t.Fatalf("unexpected multi-terminal %v", a.MultiTerminal)
+	}
+
+	if len(a.Feeders) != 2 {
+		t.Fatalf("unexpected feeders %+v", a.Feeders)
+	}
+	f1, f2 := a.Feeders[0], a.Feeders[1]
+	if !f1.Current || f1.Devices != 3 || f1.Terminals != 7 || f1.Components != 1 || f1.IslandDevices != 0 {
+		t.Fatalf("unexpected F1 %+v", f1)
+	}
+	if f2.Current || f2.Devices != 1 || f2.IslandDevices != 1 {
+		t.Fatalf("unexpected F2 %+v", f2)
+	}
+}
+
+func TestAnalyzeFileRejectIsland(t *testing.T) {
+	// 本馈线的母线与主网断开
+	content := strings.Replace(testCIM, `rdf:ID="bus"><cim:PowerSystemResource.Circuit rdf:resource="#F2"/>`,
+		`rdf:ID="bus"><cim:PowerSystemResource.Circuit rdf:resource="#F1"/>`, 1)
+	content = strings.NewReplacer(`"#n4"`, `"#n3"`, `"#n5"`, `"#n3"`).Replace(content)
+	a := analyzeFile(writeCIM(t, content))
+	if !a.Rejected || len(a.Reasons) != 1 || !strings.Contains(a.Reasons[0], "孤立岛") {
+		t.Fatalf("unexpected reasons %v", a.Reasons)
+	}
+	if a.Feeders[0].IslandDevices != 1 {
+		t.Fatalf("unexpected F1 %+v", a.Feeders[0])
+	}
+}
+
+func TestAnalyzeFileParseError(t *testing.T) {
+	a := analyzeFile(writeCIM(t, "<rdf:RDF"))
+	if !a.Rejected || a.Error == "" {
+		t.Fatalf("parse error should reject: %+v", a)
+	}
+}
+
+func TestAnalyzeSamples(t *testing.T) {
+	files, err := analyzeFiles("../data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skip("no sample files")
+	}
+	for _, file := range files {
+		a := analyzeFile(file)
+		if a.Error != "" {
+			t.Errorf("%s: %s", file, a.Error)
+			continue
+		}
+		if a.Devices == 0 || len(a.Feeders) == 0 {
+			t.Errorf("%s: no devices or feeders", file)
+		}
+	}
+}
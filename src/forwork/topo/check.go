@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"raselper/src/forwork/first"
+	"raselper/src/secondary/topology"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 问题类型
+const (
+	issueIsland         = "island"          // 孤立岛
+	issueNoNode         = "no_node"         // 设备没有连接节点
+	issueParallel       = "parallel"        // 多台设备连接同一对节点
+	issueHighDegree     = "high_degree"     // 节点连接的设备过多
+	issueSelfLoop       = "self_loop"       // 设备两端是同一节点
+	issueFeederMismatch = "feeder_mismatch" // 相邻设备都不属于本馈线
+)
+
+var issueKinds = []string{issueIsland, issueNoNode, issueParallel, issueHighDegree, issueSelfLoop, issueFeederMismatch}
+
+// handle_topo中节点连接超过7台设备视为异常
+const defaultMaxDegree = 7
+
+type TopoIssue struct {
+	Kind    string   `json:"kind"`
+	Devices []string `json:"devices,omitempty"`
+	Nodes   []string `json:"nodes,omitempty"`
+	Detail  string   `json:"detail"`
+}
+
+// 一条馈线的检查结果
+type FeederReport struct {
+	Owner      string         `json:"owner"`
+	FeederID   string         `json:"feeder_id"`
+	Devices    int            `json:"devices"`
+	Nodes      int            `json:"nodes"`
+	Components int            `json:"components"`
+	Counts     map[string]int `json:"counts"`
+	Issues     []TopoIssue    `json:"issues"`
+}
+
+func (r *FeederReport) add(issue TopoIssue) {
+	r.Issues = append(r.Issues, issue)
+	r.Counts[issue.Kind]++
+}
+
+// 检查owner下的拓扑, feeders为空时检查所有馈线
+//
+// 馈线不一致需要看相邻馈线的设备, 所以rows应包含owner下所有馈线的记录
+func checkOwner(owner string, rows []first.Topo, feeders []string, maxDegree int) []FeederReport {
+	ownerGraph := first.NewTopoGraph(rows)
+	feederOf := make(map[string][]string, len(rows)) // 同一设备可能在多条馈线下有记录
+	feederRows := make(map[string][]first.Topo)
+	for _, row := range rows {
+		if !first.Contain(feederOf[row.ID], row.FeederID) {
+			feederOf[row.ID] = append(feederOf[row.ID], row.FeederID)
+		}
+		feederRows[row.FeederID] = append(feederRows[row.FeederID], row)
+	}
+	if len(feeders) == 0 {
+		for feeder := range feederRows {
+			feeders = append(feeders, feeder)
+		}
+		sort.Strings(feeders)
+	}
+
+	reports := make([]FeederReport, 0, len(feeders))
+	for _, feeder := range feeders {
+		report := checkFeeder(owner, feeder, feederRows[feeder], maxDegree)
+		checkFeederMismatch(&report, feederRows[feeder], ownerGraph, feederOf)
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// 只看本馈线记录的检查
+func checkFeeder(owner, feeder string, rows []first.Topo, maxDegree int) FeederReport {
+	graph := first.NewTopoGraph(rows)
+	components := graph.Components()
+	report := FeederReport{
+		Owner:      owner,
+		FeederID:   feeder,
+		Devices:    len(graph.Devices()),
+		Nodes:      len(graph.Nodes()),
+		Components: len(components),
+		Counts:     make(map[string]int),
+		Issues:     make([]TopoIssue, 0),
+	}
+
+	if islands := graph.Islands(); len(islands) > 0 {
+		mainSize := len(graph.Devices()) - len(graph.Unconnected())
+		for _, island := range islands {
+			mainSize -= len(island)
+		}
+		for _, island := range islands {
+			report.add(TopoIssue{
+				Kind:    issueIsland,
+				Devices: island,
+				Detail:  fmt.Sprintf("%d 台设备与主网(%d 台)不连通", len(island), mainSize),
+			})
+		}
+	}
+
+	for _, device := range graph.Unconnected() {
+		report.add(TopoIssue{Kind: issueNoNode, Devices: []string{device}, Detail: "FIRST_NODE_ID和SECOND_NODE_ID都为空"})
+	}
+
+	pairs := make(map[[2]string][]string)
+	var pairOrder [][2]string
+	selfLoops := make(map[string]bool)
+	for _, row := range rows {
+		if row.FirstNodeID == "" || row.SecondNodeID == "" {
+			continue
+		}
+		if row.FirstNodeID == row.SecondNodeID {
+			if !selfLoops[row.ID] {
+				selfLoops[row.ID] = true
+				report.add(TopoIssue{
+					Kind:    issueSelfLoop,
+					Devices: []string{row.ID},
+					Nodes:   []string{row.FirstNodeID},
+					Detail:  "两端连接同一节点",
+				})
+			}
+			continue
+		}
+		pair := [2]string{row.FirstNodeID, row.SecondNodeID}
+		if pair[0] > pair[1] {
+			pair[0], pair[1] = pair[1], pair[0]
+		}
+		if _, ok := pairs[pair]; !ok {
+			pairOrder = append(pairOrder, pair)
+		}
+		if !first.Contain(pairs[pair], row.ID) {
+			pairs[pair] = append(pairs[pair], row.ID)
+		}
+	}
+	for _, pair := range pairOrder {
+		if devices := pairs[pair]; len(devices) > 1 {
+			report.add(TopoIssue{
+				Kind:    issueParallel,
+				Devices: devices,
+				Nodes:   pair[:],
+				Detail:  fmt.Sprintf("%d 台设备连接同一对节点", len(devices)),
+			})
+		}
+	}
+
+	for _, node := range graph.NodesOverDegree(maxDegree) {
+		report.add(TopoIssue{
+			Kind:    issueHighDegree,
+			Devices: graph.NodeDevices(node),
+			Nodes:   []string{node},
+			Detail:  fmt.Sprintf("连接 %d 台设备, 超过 %d", graph.Degree(node), maxDegree),
+		})
+	}
+	return report
+}
+
+// 有相邻设备, 但相邻设备都不属于本馈线; 联络开关等两侧都有本馈线设备的不算
+func checkFeederMismatch(report *FeederReport, rows []first.Topo, ownerGraph *topology.Graph, feederOf map[string][]string) {
+	checked := make(map[string]bool)
+	for _, row := range rows {
+		if checked[row.ID] {
+			continue
+		}
+		checked[row.ID] = true
+		neighbors := ownerGraph.Neighbors(row.ID)
+		if len(neighbors) == 0 {
+			continue
+		}
+		other := make([]string, 0, len(neighbors))
+		same := false
+		for _, neighbor := range neighbors {
+			if first.Contain(feederOf[neighbor], report.FeederID) {
+				same = true
+				break
+			}
+			for _, feeder := range feederOf[neighbor] {
+				if !first.Contain(other, feeder) {
+					other = append(other, feeder)
+				}
+			}
+		}
+		if same {
+			continue
+		}
+		report.add(TopoIssue{
+			Kind:    issueFeederMismatch,
+			Devices: append([]string{row.ID}, neighbors...),
+			Detail:  "相邻设备馈线: " + strings.Join(other, ","),
+		})
+	}
+}
+
+// 只读查询owner下的拓扑记录
+func queryOwnerTopo(db *gorm.DB, database, owner string) ([]first.Topo, error) {
+	var rows []first.Topo
+	result := db.Table(database+".SG_CON_DPWRGRID_R_TOPO").
+		Where("OWNER = ?", owner).
+		Order("FEEDER_ID, ID").
+		Find(&rows)
+	return rows, result.Error
+}
+
+func splitFeeders(feeders string) []string {
+	var list []string
+	for _, feeder := range strings.Split(feeders, ",") {
+		feeder = strings.Trim(strings.TrimSpace(feeder), "'\"")
+		if feeder != "" {
+			list = append(list, feeder)
+		}
+	}
+	return list
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"raselper/src/forwork/first"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func topoRow(feeder, id, node1, node2 string) first.Topo {
+	return first.Topo{Owner: "O", FeederID: feeder, ID: id, FirstNodeID: node1, SecondNodeID: node2}
+}
+
+func testRows() []first.Topo {
+	rows := []first.Topo{
+		topoRow("F1", "breaker", "n1", "n2"),
+		topoRow("F1", "line1", "n2", "n3"),
+		topoRow("F1", "line2", "n2", "n3"), // 与line1并联
+		topoRow("F1", "loop", "n3", "n3"),
+		topoRow("F1", "none", "", ""),
+		topoRow("F1", "island1", "n8", "n9"),
+		topoRow("F1", "island2", "n9", ""),
+		topoRow("F1", "stray", "m1", ""), // 只与F2的设备相连
+		topoRow("F2", "other", "m1", "m2"),
+		topoRow("F2", "tie", "m2", "n1"), // 联络开关, 两侧都有设备
+	}
+	for i := 0; i < 7; i++ {
+		rows = append(rows, topoRow("F2", "load"+string(rune('a'+i)), "m2", ""))
+	}
+	return rows
+}
+
+func issues(report FeederReport, kind string) []TopoIssue {
+	var list []TopoIssue
+	for _, issue := range report.Issues {
+		if issue.Kind == kind {
+			list = append(list, issue)
+		}
+	}
+	return list
+}
+
+func TestCheckOwner(t *testing.T) {
+	reports := checkOwner("O", testRows(), nil, defaultMaxDegree)
+	if len(reports) != 2 || reports[0].FeederID != "F1" || reports[1].FeederID != "F2" {
+		t.Fatalf("unexpected reports %+v", reports)
+	}
+	f1, f2 := reports[0], reports[1]
+
+	tests := []struct {
+		report  FeederReport
+		kind    string
+		devices [][]string
+	}{
+		{f1, issueIsland, [][]string{{"island1", "island2"}, {"stray"}}},
+		{f1, issueNoNode, [][]string{{"none"}}},
+		{f1, issueParallel, [][]string{{"line1", "line2"}}},
+		{f1, issueSelfLoop, [][]string{{"loop"}}},
+		{f1, issueFeederMismatch, [][]string{{"stray", "other"}}},
+		{f1, issueHighDegree, nil},
+		{f2, issueHighDegree, [][]string{{"other", "tie", "loada", "loadb", "loadc", "loadd", "loade", "loadf", "loadg"}}},
+		{f2, issueFeederMismatch, nil},
+	}
+	for _, tt := range tests {
+		var got [][]string
+		for _, issue := range issues(tt.report, tt.kind) {
+			got = append(got, issue.Devices)
+		}
+		if !reflect.DeepEqual(got, tt.devices) {
+			t.Errorf("%s %s: got %v, want %v", tt.report.FeederID, tt.kind, got, tt.devices)
+		}
+		if tt.report.Counts[tt.kind] != len(tt.devices) {
+			t.Errorf("%s %s: count %d", tt.report.FeederID, tt.kind, tt.report.Counts[tt.kind])
+		}
+	}
+
+	// 只检查指定馈线时仍按owner下所有设备判断相邻馈线
+	if reports := checkOwner("O", testRows(), []string{"F1"}, 10); len(reports) != 1 || reports[0].Counts[issueFeederMismatch] != 1 {
+		t.Fatalf("unexpected filtered reports %+v", reports)
+	}
+}
+
+func TestWriteReports(t *testing.T) {
+	reports := checkOwner("O", testRows(), nil, defaultMaxDegree)
+
+	var buf bytes.Buffer
+	if err := writeReports(&buf, "json", reports); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []FeederReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || !reflect.DeepEqual(decoded, reports) {
+		t.Fatalf("json round trip: %v", err)
+	}
+
+	buf.Reset()
+	if err := writeReports(&buf, "csv", reports); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 1 + len(reports[0].Issues) + len(reports[1].Issues); len(records) != want {
+		t.Fatalf("csv rows %d, want %d", len(records), want)
+	}
+
+	buf.Reset()
+	if err := writeReports(&buf, "html", reports); err != nil {
+		t.Fatal(err)
+	}
+	if html := buf.String(); !strings.Contains(html, `id="F1"`) || !strings.Contains(html, "island1, island2") {
+		t.Fatalf("unexpected html:\n%s", html)
+	}
+
+	if err := writeReports(&buf, "xml", reports); err == nil {
+		t.Fatal("xml should be rejected")
+	}
+}
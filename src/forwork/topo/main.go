@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"raselper/src/forwork/appconfig"
+)
+
+// 用法: topo check [-format json|html|csv] [-o file] [-max-degree 7] [app.yaml]
+//
+//	topo analyze [-format text|json] <file.xml|dir>
+//
+//	check    按owner/馈线检查SG_CON_DPWRGRID_R_TOPO, 只读, 不修改数据库
+//	analyze  直接检查单线图xml, 不连接数据库; 有文件被拒绝时退出码为1
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "check":
+		runCheck(os.Args[2:])
+	case "analyze":
+		runAnalyze(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: topo check [-format json|html|csv] [-o file] [-max-degree 7] [app.yaml]")
+	fmt.Fprintln(os.Stderr, "      topo analyze [-format text|json] <file.xml|dir>")
+	os.Exit(2)
+}
+
+func runCheck(args []string) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	format := flags.String("format", "json", "输出格式 json|html|csv")
+	output := flags.String("o", "", "输出文件, 默认标准输出")
+	maxDegree := flags.Int("max-degree", defaultMaxDegree, "节点连接设备数超过该值时报告")
+	flags.Parse(args)
+	if *format != "json" && *format != "html" && *format != "csv" {
+		log.Fatalf("不支持的格式 %q, 可选 json|html|csv", *format)
+	}
+	configFile := "app.yaml"
+	if flags.NArg() > 0 {
+		configFile = flags.Arg(0)
+	}
+
+	config, err := appconfig.Read(configFile)
+	if err != nil {
+		log.Fatalf("读取配置失败: %v", err)
+	}
+	db, err := appconfig.OpenDB(config.DB)
+	if err != nil {
+		log.Fatal("连接数据库失败:", err)
+	}
+	rows, err := queryOwnerTopo(db, config.DB.Database, config.Owner)
+	if err != nil {
+		log.Fatalf("查询拓扑失败: %v", err)
+	}
+	log.Printf("Owner=%s 共 %d 条拓扑记录", config.Owner, len(rows))
+
+	reports := checkOwner(config.Owner, rows, splitFeeders(config.Feeder), *maxDegree)
+
+	out := os.Stdout
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			log.Fatal(err)
+		}
+		defer out.Close()
+	}
+	if err := writeReports(out, *format, reports); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runAnalyze(args []string) {
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	format := flags.String("format", "text", "输出格式 text|json")
+	flags.Parse(args)
+	if flags.NArg() != 1 || (*format != "text" && *format != "json") {
+		usage()
+	}
+	files, err := analyzeFiles(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	analyses := make([]FileAnalysis, 0, len(files))
+	rejected := false
+	for _, file := range files {
+		analysis := analyzeFile(file)
+		rejected = rejected || analysis.Rejected
+		analyses = append(analyses, analysis)
+	}
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(analyses); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		printAnalysis(analyses)
+	}
+	if rejected {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func writeReports(w io.Writer, format string, reports []FeederReport) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reports)
+	case "csv":
+		return writeCSV(w, reports)
+	case "html":
+		return reportTemplate.Execute(w, struct {
+			Kinds   []string
+			Reports []FeederReport
+		}{issueKinds, reports})
+	}
+	return fmt.Errorf("不支持的格式 %q, 可选 json|html|csv", format)
+}
+
+// 每个问题一行, 设备和节点用;分隔
+func writeCSV(w io.Writer, reports []FeederReport) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"OWNER", "FEEDER_ID", "KIND", "DEVICE_COUNT", "DEVICES", "NODES", "DETAIL"})
+	for _, report := range reports {
+		for _, issue := range report.Issues {
+			writer.Write([]string{
+				report.Owner,
+				report.FeederID,
+				issue.Kind,
+				strconv.Itoa(len(issue.Devices)),
+				strings.Join(issue.Devices, ";"),
+				strings.Join(issue.Nodes, ";"),
+				issue.Detail,
+			})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// 孤立岛等设备较多时只显示前20个
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"devices": func(devices []string) string {
+		if len(devices) > 20 {
+			return strings.Join(devices[:20], ", ") + fmt.Sprintf(" ... 共 %d 台", len(devices))
+		}
+		return strings.Join(devices, ", ")
+	},
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>拓扑检查报告</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; }
+table { border-collapse: collapse; margin-bottom: 16px; }
+th, td { border: 1px solid #ccc; padding: 3px 8px; text-align: left; vertical-align: top; }
+th { background: #eee; }
+td.bad { color: #c00; font-weight: bold; }
+</style>
+</head>
+<body>
+<h2>拓扑检查报告</h2>
+<table>
+<tr><th>OWNER</th><th>FEEDER_ID</th><th>设备</th><th>节点</th><th>连通分量</th>{{range .Kinds}}<th>{{.}}</th>{{end}}</tr>
+{{range .Reports}}{{$report := .}}<tr><td>{{.Owner}}</td><td><a href="#{{.FeederID}}">{{.FeederID}}</a></td><td>{{.Devices}}</td><td>{{.Nodes}}</td><td>{{.Components}}</td>{{range $.Kinds}}{{with index $report.Counts .}}<td class="bad">{{.}}</td>{{else}}<td>0</td>{{end}}{{end}}</tr>
+{{end}}</table>
+{{range .Reports}}{{if .Issues}}
+<h3 id="{{.FeederID}}">{{.Owner}} / {{.FeederID}}</h3>
+<table>
+<tr><th>类型</th><th>说明</th><th>节点</th><th>设备</th></tr>
+{{range .Issues}}<tr><td>{{.Kind}}</td><td>{{.Detail}}</td><td>{{join .Nodes ", "}}</td><td>{{devices .Devices}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}
+</body>
+</html>
+`))
@@ -17,6 +17,24 @@ var (
 	}
 )
 
+func init() {
+	// watch需要调用其他组件, 在init中注册避免初始化循环
+	InstanceList = append(InstanceList, &impl.InstanceWatch{Dispatch: dispatch})
+}
+
+// 执行一条命令, 没有匹配的组件时返回false
+func dispatch(arg []string) bool {
+	matched := false
+	for _, instance := range InstanceList {
+		if instance.SelectComponent(arg) {
+			log.Print("args: ", arg[1:])
+			instance.Run(arg)
+			matched = true
+		}
+	}
+	return matched
+}
+
 func loadConfigArgs() [][]string {
 	// 获取当前执行文件的目录
 	dir, err := os.Getwd()
@@ -62,11 +80,8 @@ func main() {
 			return
 		}
 
-		for _, instance := range InstanceList {
-			if instance.SelectComponent(arg) {
-				log.Print("args: ", arg[1:])
-				instance.Run(arg)
-			}
+		if !dispatch(arg) {
+			log.Print("未知命令: ", arg[1:])
 		}
 	}
 }
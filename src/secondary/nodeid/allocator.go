@@ -0,0 +1,153 @@
+package nodeid
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// 连接节点ID前缀
+const Prefix = "170135"
+
+// 节点ID分配
+type Allocator interface {
+	Next() (string, error)
+}
+
+// 号段来源, 每次预留一段连续的数值
+type Source interface {
+	// 返回预留的第一个值和数量
+	Reserve() (start, size int64, err error)
+}
+
+// 配置, sequence和table二选一
+type Config struct {
+	Sequence string `yaml:"sequence"` // DM序列(含模式名), INCREMENT BY需要等于block
+	Table    string `yaml:"table"`    // 号段表(含模式名), 见TableSource
+	Name     string `yaml:"name"`     // 号段表中的名称, 默认NODE
+	Block    int64  `yaml:"block"`    // 每次预留的数量, 默认1000
+}
+
+// 按配置创建带号段缓存的分配器
+func New(db *gorm.DB, config Config) (Allocator, error) {
+	if config.Block <= 0 {
+		config.Block = 1000
+	}
+	if config.Name == "" {
+		config.Name = "NODE"
+	}
+	switch {
+	case config.Sequence != "":
+		return NewBlockAllocator(&SequenceSource{DB: db, Sequence: config.Sequence, Block: config.Block}), nil
+	case config.Table != "":
+		return NewBlockAllocator(&TableSource{DB: db, Table: config.Table, Name: config.Name, Block: config.Block}), nil
+	}
+	return nil, errors.New("node-id: 需要配置 sequence 或 table")
+}
+
+// 在内存中缓存一个号段, 用完再向来源预留, 可并发使用
+//
+// ID为 170135 + 16位数值, 与原来 170135 + 微秒时间戳的ID等长;
+// 序列从较小的值开始时不会与已有的时间戳ID重复
+type BlockAllocator struct {
+	mu     sync.Mutex
+	source Source
+	next   int64
+	end    int64
+}
+
+func NewBlockAllocator(source Source) *BlockAllocator {
+	return &BlockAllocator{source: source}
+}
+
+func (a *BlockAllocator) Next() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.next >= a.end {
+		start, size, err := a.source.Reserve()
+		if err != nil {
+			return "", fmt.Errorf("预留节点ID失败: %w", err)
+		}
+		if size <= 0 {
+			return "", fmt.Errorf("预留节点ID失败: 号段大小 %d", size)
+		}
+		a.next, a.end = start, start+size
+	}
+	id := a.next
+	a.next++
+	return Format(id), nil
+}
+
+func Format(value int64) string {
+	return fmt.Sprintf("%s%016d", Prefix, value)
+}
+
+// DM序列, 每次NEXTVAL预留 [值, 值+Block)
+//
+//	CREATE SEQUENCE DKYPW.NODE_ID_SEQ START WITH 1 INCREMENT BY 1000;
+type SequenceSource struct {
+	DB       *gorm.DB
+	Sequence string
+	Block    int64
+}
+
+func (s *SequenceSource) Reserve() (int64, int64, error) {
+	var value int64
+	if err := s.DB.Raw("SELECT " + s.Sequence + ".NEXTVAL AS ID").Scan(&value).Error; err != nil {
+		return 0, 0, err
+	}
+	return value, s.Block, nil
+}
+
+// 号段表, 每个名称一行记录下一个可用值, 行锁保证多个进程不会拿到同一段
+//
+//	CREATE TABLE DKYPW.NODE_ID_RANGE (NAME VARCHAR(64) PRIMARY KEY, NEXT_VALUE BIGINT NOT NULL);
+type TableSource struct {
+	DB    *gorm.DB
+	Table string
+	Name  string
+	Block int64
+}
+
+type rangeRow struct {
+	Name      string `gorm:"column:NAME"`
+	NextValue int64  `gorm:"column:NEXT_VALUE"`
+}
+
+func (s *TableSource) Reserve() (int64, int64, error) {
+	var start int64
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var rows []rangeRow
+		if err := tx.Raw("SELECT NAME, NEXT_VALUE FROM "+s.Table+" WHERE NAME = ? FOR UPDATE", s.Name).
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			// 第一次使用, 并发插入时主键冲突, 本次失败
+			start = 1
+			return tx.Table(s.Table).Create(&rangeRow{Name: s.Name, NextValue: start + s.Block}).Error
+		}
+		start = rows[0].NextValue
+		return tx.Table(s.Table).Where("NAME = ?", s.Name).Update("NEXT_VALUE", start+s.Block).Error
+	})
+	return start, s.Block, err
+}
+
+// 测试用, 在内存中递增
+type Fake struct {
+	mu    sync.Mutex
+	value int64
+}
+
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+func (f *Fake) Next() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.value++
+	return Format(f.value), nil
+}
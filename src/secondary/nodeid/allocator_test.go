@@ -0,0 +1,90 @@
+package nodeid
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+type memorySource struct {
+	mu       sync.Mutex
+	next     int64
+	block    int64
+	reserves int
+	err      error
+}
+
+func (s *memorySource) Reserve() (int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return 0, 0, s.err
+	}
+	s.reserves++
+	start := s.next
+	s.next += s.block
+	return start, s.block, nil
+}
+
+func TestBlockAllocatorConcurrent(t *testing.T) {
+	source := &memorySource{next: 1, block: 100}
+	// 两个进程共用一个号段来源
+	allocators := []*BlockAllocator{NewBlockAllocator(source), NewBlockAllocator(source)}
+
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(a *BlockAllocator) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				id, err := a.Next()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("duplicate id %s", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}(allocators[i%2])
+	}
+	wg.Wait()
+
+	if len(seen) != 4000 {
+		t.Fatalf("allocated %d ids", len(seen))
+	}
+	// 号段缓存: 每100个才预留一次
+	if source.reserves > 4000/100+2 {
+		t.Fatalf("too many reserves %d", source.reserves)
+	}
+}
+
+func TestBlockAllocatorError(t *testing.T) {
+	source := &memorySource{err: errors.New("db down")}
+	if _, err := NewBlockAllocator(source).Next(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if id := Format(1); id != "1701350000000000000001" || len(id) != len(Prefix)+16 {
+		t.Fatalf("unexpected id %s", id)
+	}
+	fake := NewFake()
+	a, _ := fake.Next()
+	b, _ := fake.Next()
+	if a == b {
+		t.Fatal("fake should not repeat")
+	}
+}
+
+func TestNewRequiresSource(t *testing.T) {
+	if _, err := New(nil, Config{}); err == nil {
+		t.Fatal("expected error without sequence or table")
+	}
+}
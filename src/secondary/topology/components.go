@@ -0,0 +1,119 @@
+package topology
+
+// 连通分量, 广度优先遍历, 不递归
+//
+// 按设备加入顺序选起点, 每个分量内按遍历顺序排列, 第一个为起点设备;
+// 没有连接节点的设备不属于任何分量, 见Unconnected
+func (g *Graph) Components() [][]string {
+	var components [][]string
+	visited := make(map[string]bool, len(g.devices))
+	for _, start := range g.devices {
+		if visited[start] || len(g.deviceNodes[start]) == 0 {
+			continue
+		}
+		visited[start] = true
+		component := []string{start}
+		for i := 0; i < len(component); i++ {
+			for _, neighbor := range g.Neighbors(component[i]) {
+				if !visited[neighbor] {
+					visited[neighbor] = true
+					component = append(component, neighbor)
+				}
+			}
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+// 连通分量, 以分量的起点设备为key
+func (g *Graph) Groups() map[string][]string {
+	groups := make(map[string][]string)
+	for _, component := range g.Components() {
+		groups[component[0]] = component
+	}
+	return groups
+}
+
+// 孤立岛: 除最大分量(主网)外的其他分量, 同样大小时取先出现的为主网
+func (g *Graph) Islands() [][]string {
+	components := g.Components()
+	if len(components) <= 1 {
+		return nil
+	}
+	main := 0
+	for i, component := range components {
+		if len(component) > len(components[main]) {
+			main = i
+		}
+	}
+	return append(components[:main:main], components[main+1:]...)
+}
+
+// 两台设备是否连通
+func (g *Graph) Connected(a, b string) bool {
+	if !g.HasDevice(a) || !g.HasDevice(b) {
+		return false
+	}
+	if g.uf == nil {
+		g.uf = NewUnionFind()
+		for _, device := range g.devices {
+			g.uf.Add(device)
+		}
+		for _, node := range g.nodes {
+			devices := g.nodeDevices[node]
+			for _, device := range devices[1:] {
+				g.uf.Union(devices[0], device)
+			}
+		}
+	}
+	return g.uf.Find(a) == g.uf.Find(b)
+}
+
+// 并查集, 按秩合并, 查找时路径压缩
+type UnionFind struct {
+	parent map[string]string
+	rank   map[string]int
+}
+
+func NewUnionFind() *UnionFind {
+	return &UnionFind{
+		parent: make(map[string]string),
+		rank:   make(map[string]int),
+	}
+}
+
+func (uf *UnionFind) Add(x string) {
+	if _, ok := uf.parent[x]; !ok {
+		uf.parent[x] = x
+	}
+}
+
+// 查找根, 未加入的元素自成一组
+func (uf *UnionFind) Find(x string) string {
+	uf.Add(x)
+	root := x
+	for uf.parent[root] != root {
+		root = uf.parent[root]
+	}
+	for x != root {
+		x, uf.parent[x] = uf.parent[x], root
+	}
+	return root
+}
+
+func (uf *UnionFind) Union(x, y string) {
+	rootX, rootY := uf.Find(x), uf.Find(y)
+	if rootX == rootY {
+		return
+	}
+	switch {
+	case uf.rank[rootX] > uf.rank[rootY]:
+		uf.parent[rootY] = rootX
+	case uf.rank[rootX] < uf.rank[rootY]:
+		uf.parent[rootX] = rootY
+	default:
+		uf.parent[rootY] = rootX
+		uf.rank[rootX]++
+	}
+}
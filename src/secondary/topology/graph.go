@@ -0,0 +1,116 @@
+package topology
+
+// 设备拓扑图
+//
+// 设备是边, 连接节点是点: 一台设备通过端子连接一个或多个连接节点,
+// 共用同一个连接节点的设备相互连通. 设备和节点都按加入顺序保存, 遍历结果稳定
+type Graph struct {
+	devices     []string
+	deviceNodes map[string][]string // 设备 - 节点
+	nodes       []string
+	nodeDevices map[string][]string // 节点 - 设备
+
+	uf *UnionFind // Connected使用, 加入设备后失效
+}
+
+func New() *Graph {
+	return &Graph{
+		deviceNodes: make(map[string][]string),
+		nodeDevices: make(map[string][]string),
+	}
+}
+
+// 加入设备及其连接节点, 同一设备可多次加入, 节点会合并; 空节点忽略
+func (g *Graph) AddDevice(device string, nodes ...string) {
+	if _, ok := g.deviceNodes[device]; !ok {
+		g.devices = append(g.devices, device)
+		g.deviceNodes[device] = nil
+	}
+	for _, node := range nodes {
+		if node == "" || contains(g.deviceNodes[device], node) {
+			continue
+		}
+		g.deviceNodes[device] = append(g.deviceNodes[device], node)
+		if _, ok := g.nodeDevices[node]; !ok {
+			g.nodes = append(g.nodes, node)
+		}
+		g.nodeDevices[node] = append(g.nodeDevices[node], device)
+	}
+	g.uf = nil
+}
+
+func (g *Graph) HasDevice(device string) bool {
+	_, ok := g.deviceNodes[device]
+	return ok
+}
+
+// 所有设备, 按加入顺序
+func (g *Graph) Devices() []string {
+	return g.devices
+}
+
+// 所有连接节点, 按加入顺序
+func (g *Graph) Nodes() []string {
+	return g.nodes
+}
+
+// 设备的连接节点
+func (g *Graph) DeviceNodes(device string) []string {
+	return g.deviceNodes[device]
+}
+
+// 连接在节点上的设备
+func (g *Graph) NodeDevices(node string) []string {
+	return g.nodeDevices[node]
+}
+
+// 节点的度, 即连接在节点上的设备数
+func (g *Graph) Degree(node string) int {
+	return len(g.nodeDevices[node])
+}
+
+// 与设备共用节点的其他设备, 按节点顺序去重
+func (g *Graph) Neighbors(device string) []string {
+	var neighbors []string
+	seen := map[string]bool{device: true}
+	for _, node := range g.deviceNodes[device] {
+		for _, neighbor := range g.nodeDevices[node] {
+			if !seen[neighbor] {
+				seen[neighbor] = true
+				neighbors = append(neighbors, neighbor)
+			}
+		}
+	}
+	return neighbors
+}
+
+// 没有任何连接节点的设备
+func (g *Graph) Unconnected() []string {
+	var devices []string
+	for _, device := range g.devices {
+		if len(g.deviceNodes[device]) == 0 {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// 度超过max的节点, 按加入顺序
+func (g *Graph) NodesOverDegree(max int) []string {
+	var nodes []string
+	for _, node := range g.nodes {
+		if len(g.nodeDevices[node]) > max {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+func contains(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package topology
+
+// 两台设备间经过设备最少的路径, 包含两端; 不连通时返回nil
+func (g *Graph) Path(from, to string) []string {
+	if !g.HasDevice(from) || !g.HasDevice(to) {
+		return nil
+	}
+	if from == to {
+		return []string{from}
+	}
+
+	previous := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		device := queue[0]
+		queue = queue[1:]
+		for _, neighbor := range g.Neighbors(device) {
+			if _, ok := previous[neighbor]; ok {
+				continue
+			}
+			previous[neighbor] = device
+			if neighbor == to {
+				return tracePath(previous, from, to)
+			}
+			queue = append(queue, neighbor)
+		}
+	}
+	return nil
+}
+
+func tracePath(previous map[string]string, from, to string) []string {
+	var path []string
+	for device := to; device != from; device = previous[device] {
+		path = append(path, device)
+	}
+	path = append(path, from)
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// 从start深度优先展开的所有线路: 每条为起点到末端设备的路径
+//
+// 已经走过的设备视为上游, 不再展开; 没有下游的起点返回只含起点的一条线路
+func (g *Graph) Routes(start string) [][]string {
+	type frame struct {
+		device    string
+		neighbors []string
+		next      int
+		expanded  bool
+	}
+
+	var routes [][]string
+	visited := map[string]bool{start: true}
+	stack := []*frame{{device: start, neighbors: g.Neighbors(start)}}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		child, found := "", false
+		for top.next < len(top.neighbors) && !found {
+			child = top.neighbors[top.next]
+			found = !visited[child]
+			top.next++
+		}
+		if found {
+			top.expanded = true
+			visited[child] = true
+			stack = append(stack, &frame{device: child, neighbors: g.Neighbors(child)})
+			continue
+		}
+
+		if !top.expanded {
+			route := make([]string, len(stack))
+			for i, f := range stack {
+				route[i] = f.device
+			}
+			routes = append(routes, route)
+		}
+		stack = stack[:len(stack)-1]
+	}
+	return routes
+}
@@ -0,0 +1,247 @@
+package topology
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// 样例单线图, 只解析端子
+type cimTerminals struct {
+	Terminals []struct {
+		ConductingEquipment struct {
+			Resource string `xml:"resource,attr"`
+		} `xml:"Terminal.ConductingEquipment"`
+		ConnectivityNode struct {
+			Resource string `xml:"resource,attr"`
+		} `xml:"Terminal.ConnectivityNode"`
+	} `xml:"Terminal"`
+}
+
+func loadFeeder(t *testing.T, path string) *Graph {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rdf cimTerminals
+	if err := xml.Unmarshal(data, &rdf); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	g := New()
+	for _, terminal := range rdf.Terminals {
+		g.AddDevice(strings.TrimPrefix(terminal.ConductingEquipment.Resource, "#"),
+			strings.TrimPrefix(terminal.ConnectivityNode.Resource, "#"))
+	}
+	return g
+}
+
+func sampleFeeders(t *testing.T) []string {
+	files, err := filepath.Glob("../../forwork/data/*.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skip("没有样例单线图")
+	}
+	return files
+}
+
+// 原递归实现, 用于对比
+func recursiveGroups(g *Graph) map[string][]string {
+	groups := make(map[string][]string)
+	hit := make(map[string]bool)
+	var walk func(device string, connected map[string]bool)
+	walk = func(device string, connected map[string]bool) {
+		if hit[device] {
+			return
+		}
+		hit[device] = true
+		for _, node := range g.DeviceNodes(device) {
+			for _, next := range g.NodeDevices(node) {
+				connected[next] = true
+				walk(next, connected)
+			}
+		}
+	}
+	for _, device := range g.Devices() {
+		connected := make(map[string]bool)
+		walk(device, connected)
+		for id := range connected {
+			groups[device] = append(groups[device], id)
+		}
+	}
+	return groups
+}
+
+func recursiveRoutes(g *Graph, start string, hit map[string]bool) [][]string {
+	hit[start] = true
+	var routes [][]string
+	for _, device := range g.Neighbors(start) {
+		if hit[device] {
+			continue
+		}
+		for _, route := range recursiveRoutes(g, device, hit) {
+			routes = append(routes, append([]string{start}, route...))
+		}
+	}
+	if len(routes) == 0 {
+		return [][]string{{start}}
+	}
+	return routes
+}
+
+func sorted(list []string) []string {
+	list = append([]string(nil), list...)
+	sort.Strings(list)
+	return list
+}
+
+func TestSampleFeeders(t *testing.T) {
+	for _, file := range sampleFeeders(t) {
+		g := loadFeeder(t, file)
+		name := filepath.Base(file)
+
+		components := g.Components()
+		seen := make(map[string]int)
+		for i, component := range components {
+			for _, device := range component {
+				if _, ok := seen[device]; ok {
+					t.Fatalf("%s: %s 出现在多个分量中", name, device)
+				}
+				seen[device] = i
+			}
+		}
+		if len(seen)+len(g.Unconnected()) != len(g.Devices()) {
+			t.Fatalf("%s: 分量设备数 %d, 设备数 %d", name, len(seen), len(g.Devices()))
+		}
+
+		// 与原递归实现结果一致
+		groups, want := g.Groups(), recursiveGroups(g)
+		if len(groups) != len(want) {
+			t.Fatalf("%s: %d 个分量, 递归实现 %d 个", name, len(groups), len(want))
+		}
+		for key, devices := range want {
+			if !reflect.DeepEqual(sorted(groups[key]), sorted(devices)) {
+				t.Fatalf("%s: 分量 %s 不一致", name, key)
+			}
+		}
+
+		// 并查集与遍历结果一致, 路径上相邻设备共用节点
+		main := components[0]
+		for _, component := range components {
+			if len(component) > len(main) {
+				main = component
+			}
+		}
+		for _, device := range g.Devices() {
+			i, ok := seen[device]
+			if connected := g.Connected(main[0], device); connected != (ok && reflect.DeepEqual(components[i], main)) {
+				t.Fatalf("%s: Connected(%s, %s) = %v", name, main[0], device, connected)
+			}
+		}
+		path := g.Path(main[0], main[len(main)-1])
+		if path[0] != main[0] || path[len(path)-1] != main[len(main)-1] {
+			t.Fatalf("%s: 路径端点错误 %v", name, path)
+		}
+		for i := 1; i < len(path); i++ {
+			if !contains(g.Neighbors(path[i-1]), path[i]) {
+				t.Fatalf("%s: %s 与 %s 不相连", name, path[i-1], path[i])
+			}
+		}
+
+		if islands := g.Islands(); len(islands) != len(components)-1 {
+			t.Fatalf("%s: %d 个孤立岛, %d 个分量", name, len(islands), len(components))
+		}
+
+		if routes := g.Routes(main[0]); !reflect.DeepEqual(routes, recursiveRoutes(g, main[0], make(map[string]bool))) {
+			t.Fatalf("%s: Routes与递归实现不一致", name)
+		}
+	}
+}
+
+func TestGraph(t *testing.T) {
+	g := New()
+	g.AddDevice("breaker", "n1", "n2")
+	g.AddDevice("line1", "n2", "n3")
+	g.AddDevice("line2", "n2", "n4")
+	g.AddDevice("load", "n3", "")
+	g.AddDevice("island1", "n8", "n9")
+	g.AddDevice("island2", "n9")
+	g.AddDevice("none", "", "")
+	g.AddDevice("line1", "n3") // 重复加入不产生重复节点
+
+	if got := g.DeviceNodes("line1"); !reflect.DeepEqual(got, []string{"n2", "n3"}) {
+		t.Fatalf("DeviceNodes %v", got)
+	}
+	if g.Degree("n2") != 3 || g.Degree("n3") != 2 || g.Degree("nx") != 0 {
+		t.Fatalf("Degree n2=%d n3=%d", g.Degree("n2"), g.Degree("n3"))
+	}
+	if got := g.NodesOverDegree(2); !reflect.DeepEqual(got, []string{"n2"}) {
+		t.Fatalf("NodesOverDegree %v", got)
+	}
+	if got := g.Neighbors("line1"); !reflect.DeepEqual(got, []string{"breaker", "line2", "load"}) {
+		t.Fatalf("Neighbors %v", got)
+	}
+	if got := g.Unconnected(); !reflect.DeepEqual(got, []string{"none"}) {
+		t.Fatalf("Unconnected %v", got)
+	}
+
+	want := [][]string{{"breaker", "line1", "line2", "load"}, {"island1", "island2"}}
+	if got := g.Components(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Components %v", got)
+	}
+	if got := g.Islands(); !reflect.DeepEqual(got, want[1:]) {
+		t.Fatalf("Islands %v", got)
+	}
+	if !g.Connected("load", "line2") || g.Connected("load", "island1") || g.Connected("load", "none") {
+		t.Fatal("Connected")
+	}
+
+	if got := g.Path("load", "line2"); !reflect.DeepEqual(got, []string{"load", "line1", "line2"}) {
+		t.Fatalf("Path %v", got)
+	}
+	if got := g.Path("load", "island1"); got != nil {
+		t.Fatalf("Path to island %v", got)
+	}
+
+	wantRoutes := [][]string{{"breaker", "line1", "line2"}, {"breaker", "line1", "load"}}
+	if got := g.Routes("breaker"); !reflect.DeepEqual(got, wantRoutes) {
+		t.Fatalf("Routes %v", got)
+	}
+	if got := g.Routes("none"); !reflect.DeepEqual(got, [][]string{{"none"}}) {
+		t.Fatalf("Routes from unconnected %v", got)
+	}
+}
+
+// 长线路不会因递归过深耗尽栈
+func TestLongFeeder(t *testing.T) {
+	const n = 200000
+	g := New()
+	for i := 0; i < n; i++ {
+		g.AddDevice(fmt.Sprint("d", i), fmt.Sprint("n", i), fmt.Sprint("n", i+1))
+	}
+	if components := g.Components(); len(components) != 1 || len(components[0]) != n {
+		t.Fatalf("unexpected components %d", len(components))
+	}
+	if path := g.Path("d0", fmt.Sprint("d", n-1)); len(path) != n {
+		t.Fatalf("path length %d", len(path))
+	}
+	if routes := g.Routes("d0"); len(routes) != 1 || len(routes[0]) != n {
+		t.Fatalf("unexpected routes %d", len(routes))
+	}
+}
+
+func TestUnionFind(t *testing.T) {
+	uf := NewUnionFind()
+	uf.Union("a", "b")
+	uf.Union("c", "d")
+	uf.Union("b", "d")
+	if uf.Find("a") != uf.Find("c") || uf.Find("a") == uf.Find("e") {
+		t.Fatal("unexpected union result")
+	}
+}
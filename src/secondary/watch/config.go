@@ -0,0 +1,104 @@
+package watch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config 目录监听配置
+type Config struct {
+	Ledger   string `yaml:"ledger"`   // 已处理文件记录, 重启后不重复处理
+	Interval string `yaml:"interval"` // 扫描间隔, 如 "2s"
+	Poll     bool   `yaml:"poll"`     // 强制使用轮询(不使用inotify)
+	Attempts int    `yaml:"attempts"` // 处理失败的文件最多尝试几次, 默认3
+	Rules    []Rule `yaml:"rules"`
+}
+
+// Rule 单条监听规则
+//
+// Command 中每一行是一条raseper命令, 支持占位符:
+// {file} 文件完整路径, {dir} 文件所在目录, {name} 文件名
+type Rule struct {
+	Name    string   `yaml:"name"`
+	Dir     string   `yaml:"dir"`     // 监听目录, 支持glob, 如 /home/dcloud/backup/model-release/*
+	Pattern []string `yaml:"pattern"` // 文件名匹配, 如 *.zip
+	Stable  string   `yaml:"stable"`  // 文件大小和修改时间保持不变多久才处理, 如 "5s"
+	Command []string `yaml:"command"` // raseper命令
+	Script  string   `yaml:"script"`  // raseper脚本文件, 每行一条命令
+}
+
+// LoadConfig 读取监听配置
+func LoadConfig(filePath string) (*Config, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("解析 YAML 失败: %w", err)
+	}
+
+	if config.Ledger == "" {
+		config.Ledger = ".raseper-watch.json"
+	}
+	if _, err := config.interval(); err != nil {
+		return nil, err
+	}
+	for i, rule := range config.Rules {
+		if rule.Dir == "" {
+			return nil, fmt.Errorf("规则 %d 缺少 dir", i)
+		}
+		if len(rule.Command) == 0 && rule.Script == "" {
+			return nil, fmt.Errorf("规则 %s 缺少 command 或 script", rule.Dir)
+		}
+		if _, err := rule.stable(); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+func (c *Config) interval() (time.Duration, error) {
+	return parseDuration(c.Interval, 2*time.Second)
+}
+
+func (c *Config) attempts() int {
+	if c.Attempts <= 0 {
+		return 3
+	}
+	return c.Attempts
+}
+
+func (r Rule) stable() (time.Duration, error) {
+	return parseDuration(r.Stable, 5*time.Second)
+}
+
+// Match 文件名是否匹配规则, 没有配置pattern时匹配所有文件
+func (r Rule) Match(name string) bool {
+	if len(r.Pattern) == 0 {
+		return true
+	}
+	for _, pattern := range r.Pattern {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func parseDuration(value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("时间格式错误 %s: %w", value, err)
+	}
+	return duration, nil
+}
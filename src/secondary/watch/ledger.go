@@ -0,0 +1,107 @@
+package watch
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LedgerEntry 已处理文件信息, 文件大小或修改时间变化后视为新文件
+type LedgerEntry struct {
+	Rule        string    `json:"rule"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"modTime"`
+	ProcessedAt time.Time `json:"processedAt"`
+	Error       string    `json:"error,omitempty"`
+	Attempts    int       `json:"attempts,omitempty"` // 同一大小和修改时间的文件已处理的次数
+}
+
+// Ledger 已处理文件记录, 保存为json文件
+type Ledger struct {
+	path     string
+	attempts int // 处理失败时最多尝试的次数
+	mu       sync.Mutex
+	entries  map[string]LedgerEntry
+}
+
+// LoadLedger 读取已处理文件记录, 文件不存在时返回空记录
+func LoadLedger(path string, attempts int) (*Ledger, error) {
+	ledger := &Ledger{
+		path:     path,
+		attempts: attempts,
+		entries:  make(map[string]LedgerEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ledger, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return ledger, nil
+	}
+	if err := json.Unmarshal(data, &ledger.entries); err != nil {
+		return nil, err
+	}
+
+	return ledger, nil
+}
+
+// Processed 文件是否不需要再处理(大小和修改时间都一致): 处理成功, 或失败次数已达上限
+func (l *Ledger) Processed(file string, info os.FileInfo) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[file]
+	if !ok || !entry.same(info) {
+		return false
+	}
+	return entry.Error == "" || entry.Attempts >= l.attempts
+}
+
+func (e LedgerEntry) same(info os.FileInfo) bool {
+	return e.Size == info.Size() && e.ModTime.Equal(info.ModTime())
+}
+
+// Mark 记录文件已处理并立即落盘, 返回同一文件已处理的次数
+func (l *Ledger) Mark(file string, info os.FileInfo, rule string, processErr error) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := LedgerEntry{
+		Rule:        rule,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		ProcessedAt: time.Now(),
+		Attempts:    1,
+	}
+	if previous, ok := l.entries[file]; ok && previous.same(info) {
+		entry.Attempts = previous.Attempts + 1
+	}
+	if processErr != nil {
+		entry.Error = processErr.Error()
+	}
+	l.entries[file] = entry
+
+	return entry.Attempts, l.save()
+}
+
+// 先写临时文件再重命名, 避免中途退出写坏记录
+func (l *Ledger) save() error {
+	data, err := json.MarshalIndent(l.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(l.path); dir != "" {
+		_ = os.MkdirAll(dir, 0755)
+	}
+	tmpPath := l.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, l.path)
+}
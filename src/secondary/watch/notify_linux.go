@@ -0,0 +1,101 @@
+//go:build linux
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_MOVED_TO
+
+// inotify监听
+type inotify struct {
+	fd     int
+	file   *os.File
+	mu     sync.Mutex
+	dirs   map[int32]string // wd - 目录
+	events chan string
+	done   chan struct{}
+}
+
+func newNotifier() (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &inotify{
+		fd: fd,
+		// 非阻塞fd交给runtime poller, Close时Read会立即返回
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		dirs:   make(map[int32]string),
+		events: make(chan string, 128),
+		done:   make(chan struct{}),
+	}
+	go n.read()
+
+	return n, nil
+}
+
+func (n *inotify) Add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.dirs[int32(wd)] = dir
+	n.mu.Unlock()
+	return nil
+}
+
+func (n *inotify) Events() <-chan string {
+	return n.events
+}
+
+func (n *inotify) Close() error {
+	close(n.done)
+	return n.file.Close()
+}
+
+func (n *inotify) read() {
+	defer close(n.events)
+
+	buf := make([]byte, syscall.SizeofInotifyEvent*256)
+	for {
+		count, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= count; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			nameEnd := nameStart + int(event.Len)
+			offset = nameEnd
+			if event.Len == 0 || nameEnd > count {
+				continue
+			}
+
+			name := string(buf[nameStart:nameEnd])
+			for len(name) > 0 && name[len(name)-1] == 0 { // 去掉末尾的\0填充
+				name = name[:len(name)-1]
+			}
+
+			n.mu.Lock()
+			dir := n.dirs[event.Wd]
+			n.mu.Unlock()
+			if dir == "" {
+				continue
+			}
+			select {
+			case n.events <- filepath.Join(dir, name):
+			case <-n.done:
+				return
+			}
+		}
+	}
+}
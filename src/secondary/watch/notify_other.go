@@ -0,0 +1,10 @@
+//go:build !linux
+
+package watch
+
+import "errors"
+
+// 非linux平台只使用轮询
+func newNotifier() (notifier, error) {
+	return nil, errors.New("not supported on this platform")
+}
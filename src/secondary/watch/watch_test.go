@@ -0,0 +1,121 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func runWatcher(t *testing.T, config *Config, duration time.Duration) []string {
+	var files []string
+	watcher, err := NewWatcher(config, func(rule Rule, file string) error {
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+	_ = watcher.Run(ctx)
+	return files
+}
+
+func TestWatcherLedger(t *testing.T) {
+	for _, poll := range []bool{true, false} {
+		dir := t.TempDir()
+		config := &Config{
+			Ledger:   filepath.Join(dir, "ledger.json"),
+			Interval: "20ms",
+			Poll:     poll,
+			Rules: []Rule{{
+				Name:    "zip",
+				Dir:     filepath.Join(dir, "*"),
+				Pattern: []string{"*.zip"},
+				Stable:  "50ms",
+				Command: []string{"unzip {file} ./"},
+			}},
+		}
+		subDir := filepath.Join(dir, "model-release")
+		_ = os.MkdirAll(subDir, 0755)
+		_ = os.WriteFile(filepath.Join(subDir, "a.zip"), []byte("a"), 0644)
+		_ = os.WriteFile(filepath.Join(subDir, "a.xml"), []byte("a"), 0644)
+
+		files := runWatcher(t, config, 300*time.Millisecond)
+		if len(files) != 1 || filepath.Base(files[0]) != "a.zip" {
+			t.Fatalf("poll=%v first run got %v", poll, files)
+		}
+
+		// 重启后已处理的文件不再处理, 新文件正常处理
+		_ = os.WriteFile(filepath.Join(subDir, "b.zip"), []byte("b"), 0644)
+		files = runWatcher(t, config, 300*time.Millisecond)
+		if len(files) != 1 || filepath.Base(files[0]) != "b.zip" {
+			t.Fatalf("poll=%v second run got %v", poll, files)
+		}
+	}
+}
+
+func TestLedgerFailedRetry(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.zip")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+	info, _ := os.Stat(file)
+
+	ledger, err := LoadLedger(filepath.Join(dir, "ledger.json"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ledger.Mark(file, info, "zip", errors.New("exit status 1")); err != nil {
+		t.Fatal(err)
+	}
+	if ledger.Processed(file, info) {
+		t.Fatal("处理失败的文件应重新处理")
+	}
+	if _, err := ledger.Mark(file, info, "zip", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !ledger.Processed(file, info) {
+		t.Fatal("处理成功的文件不应重新处理")
+	}
+}
+
+func TestLedgerAttempts(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.zip")
+	_ = os.WriteFile(file, []byte("a"), 0644)
+	info, _ := os.Stat(file)
+
+	ledger, err := LoadLedger(filepath.Join(dir, "ledger.json"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		attempts, err := ledger.Mark(file, info, "zip", errors.New("exit status 1"))
+		if err != nil || attempts != i {
+			t.Fatalf("attempts = %d, %v", attempts, err)
+		}
+	}
+	if !ledger.Processed(file, info) {
+		t.Fatal("失败次数达到上限后不应再处理")
+	}
+
+	// 重新加载后次数保留, 文件变化后重新计数
+	ledger, err = LoadLedger(filepath.Join(dir, "ledger.json"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ledger.Processed(file, info) {
+		t.Fatal("重新加载后失败次数应保留")
+	}
+	_ = os.WriteFile(file, []byte("ab"), 0644)
+	info, _ = os.Stat(file)
+	if ledger.Processed(file, info) {
+		t.Fatal("文件变化后应重新处理")
+	}
+	if attempts, _ := ledger.Mark(file, info, "zip", errors.New("exit status 1")); attempts != 1 {
+		t.Fatalf("文件变化后次数应重新计算, got %d", attempts)
+	}
+}
@@ -0,0 +1,212 @@
+package watch
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Handler 处理一个稳定下来的文件
+type Handler func(rule Rule, file string) error
+
+// notifier 文件变化通知, linux下为inotify, 其他平台不支持时退化为轮询
+type notifier interface {
+	Add(dir string) error
+	Events() <-chan string
+	Close() error
+}
+
+// 等待稳定的文件
+type pendingFile struct {
+	rule    Rule
+	size    int64
+	modTime time.Time
+	seen    time.Time // 最后一次发现变化的时间
+}
+
+// Watcher 目录监听, 新文件稳定后交给Handler处理
+type Watcher struct {
+	config   *Config
+	ledger   *Ledger
+	handler  Handler
+	notifier notifier
+	watched  map[string]bool
+	pending  map[string]*pendingFile
+}
+
+// NewWatcher 创建监听器
+func NewWatcher(config *Config, handler Handler) (*Watcher, error) {
+	ledger, err := LoadLedger(config.Ledger, config.attempts())
+	if err != nil {
+		return nil, err
+	}
+
+	watcher := &Watcher{
+		config:  config,
+		ledger:  ledger,
+		handler: handler,
+		watched: make(map[string]bool),
+		pending: make(map[string]*pendingFile),
+	}
+
+	if !config.Poll {
+		if n, err := newNotifier(); err != nil {
+			log.Println("inotify不可用, 使用轮询:", err)
+		} else {
+			watcher.notifier = n
+		}
+	}
+
+	return watcher, nil
+}
+
+// Run 开始监听, 直到ctx取消
+func (w *Watcher) Run(ctx context.Context) error {
+	interval, _ := w.config.interval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var events <-chan string
+	if w.notifier != nil {
+		defer w.notifier.Close()
+		events = w.notifier.Events()
+	}
+
+	w.scan()
+	w.processStable()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case file, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			w.touch(file)
+		case <-ticker.C:
+			// inotify也定时扫描, 防止新建的子目录和漏掉的事件
+			w.scan()
+			w.processStable()
+		}
+	}
+}
+
+// 扫描所有规则下的目录
+func (w *Watcher) scan() {
+	for _, rule := range w.config.Rules {
+		dirs, err := filepath.Glob(rule.Dir)
+		if err != nil {
+			log.Printf("目录格式错误 %s: %v", rule.Dir, err)
+			continue
+		}
+		for _, dir := range dirs {
+			info, err := os.Stat(dir)
+			if err != nil || !info.IsDir() {
+				continue
+			}
+			w.addDir(dir)
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				log.Printf("读取目录失败 %s: %v", dir, err)
+				continue
+			}
+			for _, entry := range entries {
+				if entry.IsDir() || !rule.Match(entry.Name()) {
+					continue
+				}
+				w.observe(rule, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+}
+
+func (w *Watcher) addDir(dir string) {
+	if w.notifier == nil || w.watched[dir] {
+		return
+	}
+	if err := w.notifier.Add(dir); err != nil {
+		log.Printf("监听目录失败 %s: %v", dir, err)
+		return
+	}
+	w.watched[dir] = true
+}
+
+// 收到inotify事件, 找到对应规则并重置稳定时间
+func (w *Watcher) touch(file string) {
+	for _, rule := range w.config.Rules {
+		if !rule.Match(filepath.Base(file)) {
+			continue
+		}
+		if ok, _ := filepath.Match(rule.Dir, filepath.Dir(file)); !ok {
+			continue
+		}
+		w.observe(rule, file)
+		return
+	}
+}
+
+// 记录文件当前大小和修改时间, 有变化就重新计时
+func (w *Watcher) observe(rule Rule, file string) {
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() {
+		delete(w.pending, file)
+		return
+	}
+	if w.ledger.Processed(file, info) {
+		return
+	}
+
+	pending, ok := w.pending[file]
+	if !ok {
+		w.pending[file] = &pendingFile{
+			rule:    rule,
+			size:    info.Size(),
+			modTime: info.ModTime(),
+			seen:    time.Now(),
+		}
+		return
+	}
+	if pending.size != info.Size() || !pending.modTime.Equal(info.ModTime()) {
+		pending.size = info.Size()
+		pending.modTime = info.ModTime()
+		pending.seen = time.Now()
+	}
+}
+
+// 处理已经稳定的文件
+func (w *Watcher) processStable() {
+	now := time.Now()
+	for file, pending := range w.pending {
+		info, err := os.Stat(file)
+		if err != nil {
+			delete(w.pending, file)
+			continue
+		}
+		if pending.size != info.Size() || !pending.modTime.Equal(info.ModTime()) {
+			w.observe(pending.rule, file)
+			continue
+		}
+		stable, _ := pending.rule.stable()
+		if now.Sub(pending.seen) < stable {
+			continue
+		}
+
+		delete(w.pending, file)
+		log.Println("watch file:", file)
+		processErr := w.handler(pending.rule, file)
+		if processErr != nil {
+			log.Println("watch file fail:", file, processErr)
+		}
+		attempts, err := w.ledger.Mark(file, info, pending.rule.Name, processErr)
+		if err != nil {
+			log.Println("写入处理记录失败:", err)
+		}
+		if processErr != nil && attempts >= w.config.attempts() {
+			log.Printf("watch file give up: %s 已失败 %d 次, 文件变化后才会重新处理", file, attempts)
+		}
+	}
+}